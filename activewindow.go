@@ -0,0 +1,37 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+// Get the title of the window that has focus. The platform specific parts are found in activewindow_<platform>.go
+
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// Run a loop that will continuously get the title of the focused window, at the specified interval. The title is empty
+// if no window has focus.
+func ActiveWindowStats(ctx context.Context, interval time.Duration, results chan string) {
+	defer close(results)
+
+	for {
+		title, err := activeWindowTitle(ctx)
+		if err != nil {
+			logError("Failed to get the active window:", err)
+			return
+		}
+
+		select {
+		case results <- title:
+		case <-ctx.Done():
+			return
+		}
+
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+//go:build linux
+// +build linux
+
+// Get the title of the focused window from the EWMH properties of the X server, read with xprop, or from swaymsg when
+// running Sway (Linux edition). Other Wayland compositors don't tell other programs which window has focus.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// A node in the layout tree of Sway, as printed by "swaymsg -t get_tree".
+type swayNode struct {
+	Name          string     `json:"name"`           // The title of the window.
+	Focused       bool       `json:"focused"`        // Whether the node has focus.
+	Nodes         []swayNode `json:"nodes"`          // The tiled child nodes.
+	FloatingNodes []swayNode `json:"floating_nodes"` // The floating child nodes.
+}
+
+// Find the title of the focused node in a Sway layout tree.
+func (node swayNode) focusedTitle() (string, bool) {
+	if node.Focused {
+		return node.Name, true
+	}
+	for _, children := range [][]swayNode{node.Nodes, node.FloatingNodes} {
+		for _, child := range children {
+			if title, found := child.focusedTitle(); found {
+				return title, true
+			}
+		}
+	}
+	return "", false
+}
+
+// Get the title of the focused window in Sway.
+func swayActiveWindowTitle(ctx context.Context) (string, error) {
+	output, err := exec.CommandContext(ctx, "swaymsg", "-t", "get_tree").Output()
+	if err != nil {
+		return "", err
+	}
+	var tree swayNode
+	if err := json.Unmarshal(output, &tree); err != nil {
+		return "", err
+	}
+	title, _ := tree.focusedTitle()
+	return title, nil
+}
+
+// Get the value of a property of an X window (or the root window if id is empty), as printed by xprop, e.g.
+// `_NET_ACTIVE_WINDOW(WINDOW): window id # 0x3a00007` or `_NET_WM_NAME(UTF8_STRING) = "Title"`.
+func xprop(ctx context.Context, id string, property string) (string, error) {
+	args := []string{"-root", property}
+	if id != "" {
+		args = []string{"-id", id, property}
+	}
+	output, err := exec.CommandContext(ctx, "xprop", args...).Output()
+	if err != nil {
+		return "", err
+	}
+
+	line := strings.TrimSpace(string(output))
+	if i := strings.Index(line, " = "); i >= 0 {
+		value := line[i+3:]
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			return unquoted, nil
+		}
+		return strings.Trim(value, `"`), nil
+	} else if i := strings.Index(line, "# "); i >= 0 {
+		return line[i+2:], nil
+	}
+	return "", fmt.Errorf("no value in '%s'", line)
+}
+
+// Get the title of the focused window.
+func activeWindowTitle(ctx context.Context) (string, error) {
+	if os.Getenv("SWAYSOCK") != "" {
+		return swayActiveWindowTitle(ctx)
+	}
+
+	id, err := xprop(ctx, "", "_NET_ACTIVE_WINDOW")
+	if err != nil {
+		return "", err
+	} else if id == "0x0" {
+		return "", nil // No window has focus.
+	}
+
+	// The window might have been closed since, or lack the EWMH title, so fall back to the older property.
+	for _, property := range []string{"_NET_WM_NAME", "WM_NAME"} {
+		if title, err := xprop(ctx, id, property); err == nil {
+			return title, nil
+		}
+	}
+	return "", nil
+}
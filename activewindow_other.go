@@ -0,0 +1,19 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+//go:build !linux && !windows
+// +build !linux,!windows
+
+// Get the title of the focused window (unsupported platform edition)
+
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// Getting the focused window is not supported on this platform.
+func activeWindowTitle(ctx context.Context) (string, error) {
+	return "", fmt.Errorf("not supported on this platform")
+}
@@ -0,0 +1,43 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+// Get the title of the foreground window from user32.dll (Windows edition)
+
+package main
+
+import (
+	"context"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	user32                   = windows.NewLazySystemDLL("user32.dll")
+	procGetForegroundWindow  = user32.NewProc("GetForegroundWindow")
+	procGetWindowTextLengthW = user32.NewProc("GetWindowTextLengthW")
+	procGetWindowTextW       = user32.NewProc("GetWindowTextW")
+)
+
+// Get the title of the foreground window.
+func activeWindowTitle(ctx context.Context) (string, error) {
+	if err := user32.Load(); err != nil {
+		return "", err
+	}
+
+	window, _, _ := procGetForegroundWindow.Call()
+	if window == 0 {
+		return "", nil // No window has focus, e.g. while switching between windows.
+	}
+	length, _, _ := procGetWindowTextLengthW.Call(window)
+	if length == 0 {
+		return "", nil
+	}
+
+	buf := make([]uint16, length+1)
+	procGetWindowTextW.Call(window, uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf)))
+	return windows.UTF16ToString(buf), nil
+}
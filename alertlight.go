@@ -0,0 +1,104 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+// Reflect the alert state of the system in the RGB underglow (or status LED) of the keyboard: red when any metric has
+// crossed its alert threshold, and green otherwise. This requires firmware support for the SetRGB command.
+
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// How often the metrics are checked for alerts.
+const ALERT_LIGHT_INTERVAL = 5 * time.Second
+
+// The colors of the underglow, as red, green, and blue.
+var (
+	ALERT_COLOR   = [3]uint8{255, 0, 0} // The color when any metric is in alert.
+	HEALTHY_COLOR = [3]uint8{0, 255, 0} // The color when all metrics are fine.
+)
+
+// Set the color of the RGB underglow of the keyboard.
+// The response isn't waited for, since it would be mixed up with the responses to the drawing of the master screen.
+func (oled *OLEDController) SetRGB(r, g, b uint8) bool {
+	return oled.SendCommand(SetRGB, Master, []byte{r, g, b})
+}
+
+// Get whether any of the metrics has crossed the alert threshold given for it. Metrics that are unknown are fine.
+func inAlert(cpu, cpuTemp, gpu, gpuTemp float64) bool {
+	return alertMark(cpu*100, *gArgs.cpuAlert) != "" ||
+		alertMark(cpuTemp, *gArgs.cpuTempAlert) != "" ||
+		alertMark(gpu*100, *gArgs.gpuAlert) != "" ||
+		alertMark(gpuTemp, *gArgs.gpuTempAlert) != ""
+}
+
+// Keep the color of the underglow according to the alert state of the CPU and graphics card, as given by the -cpu-alert,
+// -cpu-temp-alert, -gpu-alert, and -gpu-temp-alert flags. The color is set again after the device has been reconnected.
+// It will run until quit has been closed. The caller must add it to the wait group before starting it.
+func (oled *OLEDController) RunAlertLight(wg *sync.WaitGroup, quit chan bool) {
+	defer wg.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sysStats := make(chan SystemStatsResult, 5)
+	gpuStats := make(chan GraphicCardResult, 5)
+	go SystemStats(ctx, ALERT_LIGHT_INTERVAL, sysStats)
+	if *gArgs.gpuAlert > 0 || *gArgs.gpuTempAlert > 0 {
+		go GraphicCardStats(ctx, ALERT_LIGHT_INTERVAL, *gArgs.temperatureUnit, gpuStats)
+	} else {
+		gpuStats = nil
+	}
+	sensor := FindCPUTemperatureSensor(*gArgs.cpuTempSensor)
+
+	var cpu, cpuTemp, gpu, gpuTemp float64
+	applied := -1 // Which color has been applied: 0 for healthy, 1 for alert, and -1 for none.
+	connected := true
+	ticker := time.NewTicker(ALERT_LIGHT_INTERVAL)
+	defer ticker.Stop()
+	for {
+		select {
+		case result, more := <-sysStats:
+			if !more {
+				sysStats = nil
+			} else if len(result.Values) > 0 {
+				cpu = result.Values[0]
+			}
+			if sensor != "" {
+				if temp, err := ReadCPUTemperature(sensor); err == nil {
+					cpuTemp = ConvertTemperature(temp, *gArgs.temperatureUnit)
+				}
+			}
+		case result, more := <-gpuStats:
+			if !more {
+				gpuStats = nil
+			} else {
+				gpu, gpuTemp = result.GPU, result.Temperature
+			}
+		case <-ticker.C:
+			// The keyboard might have been reset while disconnected.
+			oled.mutex.Lock()
+			if !oled.Connected {
+				applied = -1
+			}
+			connected = oled.Connected
+			oled.mutex.Unlock()
+		case <-quit:
+			return
+		}
+
+		state, color := 0, HEALTHY_COLOR
+		if inAlert(cpu, cpuTemp, gpu, gpuTemp) {
+			state, color = 1, ALERT_COLOR
+		}
+		if connected && state != applied {
+			logDebugf("Setting the underglow to %v.\n", color)
+			if oled.SetRGB(color[0], color[1], color[2]) {
+				applied = state
+			}
+		}
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+//go:build linux
+// +build linux
+
+// Get status of the first AMD graphics card. Uses the amdgpu driver's files in /sys/ (Linux edition)
+
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The sysfs directory of the AMD graphics card.
+const AMD_DEVICE_PATH = "/sys/class/drm/card0/device"
+
+// Read a file from the device directory, and return its content split into fields.
+func readAMDFields(name string) ([]string, error) {
+	content, err := ioutil.ReadFile(filepath.Join(AMD_DEVICE_PATH, name))
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(string(content)), nil
+}
+
+// Read a file from the device directory containing a single number.
+func readAMDValue(name string) (float64, error) {
+	fields, err := readAMDFields(name)
+	if err != nil {
+		return 0, err
+	} else if len(fields) < 1 {
+		return 0, strconv.ErrSyntax
+	}
+	return strconv.ParseFloat(fields[0], 64)
+}
+
+// Get the maximum bandwidth of the current PCIe link, in bytes per second.
+func amdPCIBandwidth() float64 {
+	speed, err := readAMDValue("current_link_speed") // E.g. "8.0 GT/s PCIe"
+	if err != nil {
+		return 0
+	}
+	width, err := readAMDValue("current_link_width")
+	if err != nil {
+		return 0
+	}
+
+	// PCIe 1.0 and 2.0 use 8b/10b encoding, while later generations use 128b/130b.
+	encoding := 128.0 / 130.0
+	if speed < 8 {
+		encoding = 8.0 / 10.0
+	}
+	return speed * 1e9 * encoding / 8 * width
+}
+
+// Run a loop that will continuously get status from the AMD graphics card, at the specified interval.
+func AMDStats(ctx context.Context, interval time.Duration, unit string, results chan GraphicCardResult) {
+	defer close(results)
+
+	if _, err := readAMDValue("gpu_busy_percent"); err != nil {
+		logError("Failed to find AMD device:", err)
+		return
+	}
+
+	hwmon, _ := filepath.Glob(filepath.Join(AMD_DEVICE_PATH, "hwmon", "hwmon*"))
+	if len(hwmon) < 1 {
+		logError("Failed to find hardware monitor for AMD device.")
+		return
+	}
+	sensors, _ := filepath.Rel(AMD_DEVICE_PATH, hwmon[0])
+
+	for {
+		start := time.Now()
+		var result GraphicCardResult
+
+		if busy, err := readAMDValue("gpu_busy_percent"); err != nil {
+			logError("Failed to get GPU utilization:", err)
+		} else {
+			result.GPU = busy / 100
+		}
+
+		used, err := readAMDValue("mem_info_vram_used")
+		if err == nil {
+			var total float64
+			if total, err = readAMDValue("mem_info_vram_total"); err == nil {
+				result.Memory = used / total
+				result.MemoryUsed, result.MemoryTotal = used, total
+			}
+		}
+		if err != nil {
+			logError("Failed to get memory utilization:", err)
+		}
+
+		// The temperature is in millidegrees Celsius.
+		if temp, err := readAMDValue(filepath.Join(sensors, "temp1_input")); err != nil {
+			logError("Failed to get temperature:", err)
+		} else {
+			result.Temperature = ConvertTemperature(temp/1000, unit)
+		}
+
+		// The fan speed is a PWM value, usually between 0 and 255.
+		pwm, err := readAMDValue(filepath.Join(sensors, "pwm1"))
+		if err == nil {
+			var pwmMax float64
+			if pwmMax, err = readAMDValue(filepath.Join(sensors, "pwm1_max")); err == nil {
+				result.FanSpeed = pwm / pwmMax
+			}
+		}
+		if err != nil {
+			logDebug("Failed to get fan speed:", err)
+		}
+
+		// The power draw and limit are in microwatts, and not available on all cards.
+		if power, err := readAMDValue(filepath.Join(sensors, "power1_average")); err == nil {
+			result.Power = power / 1e6
+		}
+		if limit, err := readAMDValue(filepath.Join(sensors, "power1_cap")); err == nil {
+			result.PowerLimit = limit / 1e6
+		}
+
+		// Number of packets received and sent during the last second, and the maximum size of a packet.
+		// Note that this takes a second to read.
+		if fields, err := readAMDFields("pcie_bw"); err == nil && len(fields) == 3 {
+			received, _ := strconv.ParseFloat(fields[0], 64)
+			sent, _ := strconv.ParseFloat(fields[1], 64)
+			size, _ := strconv.ParseFloat(fields[2], 64)
+			if bandwidth := amdPCIBandwidth(); bandwidth > 0 {
+				result.PCIBandwidth = (received + sent) * size / bandwidth
+			}
+		}
+
+		results <- result
+
+		select {
+		case <-time.After(interval - time.Since(start)):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+// Calculate the phase of the moon, and the times of sunrise and sunset, locally from the date and position.
+
+package main
+
+import (
+	"math"
+	"time"
+)
+
+// How often to update the phase of the moon and the times of the sun.
+const ASTRO_REFRESH_INTERVAL = 1 * time.Minute
+
+// The average length of a lunar cycle, from one new moon to the next, in days.
+const SYNODIC_MONTH = 29.530588853
+
+// A known new moon, which the phase is counted from.
+var REFERENCE_NEW_MOON = time.Date(2000, time.January, 6, 18, 14, 0, 0, time.UTC)
+
+// The Julian date of the Unix epoch, and of the J2000 epoch (2000-01-01 12:00 UTC).
+const (
+	JULIAN_UNIX_EPOCH  = 2440587.5
+	JULIAN_J2000_EPOCH = 2451545.0
+)
+
+// The names of the phases of the moon, in order from the new moon.
+var MOON_PHASE_NAMES = []string{
+	"New moon",
+	"Waxing crescent",
+	"First quarter",
+	"Waxing gibbous",
+	"Full moon",
+	"Waning gibbous",
+	"Last quarter",
+	"Waning crescent",
+}
+
+// Get the phase of the moon at the specified time, as the fraction (0-1) of the lunar cycle since the last new moon.
+// 0.5 is the full moon.
+func MoonPhase(t time.Time) float64 {
+	days := t.Sub(REFERENCE_NEW_MOON).Hours() / 24
+	phase := math.Mod(days/SYNODIC_MONTH, 1)
+	if phase < 0 {
+		phase += 1
+	}
+	return phase
+}
+
+// Get the fraction (0-1) of the moon that is lit at the specified phase.
+func MoonIllumination(phase float64) float64 {
+	return (1 - math.Cos(2*math.Pi*phase)) / 2
+}
+
+// Get the name of the specified phase of the moon. Each name covers an eighth of the cycle, centered on the phase.
+func MoonPhaseName(phase float64) string {
+	return MOON_PHASE_NAMES[int(math.Floor(phase*8+0.5))%len(MOON_PHASE_NAMES)]
+}
+
+// Convert a time to a Julian date.
+func julianDate(t time.Time) float64 {
+	return float64(t.UnixNano())/float64(24*time.Hour) + JULIAN_UNIX_EPOCH
+}
+
+// Convert a Julian date to a time, in the location of the specified time.
+func fromJulianDate(date float64, loc *time.Location) time.Time {
+	return time.Unix(0, int64((date-JULIAN_UNIX_EPOCH)*float64(24*time.Hour))).In(loc)
+}
+
+// Get the times of sunrise and sunset on the day of the specified time, at the position given in decimal degrees.
+// Returns the times in the location of the specified time. If the sun doesn't rise or set that day, which happens near
+// the poles, found is false, and up tells whether the sun stays up or down.
+// Uses the sunrise equation, which is accurate to within a minute or two away from the poles.
+func SunTimes(t time.Time, latitude, longitude float64) (sunrise, sunset time.Time, found, up bool) {
+	toRadians := func(degrees float64) float64 { return degrees * math.Pi / 180 }
+
+	// The number of days since J2000 of the local noon, and the mean solar noon at the longitude.
+	noon := time.Date(t.Year(), t.Month(), t.Day(), 12, 0, 0, 0, t.Location())
+	days := math.Round(julianDate(noon) - JULIAN_J2000_EPOCH + 0.0008)
+	meanNoon := days - longitude/360
+
+	anomaly := math.Mod(357.5291+0.98560028*meanNoon, 360)
+	center := 1.9148*math.Sin(toRadians(anomaly)) + 0.02*math.Sin(toRadians(2*anomaly)) +
+		0.0003*math.Sin(toRadians(3*anomaly))
+	eclipticLongitude := math.Mod(anomaly+center+180+102.9372, 360)
+	transit := JULIAN_J2000_EPOCH + meanNoon + 0.0053*math.Sin(toRadians(anomaly)) -
+		0.0069*math.Sin(toRadians(2*eclipticLongitude))
+
+	declination := math.Asin(math.Sin(toRadians(eclipticLongitude)) * math.Sin(toRadians(23.4397)))
+	// The sun is considered up when its upper edge is above the horizon, after refraction.
+	cosHourAngle := (math.Sin(toRadians(-0.833)) - math.Sin(toRadians(latitude))*math.Sin(declination)) /
+		(math.Cos(toRadians(latitude)) * math.Cos(declination))
+	if cosHourAngle > 1 {
+		return time.Time{}, time.Time{}, false, false
+	} else if cosHourAngle < -1 {
+		return time.Time{}, time.Time{}, false, true
+	}
+
+	hourAngle := math.Acos(cosHourAngle) * 180 / math.Pi
+	sunrise = fromJulianDate(transit-hourAngle/360, t.Location())
+	sunset = fromJulianDate(transit+hourAngle/360, t.Location())
+	return sunrise, sunset, true, false
+}
@@ -0,0 +1,89 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+// Control the brightness of the screens, dimming them at night if a schedule has been given.
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// How often to check whether the brightness should change.
+const BRIGHTNESS_CHECK_INTERVAL = 1 * time.Minute
+
+// Parse a time of day in the format "HH:MM", giving the time since midnight.
+func parseTimeOfDay(value string) (time.Duration, error) {
+	parsed, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("'%s' is not in the format HH:MM", value)
+	}
+	return time.Duration(parsed.Hour())*time.Hour + time.Duration(parsed.Minute())*time.Minute, nil
+}
+
+// Whether the specified time is within the night, which might span midnight.
+func isNight(now time.Time, start, end time.Duration) bool {
+	year, month, day := now.Date()
+	sinceMidnight := now.Sub(time.Date(year, month, day, 0, 0, 0, 0, now.Location()))
+	if start <= end {
+		return sinceMidnight >= start && sinceMidnight < end
+	}
+	return sinceMidnight >= start || sinceMidnight < end
+}
+
+// Get the brightness that the screens should have at the specified time, or -1 to leave it unchanged.
+// During the night, the night brightness is used. Otherwise the brightness given with -brightness is used, or the
+// brightest level if the screens need to be restored after the night.
+func scheduledBrightness(now time.Time) int {
+	if *gArgs.nightStart == "" || *gArgs.nightEnd == "" {
+		return *gArgs.brightness
+	}
+
+	// The times have been checked by checkArgs.
+	start, _ := parseTimeOfDay(*gArgs.nightStart)
+	end, _ := parseTimeOfDay(*gArgs.nightEnd)
+	if isNight(now, start, end) {
+		return *gArgs.nightBrightness
+	} else if *gArgs.brightness >= 0 {
+		return *gArgs.brightness
+	}
+	return math.MaxUint8
+}
+
+// Keep the brightness of the screens according to the schedule, changing it when crossing the boundaries of the night,
+// and applying it again after the device has been reconnected. The brightness is changed by sending brightness events
+// to the screens, so that the commands aren't mixed up with the drawing.
+// It will run until quit has been closed. The caller must add it to the wait group before starting it.
+func (oled *OLEDController) RunBrightness(events map[ScreenID]chan Event, wg *sync.WaitGroup, quit chan bool) {
+	defer wg.Done()
+
+	ticker := time.NewTicker(BRIGHTNESS_CHECK_INTERVAL)
+	defer ticker.Stop()
+
+	applied := -1
+	for {
+		if level := scheduledBrightness(time.Now()); level >= 0 && level != applied {
+			logDebugf("Setting the brightness to %d.\n", level)
+			for screen, screenEvents := range events {
+				select {
+				case screenEvents <- Event{Event: Brightness, Screen: screen, Params: []byte{uint8(level)}}:
+				case <-quit:
+					return
+				}
+			}
+			applied = level
+		}
+
+		select {
+		case <-ticker.C:
+		case <-oled.reconnected:
+			// The keyboard might have been reset.
+			applied = -1
+		case <-quit:
+			return
+		}
+	}
+}
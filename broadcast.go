@@ -0,0 +1,132 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+// Sharing of the data sources between everything using them, such as the tags and the MQTT publisher. A source is
+// started when the first one subscribes to it, and its values are fanned out to all the subscribers, until the last one
+// has left, at which point it's stopped.
+
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// A data source shared by its subscribers.
+type broadcast struct {
+	cancel      context.CancelFunc        // Stops the source.
+	subscribers map[chan interface{}]bool // The channels of the subscribers.
+	last        interface{}               // The last value of the source, or nil if it hasn't got one yet.
+}
+
+// The running data sources, keyed by the source and its parameters (see cacheKey).
+var broadcasts = struct {
+	sync.Mutex
+	sources map[string]*broadcast
+	running int // The number of sources that haven't stopped yet, including the ones that have been told to.
+}{sources: make(map[string]*broadcast)}
+
+// Signaled each time a data source has stopped.
+var sourceStopped = sync.NewCond(&broadcasts)
+
+// Subscribe to the data source with the specified key, starting it if it isn't already running. The start function runs
+// the source, sending its values to the channel, until the context is canceled. Returns a channel with the values of
+// the source, beginning with the last one if it was already running, which is closed when the context is canceled or
+// the source stops. A subscriber that falls behind misses values, rather than holding up the others.
+func subscribe(ctx context.Context, key string, start func(ctx context.Context, values chan interface{})) chan interface{} {
+	subscriber := make(chan interface{}, 5)
+
+	broadcasts.Lock()
+	source, found := broadcasts.sources[key]
+	if !found {
+		var sourceCtx context.Context
+		source = &broadcast{subscribers: make(map[chan interface{}]bool)}
+		sourceCtx, source.cancel = context.WithCancel(context.Background())
+		broadcasts.sources[key] = source
+		broadcasts.running++
+		go source.run(sourceCtx, key, start)
+	} else if source.last != nil {
+		subscriber <- source.last
+	}
+	source.subscribers[subscriber] = true
+	broadcasts.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		broadcasts.Lock()
+		defer broadcasts.Unlock()
+		if !source.subscribers[subscriber] {
+			return // The source has already stopped.
+		}
+		delete(source.subscribers, subscriber)
+		close(subscriber)
+		if len(source.subscribers) < 1 {
+			source.stop(key)
+		}
+	}()
+
+	return subscriber
+}
+
+// Run the data source, sending its values to the subscribers until it stops.
+func (source *broadcast) run(ctx context.Context, key string, start func(ctx context.Context, values chan interface{})) {
+	values := make(chan interface{})
+	go func() {
+		defer close(values)
+		start(ctx, values)
+	}()
+
+	for value := range values {
+		broadcasts.Lock()
+		source.last = value
+		for subscriber := range source.subscribers {
+			select {
+			case subscriber <- value:
+			default:
+			}
+		}
+		broadcasts.Unlock()
+	}
+
+	// The source has stopped, either by itself (e.g. when failing), or because it has no subscribers left.
+	broadcasts.Lock()
+	defer broadcasts.Unlock()
+	for subscriber := range source.subscribers {
+		delete(source.subscribers, subscriber)
+		close(subscriber)
+	}
+	source.stop(key)
+	broadcasts.running--
+	sourceStopped.Broadcast()
+}
+
+// Stop the data source, so that the next subscriber starts it again. The broadcasts need to be locked.
+func (source *broadcast) stop(key string) {
+	source.cancel()
+	if broadcasts.sources[key] == source {
+		delete(broadcasts.sources, key)
+	}
+}
+
+// Wait for all the data sources to stop, after everything using them has been stopped. Returns false if they haven't
+// stopped within the timeout.
+func waitForSources(timeout time.Duration) bool {
+	stopped := make(chan bool)
+	go func() {
+		broadcasts.Lock()
+		defer broadcasts.Unlock()
+		for broadcasts.running > 0 {
+			sourceStopped.Wait()
+		}
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+// Tests of the sharing of the data sources.
+
+package main
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// A data source sending the values it's given, until it's stopped or there are no more values.
+type testSource struct {
+	send    chan interface{} // The values to send.
+	starts  int32            // The number of times the source has been started.
+	stopped chan bool        // Gets a value each time the source stops.
+}
+
+func newTestSource() *testSource {
+	return &testSource{send: make(chan interface{}), stopped: make(chan bool, 10)}
+}
+
+func (source *testSource) start(ctx context.Context, values chan interface{}) {
+	atomic.AddInt32(&source.starts, 1)
+	defer func() { source.stopped <- true }()
+	for {
+		select {
+		case value, more := <-source.send:
+			if !more {
+				return
+			}
+			values <- value
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Get the next value from a subscription, failing the test if there is none.
+func receive(t *testing.T, values chan interface{}) interface{} {
+	t.Helper()
+	select {
+	case value, more := <-values:
+		if !more {
+			t.Fatal("The subscription was closed.")
+		}
+		return value
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for a value.")
+	}
+	return nil
+}
+
+// Wait for a subscription to be closed, failing the test if it isn't.
+func waitClosed(t *testing.T, values chan interface{}) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case _, more := <-values:
+			if !more {
+				return
+			}
+		case <-timeout:
+			t.Fatal("Timed out waiting for the subscription to be closed.")
+		}
+	}
+}
+
+// Wait for a source to stop, failing the test if it doesn't.
+func waitStopped(t *testing.T, source *testSource) {
+	t.Helper()
+	select {
+	case <-source.stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the source to stop.")
+	}
+}
+
+func TestSubscribeShared(t *testing.T) {
+	source := newTestSource()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	first := subscribe(ctx, t.Name(), source.start)
+	second := subscribe(ctx, t.Name(), source.start)
+	source.send <- 1
+	if got := receive(t, first); got != 1 {
+		t.Errorf("The first subscriber got %v, want 1", got)
+	}
+	if got := receive(t, second); got != 1 {
+		t.Errorf("The second subscriber got %v, want 1", got)
+	}
+
+	// A late subscriber gets the last value right away.
+	third := subscribe(ctx, t.Name(), source.start)
+	if got := receive(t, third); got != 1 {
+		t.Errorf("The third subscriber got %v, want 1", got)
+	}
+
+	if starts := atomic.LoadInt32(&source.starts); starts != 1 {
+		t.Errorf("The source was started %d times, want once", starts)
+	}
+
+	// Another key is another source.
+	other := newTestSource()
+	fourth := subscribe(ctx, t.Name()+"-other", other.start)
+	other.send <- 2
+	if got := receive(t, fourth); got != 2 {
+		t.Errorf("The subscriber of the other source got %v, want 2", got)
+	}
+
+	cancel()
+	waitStopped(t, source)
+	waitStopped(t, other)
+}
+
+func TestSubscribeUnsubscribe(t *testing.T) {
+	source := newTestSource()
+	firstCtx, cancelFirst := context.WithCancel(context.Background())
+	defer cancelFirst()
+	secondCtx, cancelSecond := context.WithCancel(context.Background())
+	defer cancelSecond()
+
+	first := subscribe(firstCtx, t.Name(), source.start)
+	second := subscribe(secondCtx, t.Name(), source.start)
+
+	// The source keeps running for the remaining subscriber.
+	cancelFirst()
+	waitClosed(t, first)
+	source.send <- "a"
+	if got := receive(t, second); got != "a" {
+		t.Errorf("The remaining subscriber got %v, want a", got)
+	}
+
+	// The source is stopped once the last subscriber has left.
+	cancelSecond()
+	waitClosed(t, second)
+	waitStopped(t, source)
+
+	// And started again by the next one.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	third := subscribe(ctx, t.Name(), source.start)
+	source.send <- "b"
+	if got := receive(t, third); got != "b" {
+		t.Errorf("The new subscriber got %v, want b", got)
+	}
+	if starts := atomic.LoadInt32(&source.starts); starts != 2 {
+		t.Errorf("The source was started %d times, want twice", starts)
+	}
+	cancel()
+	waitStopped(t, source)
+}
+
+func TestSubscribeSourceStops(t *testing.T) {
+	source := newTestSource()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	first := subscribe(ctx, t.Name(), source.start)
+	second := subscribe(ctx, t.Name(), source.start)
+
+	// All subscriptions end when the source stops by itself, e.g. when it fails.
+	close(source.send)
+	waitStopped(t, source)
+	waitClosed(t, first)
+	waitClosed(t, second)
+
+	// The next subscriber starts it again.
+	source.send = make(chan interface{})
+	third := subscribe(ctx, t.Name(), source.start)
+	source.send <- 3
+	if got := receive(t, third); got != 3 {
+		t.Errorf("The new subscriber got %v, want 3", got)
+	}
+	cancel()
+	waitStopped(t, source)
+}
+
+// A subscriber that doesn't keep up doesn't hold up the others.
+func TestSubscribeSlowSubscriber(t *testing.T) {
+	source := newTestSource()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	subscribe(ctx, t.Name(), source.start) // Never read.
+	fast := subscribe(ctx, t.Name(), source.start)
+	for i := 0; i < 20; i++ {
+		source.send <- i
+		if got := receive(t, fast); got != i {
+			t.Fatalf("The fast subscriber got %v, want %d", got, i)
+		}
+	}
+	cancel()
+	waitStopped(t, source)
+}
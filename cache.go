@@ -0,0 +1,44 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+// Cache of the last value of the data sources that are slow to poll, such as the weather. A source that is started
+// again, e.g. when switching tags or after reconnecting to the keyboard, sends its cached value right away, so that the
+// screen isn't blank until the first poll has finished. The cached value might be stale, but is replaced as soon as the
+// source has a new one.
+
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+// The last value of each data source, keyed by the source and its parameters.
+var lastValues = struct {
+	sync.Mutex
+	values map[string]interface{}
+}{values: make(map[string]interface{})}
+
+// Get the key of a data source, which includes its parameters so that a value isn't shown for other parameters (e.g.
+// the weather at another location).
+func cacheKey(source string, params ...interface{}) string {
+	return source + fmt.Sprint(params...)
+}
+
+// Remember the last value of a data source.
+func cacheValue(key string, value interface{}) {
+	lastValues.Lock()
+	defer lastValues.Unlock()
+	lastValues.values[key] = value
+}
+
+// Get the last value of a data source. Returns false if the source hasn't produced a value yet.
+func cachedValue(key string) (interface{}, bool) {
+	lastValues.Lock()
+	defer lastValues.Unlock()
+	value, found := lastValues.values[key]
+	if found {
+		logDebugf("Using the cached, possibly stale, value of %s.\n", key)
+	}
+	return value, found
+}
@@ -0,0 +1,94 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+// Get the upcoming events from Google Calendar. This uses the same OAuth 2.0 Client ID as the GMail integration, but the
+// Google Calendar API needs to be enabled for the project as well. The authentication is cached separately from the
+// GMail one, since it requires a different scope.
+
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"time"
+
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/option"
+)
+
+// Structure holding an upcoming calendar event.
+type CalendarEvent struct {
+	Title  string    // The title of the event
+	Start  time.Time // When the event starts
+	End    time.Time // When the event ends
+	AllDay bool      // Whether the event lasts all day, in which case only the date of Start is relevant
+}
+
+// Start a loop that gets the next few upcoming (or ongoing) events in a calendar.
+func CalendarStats(ctx context.Context, credentials string, calendarID string, count int, result chan []CalendarEvent) {
+	defer close(result)
+
+	key := cacheKey("calendar", credentials, calendarID, count)
+	if cached, found := cachedValue(key); found {
+		result <- cached.([]CalendarEvent)
+	}
+
+	configContent, err := ioutil.ReadFile(credentials)
+	if err != nil {
+		logErrorf("Failed to read credentials file %s: %v\n", credentials, err)
+		return
+	}
+	config, err := google.ConfigFromJSON(configContent, calendar.CalendarReadonlyScope)
+	if err != nil {
+		logError("Failed to create credentials from JSON:", err)
+		return
+	}
+
+	tokenSource := getTokenSource(config, configFilePath("calendar-token.json"), "")
+	if tokenSource == nil {
+		return
+	}
+
+	calendarService, err := calendar.NewService(context.Background(), option.WithTokenSource(tokenSource))
+	if err != nil {
+		logError("Failed to create calendar service:", err)
+		return
+	}
+
+	for {
+		events, err := calendarService.Events.List(calendarID).
+			ShowDeleted(false).
+			SingleEvents(true).
+			TimeMin(time.Now().Format(time.RFC3339)).
+			MaxResults(int64(count)).
+			OrderBy("startTime").
+			Do()
+		if err != nil {
+			logWarn("Failed to get calendar events:", err)
+		} else {
+			upcoming := make([]CalendarEvent, 0, len(events.Items))
+			for _, item := range events.Items {
+				event := CalendarEvent{Title: item.Summary}
+				if item.Start.DateTime != "" {
+					event.Start, _ = time.Parse(time.RFC3339, item.Start.DateTime)
+					event.End, _ = time.Parse(time.RFC3339, item.End.DateTime)
+				} else {
+					// All day events only have a date, in the local timezone.
+					event.AllDay = true
+					event.Start, _ = time.ParseInLocation("2006-01-02", item.Start.Date, time.Local)
+					event.End, _ = time.ParseInLocation("2006-01-02", item.End.Date, time.Local)
+				}
+				upcoming = append(upcoming, event)
+			}
+			cacheValue(key, upcoming)
+			result <- upcoming
+		}
+
+		select {
+		case <-time.After(5 * time.Minute):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+// Read commands controlling the screens from a stream, such as stdin. The commands are newline-delimited JSON objects,
+// e.g. {"cmd":"change_tag","screen":0,"tag":3}, and are translated into the same events the keyboard sends.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+)
+
+// The format of a command.
+type Command struct {
+	Cmd     string   `json:"cmd"`     // The command: change_tag, next_tag, previous_tag, brightness, timer_toggle, timer_reset, or timer_adjust
+	Screen  ScreenID `json:"screen"`  // Which screen the command is for
+	Tag     TagRef   `json:"tag"`     // The tag (number or name) to change to, for change_tag
+	Level   *uint8   `json:"level"`   // The brightness (0-255), for brightness
+	Minutes *int8    `json:"minutes"` // The number of minutes to add (or remove, if negative), for timer_adjust
+}
+
+// Translate a command into the event that the keyboard would send for it.
+// Returns false if the command is unknown or invalid.
+func (cmd Command) event() (Event, bool) {
+	event := Event{Screen: cmd.Screen}
+	switch cmd.Cmd {
+	case "change_tag":
+		tag, found := resolveTag(string(cmd.Tag))
+		if !found {
+			logWarnf("Unknown tag '%s' in command.\n", cmd.Tag)
+			return event, false
+		}
+		event.Event = ChangeTag
+		event.Params = []byte{tag}
+	case "next_tag":
+		event.Event = IncrementTag
+	case "previous_tag":
+		event.Event = DecrementTag
+	case "brightness":
+		if cmd.Level == nil {
+			logWarn("Missing level in brightness command.")
+			return event, false
+		}
+		event.Event = Brightness
+		event.Params = []byte{*cmd.Level}
+	case "timer_toggle":
+		event.Event = TimerToggle
+	case "timer_reset":
+		event.Event = TimerReset
+	case "timer_adjust":
+		if cmd.Minutes == nil {
+			logWarn("Missing minutes in timer_adjust command.")
+			return event, false
+		}
+		event.Event = TimerAdjust
+		event.Params = []byte{byte(*cmd.Minutes)}
+	default:
+		logWarnf("Unknown command '%s'.\n", cmd.Cmd)
+		return event, false
+	}
+	return event, true
+}
+
+// Start reading commands from a stream, one per line. Returns a channel receiving the resulting events, which is closed
+// when the stream ends.
+func ReadCommands(reader io.Reader) chan Event {
+	events := make(chan Event, 5)
+	go func() {
+		defer close(events)
+
+		scanner := bufio.NewScanner(reader)
+		for scanner.Scan() {
+			if len(scanner.Bytes()) < 1 {
+				continue
+			}
+
+			var cmd Command
+			if err := json.Unmarshal(scanner.Bytes(), &cmd); err != nil {
+				logWarnf("Invalid command '%s': %v\n", scanner.Text(), err)
+				continue
+			}
+			if event, ok := cmd.event(); ok {
+				events <- event
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			logError("Failed to read commands:", err)
+		}
+	}()
+	return events
+}
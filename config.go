@@ -0,0 +1,264 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+// Read the configuration file. The file is written in TOML, and is looked for in the local configuration directory
+// (e.g. ~/.config/oled-controller/config.toml on Linux, or %APPDATA%\oled-controller\config.toml on Windows). Each
+// setting has the same name as the corresponding command-line flag, and flags given on the command line take precedence
+// over the values in the file.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/kirsle/configdir"
+)
+
+// The name of the configuration file.
+const CONFIG_FILE = "config.toml"
+
+// A reference to a tag, which can be given either as a number or as a name in the configuration file.
+type TagRef string
+
+// Read a tag reference from the configuration file, accepting both numbers and strings.
+func (ref *TagRef) UnmarshalTOML(value interface{}) error {
+	switch value := value.(type) {
+	case int64:
+		*ref = TagRef(strconv.FormatInt(value, 10))
+	case string:
+		*ref = TagRef(value)
+	default:
+		return fmt.Errorf("expected a tag number or name, got %v", value)
+	}
+	return nil
+}
+
+// Read a tag reference from JSON, accepting both numbers and strings.
+func (ref *TagRef) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	switch value := value.(type) {
+	case float64:
+		*ref = TagRef(strconv.FormatFloat(value, 'f', -1, 64))
+	case string:
+		*ref = TagRef(value)
+	default:
+		return fmt.Errorf("expected a tag number or name, got %v", value)
+	}
+	return nil
+}
+
+// Struct containing the settings that can be put in the configuration file.
+type Config struct {
+	Debug               bool          `toml:"debug"`                 // Whether debugging is enabled
+	LogLevel            string        `toml:"log-level"`             // The lowest level of the messages to log.
+	LogJSON             bool          `toml:"log-json"`              // Whether to log the messages as JSON.
+	HIDReportSize       uint          `toml:"hid-report-size"`       // The size of the HID reports, in bytes.
+	ReadTimeout         time.Duration `toml:"read-timeout"`          // How long to wait for a message from the firmware in each read.
+	WriteRetries        uint          `toml:"write-retries"`         // How many times to retry writing a command to the device.
+	Columns             uint          `toml:"columns"`               // The number of columns of the screens, instead of what the firmware reports.
+	Rows                uint          `toml:"rows"`                  // The number of rows of the screens, instead of what the firmware reports.
+	Brightness          int           `toml:"brightness"`            // The brightness of the screens (0-255), or negative to leave it unchanged.
+	NightStart          string        `toml:"night-start"`           // The time at which to dim the screens for the night.
+	NightEnd            string        `toml:"night-end"`             // The time at which to stop dimming the screens.
+	NightBrightness     int           `toml:"night-brightness"`      // The brightness of the screens during the night.
+	EnabledTags         string        `toml:"enabled-tags"`          // The tags that can be shown.
+	MasterTag           TagRef        `toml:"master-tag"`            // The tag to show initially on the master screen.
+	SlaveTag            TagRef        `toml:"slave-tag"`             // The tag to show initially on the slave screen.
+	MasterRotate        string        `toml:"master-rotate"`         // The tags to cycle through on the master screen.
+	SlaveRotate         string        `toml:"slave-rotate"`          // The tags to cycle through on the slave screen.
+	LayerNames          string        `toml:"layer-names"`           // The names of the keyboard layers.
+	Mirror              bool          `toml:"mirror"`                // Whether the other screens show the same tag as the master screen.
+	RotateInterval      time.Duration `toml:"rotate-interval"`       // How long to show each tag when cycling through them.
+	BarChar             string        `toml:"bar-char"`              // The character filling the bars, possibly escape-coded.
+	BarLeft             string        `toml:"bar-left"`              // The character to the left of the bars, possibly escape-coded.
+	BarRight            string        `toml:"bar-right"`             // The character to the right of the bars, possibly escape-coded.
+	Smoothing           float64       `toml:"smoothing"`             // How much of the previous value to keep when smoothing the system and GPU bars.
+	TemperatureUnit     string        `toml:"temperature-unit"`      // The unit in which to display temperature (C, F, or K).
+	SysStatDisk         string        `toml:"sysstat-disk"`          // The name of the disk(s) for which to show I/O usage (Linux only)
+	SysStatInterval     time.Duration `toml:"sysstat-interval"`      // How often to get the system status.
+	CPUPerCore          bool          `toml:"cpu-per-core"`          // Whether to show the usage of each CPU core (Linux and Windows only)
+	CPUTempSensor       string        `toml:"cpu-temp-sensor"`       // The name of the hardware monitor giving the CPU temperature (Linux only)
+	CPUAlert            float64       `toml:"cpu-alert"`             // The CPU usage, in percent, at which to mark it.
+	CPUTempAlert        float64       `toml:"cpu-temp-alert"`        // The CPU temperature at which to mark it.
+	FanSensors          string        `toml:"fan-sensors"`           // The fans for which to show the speed (Linux only)
+	DiskHealthDevices   string        `toml:"disk-health-devices"`   // The drives for which to show the SMART health and temperature (Linux only)
+	FanMaxRPM           float64       `toml:"fan-max-rpm"`           // The fan speed, in RPM, that fills the bars.
+	HistoryMetric       string        `toml:"history-metric"`        // The metric to show over time.
+	DiskSpaceMounts     string        `toml:"diskspace-mounts"`      // The mount points for which to show disk space.
+	NetInterface        string        `toml:"net-interface"`         // The network interface for which to show throughput.
+	NetMaxMbps          float64       `toml:"net-max-mbps"`          // The network throughput, in Mbit/s, that fills the bars.
+	DockerSocket        string        `toml:"docker-socket"`         // The path to the socket of the Docker daemon.
+	PingHost            string        `toml:"ping-host"`             // The host to measure the round-trip time to.
+	ShowPublicIP        bool          `toml:"show-public-ip"`        // Whether to get the public IP address from an external service.
+	GPUVendor           string        `toml:"gpu-vendor"`            // The vendor of the graphics card (nvidia, amd, or intel).
+	GPUInterval         time.Duration `toml:"gpu-interval"`          // How often to get the status of the graphics card.
+	GPUAlert            float64       `toml:"gpu-alert"`             // The GPU usage, in percent, at which to mark it.
+	GPUTempAlert        float64       `toml:"gpu-temp-alert"`        // The GPU temperature at which to mark it.
+	TempTrend           bool          `toml:"temp-trend"`            // Whether to show arrows with the trend of the weather and GPU temperatures.
+	AlertRGB            bool          `toml:"alert-rgb"`             // Whether to show the alert state with the RGB underglow of the keyboard.
+	GmailCredentials    string        `toml:"gmail-credentials"`     // The path to the JSON credential file for GMail.
+	GmailLabel          string        `toml:"gmail-label"`           // The label for which to fetch the number of unread messages.
+	GmailInterval       time.Duration `toml:"gmail-interval"`        // How often to get the number of unread messages from GMail.
+	GmailToken          string        `toml:"gmail-token"`           // The path to the file caching the GMail OAuth token.
+	GmailAuthCode       string        `toml:"gmail-auth-code"`       // The authorization code to exchange for a GMail OAuth token.
+	CalendarID          string        `toml:"calendar-id"`           // The calendar for which to show the upcoming events.
+	IMAPServer          string        `toml:"imap-server"`           // The IMAP server, as <host>:<port>.
+	IMAPUser            string        `toml:"imap-user"`             // The user name to log in to the IMAP server with.
+	IMAPPassword        string        `toml:"imap-pass"`             // The password to log in to the IMAP server with.
+	IMAPMailbox         string        `toml:"imap-mailbox"`          // The mailbox for which to fetch the number of unread messages.
+	WeatherProvider     string        `toml:"weather-provider"`      // The provider of the current weather.
+	WeatherKey          string        `toml:"weather-api-key"`       // The openweathermap.org API key
+	WeatherLocation     string        `toml:"weather-location"`      // The location for which to get the current temperature.
+	WeatherLatitude     float64       `toml:"weather-lat"`           // The latitude of the location for which to get the current weather.
+	WeatherLongitude    float64       `toml:"weather-lon"`           // The longitude of the location for which to get the current weather.
+	WeatherInterval     time.Duration `toml:"weather-interval"`      // How often to get the current weather.
+	WeatherForecast     uint          `toml:"weather-forecast"`      // The number of forecast entries to show.
+	SpotifyClientID     string        `toml:"spotify-client-id"`     // The client ID of the Spotify app.
+	SpotifyClientSecret string        `toml:"spotify-client-secret"` // The client secret of the Spotify app.
+	TickerSymbols       string        `toml:"ticker-symbols"`        // The symbols of the coins or stocks for which to show prices.
+	TickerProvider      string        `toml:"ticker-provider"`       // The provider of the prices (coingecko or yahoo).
+	TickerRotation      time.Duration `toml:"ticker-rotation"`       // How long to show each price.
+	ClockFormat         string        `toml:"clock-format"`          // The format of the time shown by the clock.
+	ClockTimezone       string        `toml:"clock-timezone"`        // The second timezone shown by the clock, if any.
+	TimerLength         time.Duration `toml:"timer-length"`          // The length of the countdown timer.
+	MessageFile         string        `toml:"message-file"`          // The file with the messages to show.
+	MessageRotation     time.Duration `toml:"message-rotation"`      // How long to show each message.
+	RSSURL              string        `toml:"rss-url"`               // The URL of the feed to show headlines from.
+	RSSRotation         time.Duration `toml:"rss-rotation"`          // How long to show each headline.
+	SplashText          string        `toml:"splash-text"`           // Text to show on the screens while waiting for the tags to be drawn.
+	ClearOnExit         bool          `toml:"clear-on-exit"`         // Whether to clear the screens when stopping.
+	ExitText            string        `toml:"exit-text"`             // Text to show on the screens when stopping, instead of clearing them.
+	HTTPAddr            string        `toml:"http-addr"`             // The address on which to serve the controller status.
+	StdinControl        bool          `toml:"stdin-control"`         // Whether to read commands controlling the screens from stdin.
+	MQTTBroker          string        `toml:"mqtt-broker"`           // The MQTT broker to publish statistics to.
+	MQTTPrefix          string        `toml:"mqtt-prefix"`           // The prefix of the MQTT topics.
+	MQTTQoS             uint          `toml:"mqtt-qos"`              // The MQTT quality of service level (0, 1, or 2).
+}
+
+// The path of the configuration file.
+var configFile = filepath.Join(configdir.LocalConfig("oled-controller"), CONFIG_FILE)
+
+// Get the default configuration, which is used for the settings that aren't in the configuration file.
+func defaultConfig() Config {
+	mounts := "/"
+	if runtime.GOOS == "windows" {
+		mounts = `C:\`
+	}
+
+	return Config{
+		HIDReportSize:   DEFAULT_REPORT_SIZE,
+		ReadTimeout:     DEFAULT_READ_TIMEOUT,
+		WriteRetries:    DEFAULT_WRITE_RETRIES,
+		Brightness:      -1,
+		LogLevel:        "info",
+		MasterTag:       "1",
+		SlaveTag:        "2",
+		RotateInterval:  10 * time.Second,
+		ClearOnExit:     true,
+		BarChar:         `\x7F`,
+		BarLeft:         "[",
+		BarRight:        "]",
+		TemperatureUnit: "C",
+		SysStatDisk:     "sda",
+		SysStatInterval: 1 * time.Second,
+		DiskSpaceMounts: mounts,
+		NetMaxMbps:      100,
+		FanMaxRPM:       2000,
+		HistoryMetric:   "cpu",
+		DockerSocket:    "/var/run/docker.sock",
+		PingHost:        "8.8.8.8",
+		GPUVendor:       "nvidia",
+		GPUInterval:     1 * time.Second,
+		GmailLabel:      "INBOX",
+		GmailInterval:   1 * time.Minute,
+		CalendarID:      "primary",
+		WeatherProvider: OPEN_WEATHER_MAP,
+		WeatherInterval: 5 * time.Minute,
+		IMAPMailbox:     "INBOX",
+		TickerSymbols:   "bitcoin,ethereum",
+		TickerProvider:  "coingecko",
+		TickerRotation:  5 * time.Second,
+		ClockFormat:     "15:04:05",
+		TimerLength:     25 * time.Minute,
+		MessageRotation: 10 * time.Second,
+		RSSRotation:     15 * time.Second,
+		MQTTPrefix:      "oled-controller",
+	}
+}
+
+// Read the configuration file, if there is one. Settings not in the file get their default value.
+func readConfig() (Config, error) {
+	config := defaultConfig()
+	meta, err := toml.DecodeFile(configFile, &config)
+	if os.IsNotExist(err) {
+		return config, nil
+	} else if err != nil {
+		return config, fmt.Errorf("failed to read configuration file %s: %v", configFile, err)
+	}
+
+	for _, key := range meta.Undecoded() {
+		logWarnf("Unknown setting '%s' in configuration file %s.\n", key, configFile)
+	}
+	logInfo("Read configuration from", configFile)
+
+	return config, nil
+}
+
+// Load the configuration file, if there is one. The defaults are used if it can't be read.
+func loadConfig() Config {
+	config, err := readConfig()
+	if err != nil {
+		logError("Using the default configuration:", err)
+		return defaultConfig()
+	}
+	return config
+}
+
+// Read the configuration file again, and update the flags in the specified set that weren't given on the command
+// line. Nothing else may use the flags while they're being updated. The flags are left as they were if the file can't
+// be read, or if the new settings aren't valid.
+func reloadConfig(flags *flag.FlagSet) error {
+	loaded, err := readConfig()
+	if err != nil {
+		return err
+	}
+	config := reflect.ValueOf(loaded)
+
+	given := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) { given[f.Name] = true })
+	previous := make(map[string]string)
+	flags.VisitAll(func(f *flag.Flag) { previous[f.Name] = f.Value.String() })
+	restore := func() {
+		for name, value := range previous {
+			flags.Lookup(name).Value.Set(value)
+		}
+	}
+
+	for i := 0; i < config.NumField(); i++ {
+		name := config.Type().Field(i).Tag.Get("toml")
+		if f := flags.Lookup(name); f != nil && !given[name] {
+			if err := f.Value.Set(fmt.Sprint(config.Field(i).Interface())); err != nil {
+				restore()
+				return fmt.Errorf("bad setting '%s': %v", name, err)
+			}
+		}
+	}
+
+	if err := checkArgs(); err != nil {
+		restore()
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+// Tests of the configuration file.
+
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// Use a configuration file with the specified content during the test, restoring the file and the arguments
+// afterwards.
+func useConfigFile(t *testing.T, content string) {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), CONFIG_FILE)
+	if err := ioutil.WriteFile(file, []byte(content), 0600); err != nil {
+		t.Fatal("Failed to write the configuration file:", err)
+	}
+
+	real := configFile
+	configFile = file
+	previous := make(map[string]string)
+	testFlags.VisitAll(func(f *flag.Flag) { previous[f.Name] = f.Value.String() })
+	t.Cleanup(func() {
+		configFile = real
+		// Setting the values through the flag set would count them as given on the command line.
+		for name, value := range previous {
+			testFlags.Lookup(name).Value.Set(value)
+		}
+	})
+}
+
+// Reloading used to update the flags of the command line, rather than the ones given.
+func TestReloadConfig(t *testing.T) {
+	setFlag(t, "clock-format", "3:04PM")
+	useConfigFile(t, `
+smoothing = 0.5
+clock-format = "15:04"
+`)
+
+	if err := reloadConfig(testFlags); err != nil {
+		t.Fatal("Failed to reload the configuration:", err)
+	}
+	if *gArgs.smoothing != 0.5 {
+		t.Errorf("-smoothing is %g after reloading, want 0.5 from the configuration file", *gArgs.smoothing)
+	}
+	if *gArgs.clockFormat != "3:04PM" {
+		t.Errorf("-clock-format is '%s' after reloading, want '3:04PM' from the command line", *gArgs.clockFormat)
+	}
+}
+
+// Reloading a bad configuration used to exit the program.
+func TestReloadConfigInvalid(t *testing.T) {
+	tests := map[string]string{
+		"unreadable": "smoothing = ",
+		"bad type":   `smoothing = "much"`,
+		"bad value":  "mqtt-prefix = \"changed\"\nsmoothing = 2.0",
+	}
+
+	for name, content := range tests {
+		useConfigFile(t, content)
+		if err := reloadConfig(testFlags); err == nil {
+			t.Errorf("Reloading the %s configuration succeeded, want an error", name)
+		}
+		if *gArgs.smoothing != 0 || *gArgs.mqttPrefix != "oled-controller" {
+			t.Errorf("Reloading the %s configuration changed -smoothing to %g and -mqtt-prefix to '%s', want them kept",
+				name, *gArgs.smoothing, *gArgs.mqttPrefix)
+		}
+	}
+}
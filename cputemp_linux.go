@@ -0,0 +1,80 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+//go:build linux
+// +build linux
+
+// Get the temperature of the CPU from the hardware monitors in /sys/ (Linux edition)
+
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// The names of the hardware monitors of common CPUs, in order of preference.
+var CPU_TEMP_SENSORS = []string{"coretemp", "k10temp", "zenpower", "cpu_thermal"}
+
+// The labels of the inputs holding the temperature of the whole CPU package, for monitors with several inputs.
+var CPU_TEMP_LABELS = []string{"Package id 0", "Tdie", "Tctl"}
+
+// Read a file from the hardware monitor directory, with surrounding whitespace removed.
+func readHwmonFile(path string) string {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(content))
+}
+
+// Find the input of the hardware monitor that gives the CPU temperature. The monitor is autodetected, unless a name is
+// given. Returns an empty string if no monitor could be found.
+func FindCPUTemperatureSensor(name string) string {
+	monitors, _ := filepath.Glob("/sys/class/hwmon/hwmon*")
+
+	names := CPU_TEMP_SENSORS
+	if name != "" {
+		names = []string{name}
+	}
+
+	for _, monitorName := range names {
+		for _, monitor := range monitors {
+			if readHwmonFile(filepath.Join(monitor, "name")) != monitorName {
+				continue
+			}
+
+			// Prefer the package temperature, and fall back to the first input.
+			labels, _ := filepath.Glob(filepath.Join(monitor, "temp*_label"))
+			for _, want := range CPU_TEMP_LABELS {
+				for _, label := range labels {
+					if readHwmonFile(label) == want {
+						return strings.TrimSuffix(label, "_label") + "_input"
+					}
+				}
+			}
+			if input := filepath.Join(monitor, "temp1_input"); readHwmonFile(input) != "" {
+				return input
+			}
+		}
+	}
+
+	if name != "" {
+		logErrorf("Failed to find a temperature sensor named '%s'.\n", name)
+	} else {
+		logDebug("Failed to find a CPU temperature sensor.")
+	}
+	return ""
+}
+
+// Read the CPU temperature in degrees Celsius from the sensor input.
+func ReadCPUTemperature(sensor string) (float64, error) {
+	// The temperature is in millidegrees Celsius.
+	temp, err := strconv.ParseFloat(readHwmonFile(sensor), 64)
+	if err != nil {
+		return 0, err
+	}
+	return temp / 1000, nil
+}
@@ -0,0 +1,21 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+//go:build !linux
+// +build !linux
+
+// Get the temperature of the CPU (unsupported platform edition)
+
+package main
+
+import "errors"
+
+// Getting the CPU temperature is not supported on this platform, so there are no sensors.
+func FindCPUTemperatureSensor(name string) string {
+	return ""
+}
+
+// Getting the CPU temperature is not supported on this platform.
+func ReadCPUTemperature(sensor string) (float64, error) {
+	return 0, errors.New("not supported on this platform")
+}
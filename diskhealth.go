@@ -0,0 +1,22 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+// Get the temperature and SMART health of the drives. The platform specific parts are found in
+// diskhealth_<platform>.go
+
+package main
+
+type DiskHealthStatus byte // The type of the SMART health status of a drive.
+// The SMART health statuses.
+const (
+	HealthUnknown DiskHealthStatus = iota // The status couldn't be read, e.g. because of missing privileges.
+	HealthPassed                          // The drive passed its self-assessment.
+	HealthFailed                          // The drive failed its self-assessment, and is likely to fail soon.
+)
+
+// The type of a disk health result
+type DiskHealthResult struct {
+	Name        string           // The name of the drive, e.g. "sda" or "nvme0n1".
+	Temperature float64          // The temperature of the drive, in degrees Celsius, or NaN if it's unknown.
+	Health      DiskHealthStatus // The SMART health status of the drive.
+}
@@ -0,0 +1,128 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+//go:build linux
+// +build linux
+
+// Get the temperature and SMART health of the drives from smartctl, falling back to the hardware monitors in /sys/ for
+// the temperature when smartctl is missing or lacks privileges (Linux edition)
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The patterns of the drives shown when none are specified.
+var DISK_HEALTH_PATTERNS = []string{"/sys/block/sd*", "/sys/block/nvme*"}
+
+// The output of "smartctl --json", restricted to the parts that are used.
+type smartctlOutput struct {
+	SmartStatus *struct {
+		Passed bool `json:"passed"` // Whether the drive passed its self-assessment.
+	} `json:"smart_status"`
+	Temperature *struct {
+		Current float64 `json:"current"` // The current temperature, in degrees Celsius.
+	} `json:"temperature"`
+}
+
+// Find the names of the drives to show, either the specified ones (with or without "/dev/"), or all SATA and NVMe
+// drives.
+func findDisks(names []string) []string {
+	var disks []string
+	if len(names) > 0 {
+		for _, name := range names {
+			disks = append(disks, strings.TrimPrefix(name, "/dev/"))
+		}
+		return disks
+	}
+
+	for _, pattern := range DISK_HEALTH_PATTERNS {
+		paths, _ := filepath.Glob(pattern)
+		for _, path := range paths {
+			disks = append(disks, filepath.Base(path))
+		}
+	}
+	return disks
+}
+
+// Read the temperature of a drive from its hardware monitor, which exists for NVMe drives, and for SATA drives when
+// the drivetemp module is loaded. Returns NaN if there is none.
+func hwmonDiskTemperature(disk string) float64 {
+	for _, pattern := range []string{"device/hwmon*/temp1_input", "device/hwmon/hwmon*/temp1_input"} {
+		inputs, _ := filepath.Glob(filepath.Join("/sys/block", disk, pattern))
+		for _, input := range inputs {
+			if milli, err := strconv.ParseFloat(readHwmonFile(input), 64); err == nil {
+				return milli / 1000
+			}
+		}
+	}
+	return math.NaN()
+}
+
+// Get the health and temperature of a drive from smartctl. Returns false if smartctl couldn't read the drive, which
+// usually means that it's not installed, or that it's not run with enough privileges.
+func smartctlDiskHealth(ctx context.Context, disk string) (DiskHealthResult, bool) {
+	result := DiskHealthResult{Name: disk, Temperature: math.NaN()}
+
+	// The exit status is a bit mask, which is non-zero for a failing drive as well, so look at the output instead.
+	output, _ := exec.CommandContext(ctx, "smartctl", "--json", "-H", "-A", "/dev/"+disk).Output()
+	var smart smartctlOutput
+	if err := json.Unmarshal(output, &smart); err != nil || smart.SmartStatus == nil {
+		return result, false
+	}
+
+	result.Health = HealthFailed
+	if smart.SmartStatus.Passed {
+		result.Health = HealthPassed
+	}
+	if smart.Temperature != nil {
+		result.Temperature = smart.Temperature.Current
+	}
+	return result, true
+}
+
+// Run a loop that will continuously get the temperature and health of the drives with the specified names (or all of
+// them if there are no names), at the specified interval.
+func DiskHealthStats(ctx context.Context, names []string, interval time.Duration, results chan []DiskHealthResult) {
+	defer close(results)
+
+	disks := findDisks(names)
+	if len(disks) < 1 {
+		logError("Failed to find any drives.")
+		return
+	}
+
+	warned := false
+	for {
+		result := make([]DiskHealthResult, 0, len(disks))
+		for _, disk := range disks {
+			health, ok := smartctlDiskHealth(ctx, disk)
+			if !ok {
+				if !warned {
+					logWarnf("Failed to read the SMART status of %s; is smartctl installed, and run with enough "+
+						"privileges? Only showing the temperature.\n", disk)
+					warned = true
+				}
+				health.Temperature = hwmonDiskTemperature(disk)
+			} else if math.IsNaN(health.Temperature) {
+				health.Temperature = hwmonDiskTemperature(disk)
+			}
+			result = append(result, health)
+		}
+		results <- result
+
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
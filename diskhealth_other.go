@@ -0,0 +1,20 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+//go:build !linux
+// +build !linux
+
+// Get the temperature and SMART health of the drives (unsupported platform edition)
+
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// Getting the health of the drives is not supported on this platform.
+func DiskHealthStats(ctx context.Context, names []string, interval time.Duration, results chan []DiskHealthResult) {
+	defer close(results)
+	logError("Disk health is not supported on this platform.")
+}
@@ -0,0 +1,43 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+// Get how full the filesystems are. The platform specific parts are found in diskspace_<platform>.go
+
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// The type of a disk space result
+type DiskSpaceResult struct {
+	Mount string // The mount point (or drive) of the filesystem.
+	Total uint64 // The total size of the filesystem, in bytes.
+	Free  uint64 // The space available to the user, in bytes.
+}
+
+// Run a loop that will continuously get the disk space of the specified mount points, at the specified interval.
+// Mount points that can't be read are skipped.
+func DiskSpaceStats(ctx context.Context, mounts []string, interval time.Duration, results chan []DiskSpaceResult) {
+	defer close(results)
+
+	for {
+		var spaces []DiskSpaceResult
+		for _, mount := range mounts {
+			total, free, err := diskSpace(mount)
+			if err != nil {
+				logErrorf("Failed to get disk space of '%s': %v\n", mount, err)
+				continue
+			}
+			spaces = append(spaces, DiskSpaceResult{Mount: mount, Total: total, Free: free})
+		}
+		results <- spaces
+
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
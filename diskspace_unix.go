@@ -0,0 +1,20 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+//go:build linux || darwin
+// +build linux darwin
+
+// Get how full a filesystem is using statfs (Unix edition)
+
+package main
+
+import "syscall"
+
+// Get the total size and the space available to the user of the filesystem at the mount point, in bytes.
+func diskSpace(mount string) (total uint64, free uint64, err error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(mount, &stat); err != nil {
+		return 0, 0, err
+	}
+	return uint64(stat.Blocks) * uint64(stat.Bsize), uint64(stat.Bavail) * uint64(stat.Bsize), nil
+}
@@ -0,0 +1,21 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+// Get how full a filesystem is using GetDiskFreeSpaceEx (Windows edition)
+
+package main
+
+import "golang.org/x/sys/windows"
+
+// Get the total size and the space available to the user of the filesystem at the mount point (e.g. "C:\"), in bytes.
+func diskSpace(mount string) (total uint64, free uint64, err error) {
+	path, err := windows.UTF16PtrFromString(mount)
+	if err != nil {
+		return 0, 0, err
+	}
+	err = windows.GetDiskFreeSpaceEx(path, &free, &total, nil)
+	return total, free, err
+}
@@ -0,0 +1,98 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+// Get the status of the Docker containers, by talking with the Docker daemon over its Unix socket.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The state of a Docker container
+type DockerContainer struct {
+	Name  string // The name of the container.
+	State string // The state of the container, e.g. "running" or "exited".
+}
+
+// The type of a Docker result
+type DockerResult struct {
+	Running    int               // The number of running containers.
+	Containers []DockerContainer // All containers, with the running ones first.
+}
+
+// Get the status of all containers from the Docker daemon.
+func dockerContainers(client *http.Client) (DockerResult, error) {
+	// The host is ignored, since the client always connects to the socket.
+	resp, err := client.Get("http://docker/containers/json?all=1")
+	if err != nil {
+		return DockerResult{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return DockerResult{}, fmt.Errorf("%s (%d)", http.StatusText(resp.StatusCode), resp.StatusCode)
+	}
+
+	var containers []struct {
+		Names []string `json:"Names"`
+		State string   `json:"State"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&containers); err != nil {
+		return DockerResult{}, err
+	}
+
+	var result DockerResult
+	for _, container := range containers {
+		name := ""
+		if len(container.Names) > 0 {
+			name = strings.TrimPrefix(container.Names[0], "/")
+		}
+		if container.State == "running" {
+			result.Running++
+		}
+		result.Containers = append(result.Containers, DockerContainer{Name: name, State: container.State})
+	}
+	sort.SliceStable(result.Containers, func(i, j int) bool {
+		return result.Containers[i].State == "running" && result.Containers[j].State != "running"
+	})
+	return result, nil
+}
+
+// Start a loop that gets the status of the Docker containers at the specified interval. Stops if the Docker daemon
+// can't be reached.
+func DockerStats(ctx context.Context, socket string, interval time.Duration, result chan DockerResult) {
+	defer close(result)
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", socket)
+			},
+		},
+		Timeout: 5 * time.Second,
+	}
+
+	for {
+		containers, err := dockerContainers(client)
+		if err != nil {
+			logErrorf("Failed to get container status from %s: %v\n", socket, err)
+			return
+		}
+		result <- containers
+
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
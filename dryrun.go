@@ -0,0 +1,82 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+// Dry run, which draws each tag once and prints the result instead of sending it to the keyboard. Useful for checking
+// the data sources and the formatting of the tags without any hardware attached.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// How long to wait for a tag to produce its content, or to shut down.
+const DRY_RUN_TIMEOUT = 10 * time.Second
+
+// Draw each tag once in an area of the specified size, and print the lines to stdout.
+func DryRun(area Area) {
+	for _, id := range sortedTagIDs() {
+		dryRunTag(id, area)
+	}
+}
+
+// Draw a single tag once, print the lines, and stop it again. The first lines are often a placeholder shown while the
+// data is being fetched, so the lines printed are the first ones that differ from them, or the last ones drawn before
+// timing out.
+func dryRunTag(id uint8, area Area) {
+	results := make(chan []string, 5)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go tags[id].Draw(ctx, area, results)
+
+	var first, last []string
+	drawn, stopped := false, false
+	deadline := time.After(DRY_RUN_TIMEOUT)
+	for waiting := true; waiting; {
+		select {
+		case lines, more := <-results:
+			if !more {
+				stopped, waiting = true, false
+				break
+			}
+			if !drawn {
+				first, drawn = lines, true
+			}
+			last = lines
+			waiting = strings.Join(lines, "\n") == strings.Join(first, "\n")
+		case <-deadline:
+			waiting = false
+		}
+	}
+
+	fmt.Printf("Tag %d:\n", id)
+	if !drawn && stopped {
+		fmt.Println("  (nothing to draw)")
+	} else if !drawn {
+		fmt.Println("  (timed out)")
+	}
+	for _, line := range last {
+		fmt.Printf("  |%-*s|\n", int(area.Width), line)
+	}
+	if stopped {
+		return
+	}
+
+	// Stop the tag, and wait for it to finish.
+	cancel()
+	timeout := time.After(DRY_RUN_TIMEOUT)
+	for {
+		select {
+		case _, more := <-results:
+			if !more {
+				return
+			}
+		case <-timeout:
+			logWarnf("Tag %d did not stop in time.\n", id)
+			return
+		}
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+// Tests of the dry run.
+
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// A tag showing a placeholder while fetching its data, like the ones using external services.
+type fetchingTag struct{}
+
+func (*fetchingTag) Draw(ctx context.Context, area Area, results chan []string) {
+	defer close(results)
+	results <- []string{"Fetching..."}
+	select {
+	case <-time.After(50 * time.Millisecond):
+		results <- []string{"fetched"}
+	case <-ctx.Done():
+		return
+	}
+	<-ctx.Done()
+}
+
+// Run a dry run of a tag, returning what it printed.
+func dryRunOutput(t *testing.T, id uint8) string {
+	t.Helper()
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal("Failed to make a pipe:", err)
+	}
+	stdout := os.Stdout
+	os.Stdout = writer
+	defer func() { os.Stdout = stdout }()
+
+	dryRunTag(id, Area{Width: 21, Height: 4})
+	writer.Close()
+	output, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatal("Failed to read the output:", err)
+	}
+	return string(output)
+}
+
+// Only the placeholder used to be printed, so the data sources were never checked.
+func TestDryRunTag(t *testing.T) {
+	useTags(t, map[uint8]Tag{1: &fetchingTag{}})
+	if output := dryRunOutput(t, 1); !strings.Contains(output, "|fetched") || strings.Contains(output, "Fetching") {
+		t.Errorf("The dry run of a tag fetching its data printed %q, want only the fetched lines", output)
+	}
+}
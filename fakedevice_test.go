@@ -0,0 +1,249 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+// A fake HID device standing in for the keyboard in the tests, and the set up shared by them.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// The flags of the arguments used by the tests.
+var testFlags = flag.NewFlagSet("test", flag.ContinueOnError)
+
+// Set up the arguments with their default values before running the tests, since most of the code reads them.
+func TestMain(m *testing.M) {
+	defineFlags(testFlags, defaultConfig())
+	os.Exit(m.Run())
+}
+
+// Set an argument during the test, restoring its default value afterwards.
+func setFlag(t *testing.T, name string, value string) {
+	t.Helper()
+	if err := testFlags.Set(name, value); err != nil {
+		t.Fatalf("Failed to set -%s: %v", name, err)
+	}
+	t.Cleanup(func() { testFlags.Set(name, testFlags.Lookup(name).DefValue) })
+}
+
+// A fake HID device, which records the reports written to it, and returns queued reports when read.
+type fakeDevice struct {
+	mutex       sync.Mutex
+	written     [][]byte                     // The reports written to the device, in order.
+	writeErrors int                          // The number of writes that should fail before they succeed.
+	readError   error                        // The error to return when reading, if any.
+	respond     func(report []byte) [][]byte // Gives the reports to send back for each written report, if set.
+	reports     chan []byte                  // The reports to return when reading.
+	closed      bool                         // Whether the device has been closed.
+}
+
+// Create a fake device without any reports to read.
+func newFakeDevice() *fakeDevice {
+	return &fakeDevice{reports: make(chan []byte, 1024)}
+}
+
+func (dev *fakeDevice) Write(b []byte) (int, error) {
+	dev.mutex.Lock()
+	if dev.writeErrors > 0 {
+		dev.writeErrors--
+		dev.mutex.Unlock()
+		return 0, errors.New("fake write error")
+	}
+	dev.written = append(dev.written, append([]byte(nil), b...))
+	respond := dev.respond
+	dev.mutex.Unlock()
+
+	if respond != nil {
+		for _, report := range respond(b) {
+			// Like a real device, the responses are lost if nothing reads them, rather than blocking the writes.
+			select {
+			case dev.reports <- report:
+			default:
+			}
+		}
+	}
+	return len(b), nil
+}
+
+func (dev *fakeDevice) ReadTimeout(b []byte, timeout int) (int, error) {
+	dev.mutex.Lock()
+	err := dev.readError
+	dev.mutex.Unlock()
+	if err != nil {
+		return 0, err
+	}
+
+	select {
+	case report := <-dev.reports:
+		return copy(b, report), nil
+	case <-time.After(time.Duration(timeout) * time.Millisecond):
+		return 0, nil
+	}
+}
+
+func (dev *fakeDevice) SetNonblocking(nonblocking bool) error {
+	return nil
+}
+
+func (dev *fakeDevice) Close() error {
+	dev.mutex.Lock()
+	defer dev.mutex.Unlock()
+	dev.closed = true
+	return nil
+}
+
+// Queue a report to be returned when reading from the device.
+func (dev *fakeDevice) Queue(report []byte) {
+	dev.reports <- report
+}
+
+// Make reads from the device fail with the specified error, or succeed again if it's nil.
+func (dev *fakeDevice) SetReadError(err error) {
+	dev.mutex.Lock()
+	defer dev.mutex.Unlock()
+	dev.readError = err
+}
+
+// Get the reports written to the device so far.
+func (dev *fakeDevice) Written() [][]byte {
+	dev.mutex.Lock()
+	defer dev.mutex.Unlock()
+	return append([][]byte(nil), dev.written...)
+}
+
+// Get the reports written to the device so far, with the specified command.
+func (dev *fakeDevice) WrittenCommands(cmd CommandID) [][]byte {
+	var reports [][]byte
+	for _, report := range dev.Written() {
+		if report[0] == CommandMsg && CommandID(report[1]) == cmd {
+			reports = append(reports, report)
+		}
+	}
+	return reports
+}
+
+// Create a report with a response from the firmware.
+func responseReport(success bool, cmd CommandID, screen ScreenID, params ...byte) []byte {
+	report := make([]byte, DEFAULT_REPORT_SIZE)
+	if success {
+		report[0] = Success
+	} else {
+		report[0] = Failure
+	}
+	report[1] = byte(cmd)
+	report[2] = byte(screen)
+	copy(report[3:], params)
+	return report
+}
+
+// Create a report with an event from the firmware.
+func eventReport(event EventID, screen ScreenID, params ...byte) []byte {
+	report := make([]byte, DEFAULT_REPORT_SIZE)
+	report[0] = EventMsg
+	report[1] = byte(event)
+	report[2] = byte(screen)
+	copy(report[3:], params)
+	return report
+}
+
+// Get a responder acting like firmware with the specified number of screens of the specified size, which answers every
+// command successfully.
+func keyboardResponder(size Area, screens uint8) func(report []byte) [][]byte {
+	return func(report []byte) [][]byte {
+		if report[0] != CommandMsg {
+			return nil
+		}
+		cmd, screen := CommandID(report[1]), ScreenID(report[2])
+		if cmd == SetUp {
+			return [][]byte{responseReport(true, cmd, screen, size.Width, size.Height, screens)}
+		}
+		return [][]byte{responseReport(true, cmd, screen)}
+	}
+}
+
+// A tag drawing the same lines over and over at the specified interval, or once if it's zero.
+type fakeTag struct {
+	lines    []string      // The lines to draw.
+	interval time.Duration // How often to draw them again.
+}
+
+func (tag *fakeTag) Draw(ctx context.Context, area Area, results chan []string) {
+	defer close(results)
+	for {
+		select {
+		case results <- tag.lines:
+		case <-ctx.Done():
+			return
+		}
+		if tag.interval <= 0 {
+			<-ctx.Done()
+			return
+		}
+		select {
+		case <-time.After(tag.interval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Use the specified tags instead of the real ones during the test, so that no real data sources are started.
+func useTags(t *testing.T, fake map[uint8]Tag) {
+	real := tags
+	setTags(fake)
+	t.Cleanup(func() { setTags(real) })
+}
+
+// Start running an OLED controller with the fake device, which should act like a keyboard. Returns a function stopping
+// the controller, which fails the test if it doesn't stop in time, and returns whether the controller asked for the
+// configuration to be reloaded.
+func runController(t *testing.T, dev *fakeDevice) (*OLEDController, func() bool) {
+	oled := &OLEDController{
+		Device:      dev,
+		ReadTimeout: 10 * time.Millisecond,
+		signals:     make(chan os.Signal, 1),
+	}
+	done := make(chan bool)
+	var reload bool
+	go func() {
+		defer close(done)
+		reload = oled.Run()
+	}()
+
+	// Wait for the screens to be set up.
+	waitFor(t, "the screens to be set up", func() bool {
+		oled.mutex.Lock()
+		defer oled.mutex.Unlock()
+		return len(oled.Screens) > 0
+	})
+
+	return oled, func() bool {
+		oled.signals <- syscall.SIGHUP
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("The controller didn't stop in time.")
+		}
+		return reload
+	}
+}
+
+// Wait until the condition is true, failing the test if it takes too long.
+func waitFor(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s.", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,12 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+// Get the speed of the system fans. The platform specific parts are found in fanstats_<platform>.go
+
+package main
+
+// The type of a fan result
+type FanResult struct {
+	Name string  // The name of the fan, which is its label if it has one.
+	RPM  float64 // The speed of the fan, in revolutions per minute.
+}
@@ -0,0 +1,85 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+//go:build linux
+// +build linux
+
+// Get the speed of the system fans from the hardware monitors in /sys/ (Linux edition)
+
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A fan input of a hardware monitor.
+type fanInput struct {
+	name  string // The name of the fan, which is its label if it has one, or <monitor>/fan<N> otherwise.
+	input string // The path to the file holding the speed.
+}
+
+// Find the fan inputs of all hardware monitors, or only the ones with the specified names (either the label or
+// <monitor>/fan<N>, e.g. "nct6775/fan2").
+func findFanInputs(names []string) []fanInput {
+	inputs, _ := filepath.Glob("/sys/class/hwmon/hwmon*/fan*_input")
+
+	var fans []fanInput
+	for _, input := range inputs {
+		base := strings.TrimSuffix(input, "_input")
+		id := readHwmonFile(filepath.Join(filepath.Dir(input), "name")) + "/" + filepath.Base(base)
+		fan := fanInput{name: readHwmonFile(base + "_label"), input: input}
+		if fan.name == "" {
+			fan.name = id
+		}
+
+		if len(names) > 0 {
+			found := false
+			for _, name := range names {
+				if name == fan.name || name == id {
+					found = true
+					break
+				}
+			}
+			if !found {
+				continue
+			}
+		}
+		fans = append(fans, fan)
+	}
+	return fans
+}
+
+// Run a loop that will continuously get the speed of the fans with the specified names (or all of them if there are
+// no names), at the specified interval.
+func FanStats(ctx context.Context, names []string, interval time.Duration, results chan []FanResult) {
+	defer close(results)
+
+	fans := findFanInputs(names)
+	if len(fans) < 1 {
+		logError("Failed to find any fans.")
+		return
+	}
+
+	for {
+		result := make([]FanResult, 0, len(fans))
+		for _, fan := range fans {
+			rpm, err := strconv.ParseFloat(readHwmonFile(fan.input), 64)
+			if err != nil {
+				logErrorf("Failed to get the speed of fan %s: %v\n", fan.name, err)
+				continue
+			}
+			result = append(result, FanResult{Name: fan.name, RPM: rpm})
+		}
+		results <- result
+
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
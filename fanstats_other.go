@@ -0,0 +1,20 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+//go:build !linux
+// +build !linux
+
+// Get the speed of the system fans (unsupported platform edition)
+
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// Getting the speed of the fans is not supported on this platform.
+func FanStats(ctx context.Context, names []string, interval time.Duration, results chan []FanResult) {
+	defer close(results)
+	logError("System fans are not supported on this platform.")
+}
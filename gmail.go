@@ -4,7 +4,8 @@
 // Get status for GMail. This requires some work on your part. Register a project on console.developers.google.com,
 // then give access to that project to use GMail, and lastly create an OAuth 2.0 Client ID. The resulting JSON-file can be
 // given as an argument to the program, and the first time you will be prompted with an URL to visit, and a token to
-// fill in after logging in on the site.
+// fill in after logging in on the site. Without a terminal, the token can be given with a flag or environment variable
+// instead.
 
 package main
 
@@ -13,7 +14,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"log"
 	"os"
 	"path/filepath"
 	"time"
@@ -25,29 +25,75 @@ import (
 	"google.golang.org/api/option"
 )
 
-// Get a GMail service
-func getService(config *oauth2.Config) *gmail.Service {
-	ctx := context.Background()
+// The environment variables that can be used instead of the -gmail-auth-code flag, and to give a token obtained
+// elsewhere (as JSON), e.g. when running in a container.
+const (
+	GMAIL_AUTH_CODE_ENV = "OLED_GMAIL_AUTH_CODE"
+	GMAIL_TOKEN_ENV     = "OLED_GMAIL_TOKEN"
+)
 
+// Get the path of a file in the configuration directory, which is created if needed.
+// Returns an empty string if the directory can't be created.
+func configFilePath(name string) string {
 	configDir := configdir.LocalConfig("oled-controller")
-	err := configdir.MakePath(configDir)
-	if err != nil {
-		log.Printf("Failed to create configuration path %s: %v\n", configDir, err)
+	if err := configdir.MakePath(configDir); err != nil {
+		logErrorf("Failed to create configuration path %s: %v\n", configDir, err)
+		return ""
+	}
+	return filepath.Join(configDir, name)
+}
+
+// Get a source of OAuth tokens, which are cached in the specified file.
+// If there is no cached token, the authorization code is exchanged for one, or the user is prompted to authenticate if
+// no code is given.
+func getTokenSource(config *oauth2.Config, tokenFile string, authCode string) oauth2.TokenSource {
+	if tokenFile == "" {
 		return nil
 	}
-	tokenFile := filepath.Join(configDir, "token.json")
 	token, err := getTokenFromFile(tokenFile)
 	if err != nil {
-		token := getTokenFromWeb(config)
+		if authCode != "" {
+			token = exchangeAuthCode(config, authCode)
+		} else {
+			token = getTokenFromWeb(config)
+		}
 		if token == nil {
 			return nil
 		}
 		saveTokenToFile(tokenFile, token)
 	}
 
-	gmailService, err := gmail.NewService(ctx, option.WithTokenSource(config.TokenSource(ctx, token)))
+	return config.TokenSource(context.Background(), token)
+}
+
+// Get a GMail service
+func getService(config *oauth2.Config) *gmail.Service {
+	var tokenSource oauth2.TokenSource
+	if injected := os.Getenv(GMAIL_TOKEN_ENV); injected != "" {
+		token := &oauth2.Token{}
+		if err := json.Unmarshal([]byte(injected), token); err != nil {
+			logErrorf("Failed to parse the token in %s: %v\n", GMAIL_TOKEN_ENV, err)
+			return nil
+		}
+		tokenSource = config.TokenSource(context.Background(), token)
+	} else {
+		tokenFile := *gArgs.gmailToken
+		if tokenFile == "" {
+			tokenFile = configFilePath("token.json")
+		}
+		authCode := *gArgs.gmailAuthCode
+		if authCode == "" {
+			authCode = os.Getenv(GMAIL_AUTH_CODE_ENV)
+		}
+		tokenSource = getTokenSource(config, tokenFile, authCode)
+	}
+	if tokenSource == nil {
+		return nil
+	}
+
+	gmailService, err := gmail.NewService(context.Background(), option.WithTokenSource(tokenSource))
 	if err != nil {
-		log.Println("Failed to create GMail service:", err)
+		logError("Failed to create GMail service:", err)
 		return nil
 	}
 
@@ -62,13 +108,17 @@ func getTokenFromWeb(config *oauth2.Config) *oauth2.Token {
 	fmt.Print("Code: ")
 	var authCode string
 	if _, err := fmt.Scan(&authCode); err != nil {
-		log.Println("Failed to read code:", err)
+		logError("Failed to read code:", err)
 		return nil
 	}
+	return exchangeAuthCode(config, authCode)
+}
 
+// Exchange an authorization code, shown after authenticating on the web, for an API token.
+func exchangeAuthCode(config *oauth2.Config, authCode string) *oauth2.Token {
 	token, err := config.Exchange(context.TODO(), authCode)
 	if err != nil {
-		log.Println("Failed to exchange OAuth token:", err)
+		logError("Failed to exchange OAuth token:", err)
 		return nil
 	}
 	return token
@@ -91,7 +141,7 @@ func saveTokenToFile(file string, token *oauth2.Token) {
 	fmt.Printf("Saving credentials to file '%s'\n", file)
 	f, err := os.OpenFile(file, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
 	if err != nil {
-		log.Println("Failed to cache OAuth token:", err)
+		logWarn("Failed to cache OAuth token:", err)
 		return
 	}
 	defer f.Close()
@@ -99,17 +149,22 @@ func saveTokenToFile(file string, token *oauth2.Token) {
 }
 
 // Start a loop that gets the count of unread messages for a specific label.
-func GmailStats(credentials string, label string, result chan int64, quit chan bool) {
+func GmailStats(ctx context.Context, credentials string, label string, interval time.Duration, result chan int64) {
 	defer close(result)
 
+	key := cacheKey("gmail", credentials, label)
+	if cached, found := cachedValue(key); found {
+		result <- cached.(int64)
+	}
+
 	configContent, err := ioutil.ReadFile(credentials)
 	if err != nil {
-		log.Printf("Failed to read credentials file %s: %v\n", credentials, err)
+		logErrorf("Failed to read credentials file %s: %v\n", credentials, err)
 		return
 	}
 	config, err := google.ConfigFromJSON(configContent, gmail.GmailLabelsScope)
 	if err != nil {
-		log.Println("Failed to create credentials from JSON:", err)
+		logError("Failed to create credentials from JSON:", err)
 		return
 	}
 
@@ -122,15 +177,53 @@ func GmailStats(credentials string, label string, result chan int64, quit chan b
 	for {
 		label, err := gmailService.Users.Labels.Get(user, label).Do()
 		if err != nil {
-			log.Println("Failed to get unread message count:", err)
+			logWarn("Failed to get unread message count:", err)
 		} else {
+			cacheValue(key, label.MessagesUnread)
 			result <- label.MessagesUnread
 		}
 
 		select {
-		case <-time.After(1 * time.Minute):
-		case <-quit:
+		case <-time.After(interval):
+		case <-ctx.Done():
 			return
 		}
 	}
 }
+
+// Start getting the number of unread mails from GMail, or from the IMAP server, whichever has been configured. The
+// number is shared with everything else getting it. Returns false, without starting anything, if neither has.
+func StartMailStats(ctx context.Context, result chan int64) bool {
+	var key string
+	var start func(ctx context.Context, result chan int64)
+	if credentials, label, interval := *gArgs.gmailCredentials, *gArgs.gmailLabel, *gArgs.gmailInterval; credentials != "" {
+		key = cacheKey("gmail", credentials, label, interval)
+		start = func(ctx context.Context, result chan int64) {
+			GmailStats(ctx, credentials, label, interval, result)
+		}
+	} else if server := *gArgs.imapServer; server != "" {
+		user, password, mailbox := *gArgs.imapUser, *gArgs.imapPassword, *gArgs.imapMailbox
+		key = cacheKey("imap", server, user, password, mailbox)
+		start = func(ctx context.Context, result chan int64) {
+			IMAPStats(ctx, server, user, password, mailbox, result)
+		}
+	} else {
+		return false
+	}
+
+	go func() {
+		defer close(result)
+
+		values := subscribe(ctx, key, func(ctx context.Context, values chan interface{}) {
+			counts := make(chan int64, 5)
+			go start(ctx, counts)
+			for count := range counts {
+				values <- count
+			}
+		})
+		for value := range values {
+			result <- value.(int64)
+		}
+	}()
+	return true
+}
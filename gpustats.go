@@ -0,0 +1,62 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+// Get status of the graphics card. The vendor specific parts are found in nvidia.go, amd_<platform>.go, and
+// intel_<platform>.go
+
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// The type of a graphic card result
+type GraphicCardResult struct {
+	Temperature  float64 // The temperature in the desired unit.
+	FanSpeed     float64 // The intended fan speed in percent (0-1).
+	GPU          float64 // The GPU utilization in percent (0-1).
+	Memory       float64 // The memory utilization in percent (0-1).
+	MemoryUsed   float64 // The amount of memory used in bytes, or zero if unknown.
+	MemoryTotal  float64 // The total amount of memory in bytes, or zero if unknown.
+	Encoder      float64 // The encoder utilization in percent (0-1).
+	Decoder      float64 // The decoder utilization in percent (0-1).
+	PCIBandwidth float64 // The PCIe bandwidth utilization in percent (0-1).
+	Frequency    float64 // The current GPU clock frequency in MHz, or zero if unknown.
+	Power        float64 // The current power draw in watts, or zero if unknown.
+	PowerLimit   float64 // The power limit in watts, or zero if unknown.
+}
+
+// Run a loop that will continuously get status from the graphics card of the selected vendor, at the specified
+// interval. The status is shared with everything else getting it at the same interval.
+func GraphicCardStats(ctx context.Context, interval time.Duration, unit string, results chan GraphicCardResult) {
+	defer close(results)
+
+	key := cacheKey("gpustats", interval, unit, *gArgs.gpuVendor)
+	values := subscribe(ctx, key, func(ctx context.Context, values chan interface{}) {
+		stats := make(chan GraphicCardResult, 5)
+		go graphicCardStats(ctx, interval, unit, stats)
+		for stat := range stats {
+			values <- stat
+		}
+	})
+	for value := range values {
+		results <- value.(GraphicCardResult)
+	}
+}
+
+// Get status from the graphics card of the selected vendor.
+func graphicCardStats(ctx context.Context, interval time.Duration, unit string, results chan GraphicCardResult) {
+	switch strings.ToLower(*gArgs.gpuVendor) {
+	case "amd":
+		AMDStats(ctx, interval, unit, results)
+	case "intel":
+		IntelStats(ctx, interval, unit, results)
+	case "nvidia":
+		NvidiaStats(ctx, interval, unit, results)
+	default:
+		logErrorf("Unknown graphics card vendor '%s'.\n", *gArgs.gpuVendor)
+		close(results)
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+// Get the values of a single metric over time, taken from one of the other data sources.
+
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// The metrics that can be followed over time, and their labels.
+var HISTORY_METRICS = map[string]string{
+	"cpu": "CPU", // The total CPU usage.
+	"mem": "Mem", // The memory usage.
+	"gpu": "GPU", // The usage of the graphics card.
+	"net": "Net", // The network throughput (received and sent), relative to -net-max-mbps.
+}
+
+// Run a loop that will continuously get the value of a metric, as a fraction (0.0-1.0) of its maximum. The values come
+// at the interval of the underlying data source.
+func HistoryStats(ctx context.Context, metric string, results chan float64) {
+	defer close(results)
+
+	switch metric {
+	case "cpu", "mem":
+		sysStat := make(chan SystemStatsResult, 5)
+		go SystemStats(ctx, *gArgs.sysStatInterval, sysStat)
+
+		index := 0
+		if metric == "mem" {
+			index = 1
+		}
+		for result := range sysStat {
+			results <- result.Values[index]
+		}
+	case "gpu":
+		gpuStats := make(chan GraphicCardResult, 5)
+		go GraphicCardStats(ctx, *gArgs.gpuInterval, *gArgs.temperatureUnit, gpuStats)
+		for result := range gpuStats {
+			results <- result.GPU
+		}
+	case "net":
+		netStats := make(chan NetworkResult, 5)
+		go NetworkStats(ctx, *gArgs.netInterface, 1*time.Second, netStats)
+
+		maxRate := *gArgs.netMaxMbps * 1000 * 1000 / 8 // In bytes per second.
+		for result := range netStats {
+			results <- (result.Received + result.Sent) / maxRate
+		}
+	default:
+		logErrorf("Unknown history metric '%s'.\n", metric)
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+// Get the number of unread messages from an IMAP server. The connection is encrypted either with implicit TLS (port
+// 993), or with STARTTLS if the server supports it.
+
+package main
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+)
+
+// Connect and log in to an IMAP server, given as <host>:<port>.
+func imapConnect(server string, user string, password string) (*client.Client, error) {
+	_, port, err := net.SplitHostPort(server)
+	if err != nil {
+		return nil, err
+	}
+
+	var c *client.Client
+	if port == "993" {
+		c, err = client.DialTLS(server, nil)
+	} else {
+		c, err = client.Dial(server)
+		if err == nil {
+			if startTLS, _ := c.SupportStartTLS(); startTLS {
+				err = c.StartTLS(nil)
+			} else {
+				logWarn("IMAP server doesn't support STARTTLS. Logging in without encryption.")
+			}
+		}
+	}
+	if err != nil {
+		if c != nil {
+			c.Logout()
+		}
+		return nil, err
+	}
+
+	if err := c.Login(user, password); err != nil {
+		c.Logout()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Start a loop that gets the count of unread messages in a specific mailbox.
+func IMAPStats(ctx context.Context, server string, user string, password string, mailbox string, result chan int64) {
+	defer close(result)
+
+	key := cacheKey("imap", server, user, mailbox)
+	if cached, found := cachedValue(key); found {
+		result <- cached.(int64)
+	}
+
+	var c *client.Client
+	defer func() {
+		if c != nil {
+			c.Logout()
+		}
+	}()
+
+	for {
+		var err error
+		if c == nil {
+			c, err = imapConnect(server, user, password)
+			if err != nil {
+				logWarnf("Failed to connect to IMAP server %s: %v\n", server, err)
+			}
+		}
+
+		if c != nil {
+			status, err := c.Status(mailbox, []imap.StatusItem{imap.StatusUnseen})
+			if err != nil {
+				// The connection has probably been dropped. Reconnect next time.
+				logWarn("Failed to get unread message count:", err)
+				c.Logout()
+				c = nil
+			} else {
+				cacheValue(key, int64(status.Unseen))
+				result <- int64(status.Unseen)
+			}
+		}
+
+		select {
+		case <-time.After(1 * time.Minute):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+//go:build linux
+// +build linux
+
+// Get status of the first Intel integrated graphics card. Uses the i915 driver's files in /sys/ (Linux edition)
+
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The sysfs directory of the Intel graphics card.
+const INTEL_CARD_PATH = "/sys/class/drm/card0"
+
+// Read a file from the card directory containing a single number.
+func readIntelValue(name string) (float64, error) {
+	content, err := ioutil.ReadFile(filepath.Join(INTEL_CARD_PATH, name))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(strings.TrimSpace(string(content)), 64)
+}
+
+// Find the file holding the time spent in the RC6 (idle) state, which moved with multi-tile support.
+func intelRC6File() string {
+	for _, name := range []string{"gt/gt0/rc6_residency_ms", "power/rc6_residency_ms"} {
+		if _, err := readIntelValue(name); err == nil {
+			return name
+		}
+	}
+	return ""
+}
+
+// Run a loop that will continuously get status from the Intel graphics card, at the specified interval.
+// The GPU utilization is the time not spent idling, and the card has no dedicated memory, encoder/decoder counters, or
+// PCIe link, so those are left at zero.
+func IntelStats(ctx context.Context, interval time.Duration, unit string, results chan GraphicCardResult) {
+	defer close(results)
+
+	if _, err := readIntelValue("gt_max_freq_mhz"); err != nil {
+		logError("Failed to find Intel device:", err)
+		return
+	}
+
+	rc6File := intelRC6File()
+	if rc6File == "" {
+		logError("Failed to find the idle residency of the Intel device. GPU utilization will not be shown.")
+	}
+
+	// Newer cards have their own hardware monitor, but the integrated ones usually don't.
+	var sensors string
+	if hwmon, _ := filepath.Glob(filepath.Join(INTEL_CARD_PATH, "device", "hwmon", "hwmon*")); len(hwmon) > 0 {
+		sensors, _ = filepath.Rel(INTEL_CARD_PATH, hwmon[0])
+	}
+
+	var lastIdle float64
+	var lastTime time.Time
+	for {
+		start := time.Now()
+		var result GraphicCardResult
+
+		if rc6File != "" {
+			if idle, err := readIntelValue(rc6File); err != nil {
+				logError("Failed to get GPU utilization:", err)
+			} else {
+				if elapsed := float64(start.Sub(lastTime).Milliseconds()); !lastTime.IsZero() && elapsed > 0 {
+					result.GPU = 1 - (idle-lastIdle)/elapsed
+				}
+				lastIdle, lastTime = idle, start
+			}
+		}
+
+		if freq, err := readIntelValue("gt_act_freq_mhz"); err != nil {
+			logError("Failed to get GPU frequency:", err)
+		} else {
+			result.Frequency = freq
+		}
+
+		// The temperature is in millidegrees Celsius.
+		if sensors != "" {
+			if temp, err := readIntelValue(filepath.Join(sensors, "temp1_input")); err != nil {
+				logError("Failed to get temperature:", err)
+			} else {
+				result.Temperature = ConvertTemperature(temp/1000, unit)
+			}
+		}
+
+		results <- result
+
+		select {
+		case <-time.After(interval - time.Since(start)):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,20 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+//go:build !linux
+// +build !linux
+
+// Get status of the first Intel integrated graphics card (unsupported platform edition)
+
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// Getting status from Intel graphics cards is not supported on this platform.
+func IntelStats(ctx context.Context, interval time.Duration, unit string, results chan GraphicCardResult) {
+	defer close(results)
+	logError("Intel graphics cards are not supported on this platform.")
+}
@@ -0,0 +1,92 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+// Get the IPv4 address of the primary network interface, and optionally the public IP address as seen by an external
+// service (ipify.org).
+
+package main
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// The service returning the public IP address.
+const PUBLIC_IP_URL = "https://api.ipify.org?format=json"
+
+// How often to get the public IP address. It's also fetched again when the local address changes.
+const PUBLIC_IP_INTERVAL = 10 * time.Minute
+
+// The type of an IP information result
+type IPInfoResult struct {
+	LocalIP  string // The IPv4 address of the primary interface. Empty if there is no network.
+	PublicIP string // The public IP address. Empty if it's unknown or not requested.
+}
+
+// Get the IPv4 address of the interface that is used to reach the Internet.
+// No packets are sent; connecting a UDP socket only picks the route.
+func primaryIPv4() (string, error) {
+	conn, err := net.Dial("udp4", "8.8.8.8:53")
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP.String(), nil
+}
+
+// Get the public IP address from an external service.
+func publicIP(ctx context.Context) (string, error) {
+	var result struct {
+		IP string `json:"ip"`
+	}
+	if err := getJSON(ctx, PUBLIC_IP_URL, &result); err != nil {
+		return "", err
+	}
+	return result.IP, nil
+}
+
+// Start a loop that gets the IP address of the primary interface at the specified interval, and the public IP address
+// every ten minutes, if requested.
+func IPInfoStats(ctx context.Context, public bool, interval time.Duration, results chan IPInfoResult) {
+	defer close(results)
+
+	key := cacheKey("ipinfo", public)
+	if cached, found := cachedValue(key); found {
+		results <- cached.(IPInfoResult)
+	}
+
+	var result IPInfoResult
+	var nextPublic time.Time
+	for {
+		local, err := primaryIPv4()
+		if err != nil {
+			logDebug("Failed to get the local IP address:", err)
+			local = ""
+		}
+
+		if local == "" {
+			result.PublicIP = ""
+		} else if public && (local != result.LocalIP || time.Now().After(nextPublic)) {
+			if address, err := publicIP(ctx); err != nil {
+				logWarn("Failed to get the public IP address:", err)
+				if local != result.LocalIP {
+					result.PublicIP = "" // The old address is likely wrong on the new network.
+				}
+				nextPublic = time.Now().Add(interval) // Try again soon.
+			} else {
+				result.PublicIP = address
+				nextPublic = time.Now().Add(PUBLIC_IP_INTERVAL)
+			}
+		}
+		result.LocalIP = local
+		cacheValue(key, result)
+		results <- result
+
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
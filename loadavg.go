@@ -0,0 +1,33 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+// Get the load averages and uptime of the system. The platform specific parts are found in loadavg_<platform>.go
+
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// The type of a load average result
+type LoadAvgResult struct {
+	Load1, Load5, Load15 float64       // The load averages over the last 1, 5, and 15 minutes.
+	Uptime               time.Duration // How long the system has been running.
+}
+
+// Format an uptime compactly as days, hours, and minutes, leaving out the leading units that are zero.
+func formatUptime(uptime time.Duration) string {
+	days := int(uptime / (24 * time.Hour))
+	hours := int(uptime/time.Hour) % 24
+	minutes := int(uptime/time.Minute) % 60
+
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd %dh %dm", days, hours, minutes)
+	case hours > 0:
+		return fmt.Sprintf("%dh %dm", hours, minutes)
+	default:
+		return fmt.Sprintf("%dm", minutes)
+	}
+}
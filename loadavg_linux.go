@@ -0,0 +1,49 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+//go:build linux
+// +build linux
+
+// Get the load averages and uptime from /proc/ (Linux edition)
+
+package main
+
+import (
+	"context"
+	"time"
+
+	linuxproc "github.com/c9s/goprocinfo/linux"
+)
+
+// Run a loop that will continuously get the load averages and uptime, at the specified interval.
+func LoadAvgStats(ctx context.Context, interval time.Duration, results chan LoadAvgResult) {
+	defer close(results)
+
+	for {
+		var result LoadAvgResult
+
+		loadAvg, err := linuxproc.ReadLoadAvg("/proc/loadavg")
+		if err != nil {
+			logError("Failed to retrieve load average:", err)
+			return
+		}
+		result.Load1 = loadAvg.Last1Min
+		result.Load5 = loadAvg.Last5Min
+		result.Load15 = loadAvg.Last15Min
+
+		uptime, err := linuxproc.ReadUptime("/proc/uptime")
+		if err != nil {
+			logError("Failed to retrieve uptime:", err)
+		} else {
+			result.Uptime = time.Duration(uptime.Total * float64(time.Second))
+		}
+
+		results <- result
+
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,20 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+//go:build !linux
+// +build !linux
+
+// Get the load averages and uptime (unsupported platform edition)
+
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// Getting the load averages is not supported on this platform.
+func LoadAvgStats(ctx context.Context, interval time.Duration, results chan LoadAvgResult) {
+	defer close(results)
+	logError("Load averages are not supported on this platform.")
+}
@@ -0,0 +1,148 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+// Log messages at different levels, either as text through the standard logger, or as one JSON object per line (e.g.
+// {"time":"...","level":"warn","msg":"..."}) when running as a service.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+type LogLevel int // The type of a log level.
+// The log levels, in increasing order of severity.
+const (
+	LogDebug LogLevel = iota // Details that are only useful when debugging.
+	LogInfo                  // Changes of state, such as connecting to the device.
+	LogWarn                  // Failures that are recovered from, such as failing to write to the device.
+	LogError                 // Failures that make something stop working.
+)
+
+// Map from the name of a log level to the level.
+var LOG_LEVELS = map[string]LogLevel{
+	"debug": LogDebug,
+	"info":  LogInfo,
+	"warn":  LogWarn,
+	"error": LogError,
+}
+
+// The format of a log message in JSON.
+type logEntry struct {
+	Time    time.Time `json:"time"`  // When the message was logged.
+	Level   string    `json:"level"` // The level of the message.
+	Message string    `json:"msg"`   // The message.
+}
+
+// Lock making sure that JSON log messages aren't interleaved.
+var logMutex sync.Mutex
+
+// Get the name of a log level.
+func (level LogLevel) String() string {
+	for name, value := range LOG_LEVELS {
+		if value == level {
+			return name
+		}
+	}
+	return fmt.Sprintf("level%d", int(level))
+}
+
+// The log settings, which are copied from the flags by setLogSettings, so that messages can be logged while the
+// flags are being reloaded.
+var logSettings = struct {
+	sync.RWMutex
+	minimum LogLevel // The lowest level of the messages to log.
+	json    bool     // Whether to log one JSON object per message.
+}{minimum: LogInfo}
+
+// Copy the log settings from the flags, once they have been checked.
+func setLogSettings() {
+	minimum := LOG_LEVELS[strings.ToLower(*gArgs.logLevel)]
+	if *gArgs.debug {
+		minimum = LogDebug
+	}
+
+	logSettings.Lock()
+	defer logSettings.Unlock()
+	logSettings.minimum = minimum
+	logSettings.json = *gArgs.logJSON
+}
+
+// Get whether messages at the specified level should be logged.
+func logEnabled(level LogLevel) bool {
+	logSettings.RLock()
+	defer logSettings.RUnlock()
+	return level >= logSettings.minimum
+}
+
+// Log a message at the specified level. The message is only formatted if the level is enabled.
+func logOutput(level LogLevel, format func() string) {
+	if !logEnabled(level) {
+		return
+	}
+	message := strings.TrimSuffix(format(), "\n")
+
+	logSettings.RLock()
+	asJSON := logSettings.json
+	logSettings.RUnlock()
+
+	if asJSON {
+		if entry, err := json.Marshal(logEntry{Time: time.Now(), Level: level.String(), Message: message}); err == nil {
+			logMutex.Lock()
+			defer logMutex.Unlock()
+			log.Writer().Write(append(entry, '\n'))
+			return
+		}
+	}
+	log.Output(3, strings.ToUpper(level.String())+": "+message)
+}
+
+// Log a message at the debug level, with the arguments formatted like fmt.Println.
+func logDebug(v ...interface{}) { logOutput(LogDebug, func() string { return fmt.Sprintln(v...) }) }
+
+// Log a message at the debug level, with the arguments formatted like fmt.Printf.
+func logDebugf(format string, v ...interface{}) {
+	logOutput(LogDebug, func() string { return fmt.Sprintf(format, v...) })
+}
+
+// Log a message at the info level, with the arguments formatted like fmt.Println.
+func logInfo(v ...interface{}) { logOutput(LogInfo, func() string { return fmt.Sprintln(v...) }) }
+
+// Log a message at the info level, with the arguments formatted like fmt.Printf.
+func logInfof(format string, v ...interface{}) {
+	logOutput(LogInfo, func() string { return fmt.Sprintf(format, v...) })
+}
+
+// Log a message at the warn level, with the arguments formatted like fmt.Println.
+func logWarn(v ...interface{}) { logOutput(LogWarn, func() string { return fmt.Sprintln(v...) }) }
+
+// Log a message at the warn level, with the arguments formatted like fmt.Printf.
+func logWarnf(format string, v ...interface{}) {
+	logOutput(LogWarn, func() string { return fmt.Sprintf(format, v...) })
+}
+
+// Log a message at the error level, with the arguments formatted like fmt.Println.
+func logError(v ...interface{}) { logOutput(LogError, func() string { return fmt.Sprintln(v...) }) }
+
+// Log a message at the error level, with the arguments formatted like fmt.Printf.
+func logErrorf(format string, v ...interface{}) {
+	logOutput(LogError, func() string { return fmt.Sprintf(format, v...) })
+}
+
+// Log a message at the error level, with the arguments formatted like fmt.Println, and exit the program.
+func logFatal(v ...interface{}) {
+	logOutput(LogError, func() string { return fmt.Sprintln(v...) })
+	os.Exit(1)
+}
+
+// Log a message at the error level, with the arguments formatted like fmt.Printf, and exit the program.
+func logFatalf(format string, v ...interface{}) {
+	logOutput(LogError, func() string { return fmt.Sprintf(format, v...) })
+	os.Exit(1)
+}
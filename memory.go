@@ -0,0 +1,15 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+// Get a breakdown of the memory usage. The platform specific parts are found in memory_<platform>.go
+
+package main
+
+// The type of a memory result. All values are in bytes.
+type MemoryResult struct {
+	Total     float64 // The total amount of memory.
+	Used      float64 // The memory used by programs, excluding the cache and buffers.
+	Cached    float64 // The memory used by the cache and buffers.
+	SwapTotal float64 // The total amount of swap.
+	SwapUsed  float64 // The swap in use.
+}
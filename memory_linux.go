@@ -0,0 +1,47 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+//go:build linux
+// +build linux
+
+// Get a breakdown of the memory usage from /proc/meminfo (Linux edition)
+
+package main
+
+import (
+	"context"
+	"math"
+	"time"
+
+	linuxproc "github.com/c9s/goprocinfo/linux"
+)
+
+// Run a loop that will continuously get the memory usage, at the specified interval.
+func MemoryStats(ctx context.Context, interval time.Duration, results chan MemoryResult) {
+	defer close(results)
+
+	for {
+		meminfo, err := linuxproc.ReadMemInfo("/proc/meminfo")
+		if err != nil {
+			logError("Failed to retrieve meminfo information:", err)
+			return
+		}
+
+		// The values are in kB. Fields that are missing are zero, so make sure that nothing goes negative.
+		cached := float64(meminfo.Buffers + meminfo.Cached + meminfo.SReclaimable)
+		result := MemoryResult{
+			Total:     float64(meminfo.MemTotal) * 1024,
+			Used:      math.Max(float64(meminfo.MemTotal)-float64(meminfo.MemFree)-cached, 0) * 1024,
+			Cached:    cached * 1024,
+			SwapTotal: float64(meminfo.SwapTotal) * 1024,
+			SwapUsed:  math.Max(float64(meminfo.SwapTotal)-float64(meminfo.SwapFree), 0) * 1024,
+		}
+		results <- result
+
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
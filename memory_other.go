@@ -0,0 +1,20 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+//go:build !linux
+// +build !linux
+
+// Get a breakdown of the memory usage (unsupported platform edition)
+
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// Getting a breakdown of the memory usage is not supported on this platform.
+func MemoryStats(ctx context.Context, interval time.Duration, results chan MemoryResult) {
+	defer close(results)
+	logError("Memory breakdown is not supported on this platform.")
+}
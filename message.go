@@ -0,0 +1,50 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+// Get messages to show from a file, e.g. reminders or quotes, one per line. The file is read again when it changes.
+
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+)
+
+// Run a loop that will check the message file for changes at the specified interval, and get its non-empty lines
+// whenever it has changed.
+func MessageStats(ctx context.Context, file string, interval time.Duration, results chan []string) {
+	defer close(results)
+
+	var modified time.Time
+	for {
+		if info, err := os.Stat(file); err != nil {
+			logErrorf("Failed to check message file %s: %v\n", file, err)
+			return
+		} else if !info.ModTime().Equal(modified) {
+			modified = info.ModTime()
+
+			content, err := ioutil.ReadFile(file)
+			if err != nil {
+				logErrorf("Failed to read message file %s: %v\n", file, err)
+				return
+			}
+
+			var messages []string
+			for _, line := range strings.Split(string(content), "\n") {
+				if line = strings.TrimSpace(line); line != "" {
+					messages = append(messages, ToLatin(line))
+				}
+			}
+			results <- messages
+		}
+
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+// Publish the gathered statistics to an MQTT broker, e.g. for integration with Home Assistant. The statistics are
+// published regardless of what is shown on the screens, and even when no device is connected.
+
+package main
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// Publish a value to a topic below the prefix.
+func publish(client mqtt.Client, prefix string, qos byte, topic string, value interface{}) {
+	var payload string
+	switch value := value.(type) {
+	case float64:
+		payload = strconv.FormatFloat(value, 'f', -1, 64)
+	case int64:
+		payload = strconv.FormatInt(value, 10)
+	case string:
+		payload = value
+	}
+
+	token := client.Publish(prefix+"/"+topic, qos, false, payload)
+	if token.WaitTimeout(5*time.Second) && token.Error() != nil {
+		logWarnf("Failed to publish to %s: %v\n", topic, token.Error())
+	}
+}
+
+// Start publishing the statistics to the MQTT broker given with -mqtt-broker, if any. Returns a function stopping it,
+// which returns once it has stopped.
+func StartPublishing() func() {
+	if *gArgs.mqttBroker == "" {
+		return func() {}
+	}
+	broker, prefix, qos := *gArgs.mqttBroker, *gArgs.mqttPrefix, byte(*gArgs.mqttQoS)
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan bool)
+	go func() {
+		defer close(stopped)
+		PublishStats(ctx, broker, prefix, qos)
+	}()
+	return func() {
+		cancel()
+		<-stopped
+	}
+}
+
+// Connect to the MQTT broker, and keep publishing the statistics from the different sources until the context is
+// canceled. The sources are shared with the tags showing the same statistics.
+func PublishStats(ctx context.Context, broker string, prefix string, qos byte) {
+	opts := mqtt.NewClientOptions().
+		AddBroker(broker).
+		SetClientID("oled-controller").
+		SetAutoReconnect(true)
+	client := mqtt.NewClient(opts)
+	token := client.Connect()
+	select {
+	case <-token.Done():
+		if token.Error() != nil {
+			logErrorf("Failed to connect to MQTT broker %s: %v\n", broker, token.Error())
+			return
+		}
+	case <-ctx.Done():
+		return
+	}
+	defer client.Disconnect(250)
+
+	sysStats := make(chan SystemStatsResult, 5)
+	gpuStats := make(chan GraphicCardResult, 5)
+	weatherReport := make(chan WeatherResult, 5)
+	unreadMails := make(chan int64, 5)
+
+	go SystemStats(ctx, *gArgs.sysStatInterval, sysStats)
+	go GraphicCardStats(ctx, *gArgs.gpuInterval, *gArgs.temperatureUnit, gpuStats)
+	if !StartWeatherStats(ctx, weatherReport) {
+		close(weatherReport)
+	}
+	if !StartMailStats(ctx, unreadMails) {
+		close(unreadMails)
+	}
+
+	// A nil channel is never selected, so sources that have stopped are set to nil.
+	for sysStats != nil || gpuStats != nil || weatherReport != nil || unreadMails != nil {
+		select {
+		case result, more := <-sysStats:
+			if !more {
+				sysStats = nil
+				continue
+			}
+			// The topics are named after the labels, e.g. "CPU%" => "cpu", and "sda" => "sda".
+			for i, value := range result.Values {
+				topic := strings.ToLower(strings.TrimSuffix(result.Labels[i], "%"))
+				publish(client, prefix, qos, "system/"+topic, value)
+			}
+		case result, more := <-gpuStats:
+			if !more {
+				gpuStats = nil
+				continue
+			}
+			publish(client, prefix, qos, "gpu/temperature", result.Temperature)
+			publish(client, prefix, qos, "gpu/fan", result.FanSpeed)
+			publish(client, prefix, qos, "gpu/gpu", result.GPU)
+			publish(client, prefix, qos, "gpu/memory", result.Memory)
+			publish(client, prefix, qos, "gpu/encoder", result.Encoder)
+			publish(client, prefix, qos, "gpu/decoder", result.Decoder)
+			publish(client, prefix, qos, "gpu/pcie", result.PCIBandwidth)
+		case weather, more := <-weatherReport:
+			if !more {
+				weatherReport = nil
+				continue
+			}
+			publish(client, prefix, qos, "weather/temperature", weather.Temperature)
+			publish(client, prefix, qos, "weather/condition", strconv.Itoa(int(weather.Weather)))
+		case numUnread, more := <-unreadMails:
+			if !more {
+				unreadMails = nil
+				continue
+			}
+			publish(client, prefix, qos, "mail/unread", numUnread)
+		}
+	}
+}
@@ -0,0 +1,12 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+// Get the network throughput. The platform specific parts are found in netstats_<platform>.go
+
+package main
+
+// The type of a network result
+type NetworkResult struct {
+	Received float64 // The download rate in bytes per second.
+	Sent     float64 // The upload rate in bytes per second.
+}
@@ -0,0 +1,90 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+//go:build linux
+// +build linux
+
+// Get the network throughput from /sys/ (Linux edition)
+
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Get the total number of bytes received and sent on an interface, or on all interfaces except loopback if none is
+// specified.
+func readNetworkBytes(iface string) (rx uint64, tx uint64, err error) {
+	ifaces := []string{iface}
+	if iface == "" {
+		paths, _ := filepath.Glob("/sys/class/net/*")
+		ifaces = ifaces[:0]
+		for _, path := range paths {
+			if name := filepath.Base(path); name != "lo" {
+				ifaces = append(ifaces, name)
+			}
+		}
+	}
+
+	for _, name := range ifaces {
+		for _, stat := range []struct {
+			file  string
+			value *uint64
+		}{{"rx_bytes", &rx}, {"tx_bytes", &tx}} {
+			content, err := ioutil.ReadFile(filepath.Join("/sys/class/net", name, "statistics", stat.file))
+			if err != nil {
+				return 0, 0, err
+			}
+			value, err := strconv.ParseUint(strings.TrimSpace(string(content)), 10, 64)
+			if err != nil {
+				return 0, 0, err
+			}
+			*stat.value += value
+		}
+	}
+	return rx, tx, nil
+}
+
+// Run a loop that will continuously get the network throughput of an interface (or all interfaces if empty), at the
+// specified interval.
+func NetworkStats(ctx context.Context, iface string, interval time.Duration, results chan NetworkResult) {
+	defer close(results)
+
+	prevRx, prevTx, err := readNetworkBytes(iface)
+	if err != nil {
+		logErrorf("Failed to get network statistics for interface '%s': %v\n", iface, err)
+		return
+	}
+	prevTime := time.Now()
+
+	for {
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return
+		}
+
+		rx, tx, err := readNetworkBytes(iface)
+		if err != nil {
+			logWarn("Failed to get network statistics:", err)
+			continue
+		}
+		now := time.Now()
+
+		// The counters might wrap or be reset, in which case the result is skipped.
+		if rx >= prevRx && tx >= prevTx {
+			elapsed := now.Sub(prevTime).Seconds()
+			results <- NetworkResult{
+				Received: float64(rx-prevRx) / elapsed,
+				Sent:     float64(tx-prevTx) / elapsed,
+			}
+		}
+
+		prevRx, prevTx, prevTime = rx, tx, now
+	}
+}
@@ -0,0 +1,20 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+//go:build !linux && !windows
+// +build !linux,!windows
+
+// Get the network throughput (unsupported platform edition)
+
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// Getting the network throughput is not supported on this platform.
+func NetworkStats(ctx context.Context, iface string, interval time.Duration, results chan NetworkResult) {
+	defer close(results)
+	logError("Getting the network throughput is not supported on this platform.")
+}
@@ -0,0 +1,55 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+// Get the network throughput from the performance counters (Windows edition)
+
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// Run a loop that will continuously get the network throughput of an interface (or all interfaces if empty), at the
+// specified interval.
+func NetworkStats(ctx context.Context, iface string, interval time.Duration, results chan NetworkResult) {
+	defer close(results)
+
+	if iface == "" {
+		iface = "*"
+	}
+
+	query, err := openPDHQuery([]string{
+		`\Network Interface(` + iface + `)\Bytes Received/sec`,
+		`\Network Interface(` + iface + `)\Bytes Sent/sec`,
+	})
+	if err != nil {
+		logError("Failed to open performance counters:", err)
+		return
+	}
+	defer query.Close()
+
+	for {
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return
+		}
+
+		values, err := query.Collect()
+		if err != nil {
+			logWarn("Failed to collect performance counters:", err)
+			continue
+		}
+
+		// With a wildcard, each counter has one value per interface.
+		select {
+		case results <- NetworkResult{Received: sumValues(values[0]), Sent: sumValues(values[1])}:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
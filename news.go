@@ -0,0 +1,82 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+// Get the recent headlines of an RSS or Atom feed.
+
+package main
+
+import (
+	"context"
+	"html"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// News constants.
+const (
+	NEWS_INTERVAL      = 15 * time.Minute // How often to fetch the feed.
+	NEWS_MAX_HEADLINES = 10               // The maximum number of headlines to get from the feed.
+	NEWS_TIMEOUT       = 30 * time.Second // How long to wait for the feed before giving up.
+)
+
+// The type of a news result
+type NewsResult struct {
+	Source    string   // The title of the feed.
+	Headlines []string // The most recent headlines, without HTML.
+}
+
+// Expression matching HTML tags, which some feeds put in the titles.
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+
+// Turn the title of a feed or item into plain text that can be drawn.
+func plainText(title string) string {
+	text := html.UnescapeString(htmlTag.ReplaceAllString(title, ""))
+	return ToLatin(strings.Join(strings.Fields(text), " "))
+}
+
+// Run a loop that will fetch the headlines of the feed at the specified URL every NEWS_INTERVAL. Failed fetches are
+// logged and retried at the next interval.
+func NewsStats(ctx context.Context, url string, results chan NewsResult) {
+	defer close(results)
+
+	key := cacheKey("news", url)
+	if cached, found := cachedValue(key); found {
+		results <- cached.(NewsResult)
+	}
+
+	parser := gofeed.NewParser()
+	parser.Client = &http.Client{Timeout: NEWS_TIMEOUT}
+	for {
+		if feed, err := parser.ParseURLWithContext(url, ctx); ctx.Err() != nil {
+			return
+		} else if err != nil {
+			logWarnf("Failed to fetch feed %s: %v\n", url, err)
+		} else {
+			result := NewsResult{Source: plainText(feed.Title)}
+			for _, item := range feed.Items {
+				if headline := plainText(item.Title); headline != "" {
+					result.Headlines = append(result.Headlines, headline)
+				}
+				if len(result.Headlines) >= NEWS_MAX_HEADLINES {
+					break
+				}
+			}
+			cacheValue(key, result)
+			select {
+			case results <- result:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		select {
+		case <-time.After(NEWS_INTERVAL):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,17 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+// Get the currently playing media. The platform specific parts are found in nowplaying_<platform>.go
+
+package main
+
+import "time"
+
+// The type of a media result
+type MediaResult struct {
+	Playing  bool          // Whether anything is currently playing.
+	Title    string        // The title of the track.
+	Artist   string        // The artist(s) of the track.
+	Position time.Duration // The current position in the track.
+	Duration time.Duration // The total length of the track, or zero if unknown.
+}
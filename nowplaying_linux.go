@@ -0,0 +1,98 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+//go:build linux
+// +build linux
+
+// Get the currently playing media from MPRIS over D-Bus (Linux edition)
+
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// MPRIS constants.
+const (
+	MPRIS_PREFIX = "org.mpris.MediaPlayer2."       // The bus name prefix of media players.
+	MPRIS_PATH   = "/org/mpris/MediaPlayer2"       // The object path of media players.
+	MPRIS_PLAYER = "org.mpris.MediaPlayer2.Player" // The player interface.
+)
+
+// Get the media that is currently playing in any of the players on the bus, or an empty result if nothing is playing.
+func currentMedia(conn *dbus.Conn) MediaResult {
+	var names []string
+	if err := conn.BusObject().Call("org.freedesktop.DBus.ListNames", 0).Store(&names); err != nil {
+		logError("Failed to list D-Bus names:", err)
+		return MediaResult{}
+	}
+
+	for _, name := range names {
+		if !strings.HasPrefix(name, MPRIS_PREFIX) {
+			continue
+		}
+
+		player := conn.Object(name, MPRIS_PATH)
+		status, err := player.GetProperty(MPRIS_PLAYER + ".PlaybackStatus")
+		if err != nil || status.Value() != "Playing" {
+			continue
+		}
+
+		result := MediaResult{Playing: true}
+
+		if metadata, err := player.GetProperty(MPRIS_PLAYER + ".Metadata"); err == nil {
+			values, _ := metadata.Value().(map[string]dbus.Variant)
+			if title, found := values["xesam:title"]; found {
+				result.Title, _ = title.Value().(string)
+			}
+			if artists, found := values["xesam:artist"]; found {
+				list, _ := artists.Value().([]string)
+				result.Artist = strings.Join(list, ", ")
+			}
+			if length, found := values["mpris:length"]; found {
+				// The length is in microseconds, but players disagree on whether it is signed or not.
+				switch length := length.Value().(type) {
+				case int64:
+					result.Duration = time.Duration(length) * time.Microsecond
+				case uint64:
+					result.Duration = time.Duration(length) * time.Microsecond
+				}
+			}
+		}
+
+		if position, err := player.GetProperty(MPRIS_PLAYER + ".Position"); err == nil {
+			if position, ok := position.Value().(int64); ok {
+				result.Position = time.Duration(position) * time.Microsecond
+			}
+		}
+
+		return result
+	}
+
+	return MediaResult{}
+}
+
+// Run a loop that will continuously get the currently playing media, at the specified interval.
+func NowPlayingStats(ctx context.Context, interval time.Duration, results chan MediaResult) {
+	defer close(results)
+
+	conn, err := dbus.SessionBus()
+	if err != nil {
+		logError("Failed to connect to the D-Bus session bus:", err)
+		return
+	}
+
+	for {
+		results <- currentMedia(conn)
+
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,20 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+//go:build !linux && !windows
+// +build !linux,!windows
+
+// Get the currently playing media (unsupported platform edition)
+
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// Getting the currently playing media is not supported on this platform.
+func NowPlayingStats(ctx context.Context, interval time.Duration, results chan MediaResult) {
+	defer close(results)
+	logError("Getting the currently playing media is not supported on this platform.")
+}
@@ -0,0 +1,117 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+// Get the currently playing media from the system media transport controls (Windows edition)
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PowerShell script that periodically prints the current media session as "<title>\t<artist>\t<position>\t<length>",
+// or an empty line if nothing is playing. The position and length are in whole seconds.
+const nowPlayingScript = `
+Add-Type -AssemblyName System.Runtime.WindowsRuntime
+$asTask = ([System.WindowsRuntimeSystemExtensions].GetMethods() | Where-Object {
+	$_.Name -eq 'AsTask' -and $_.GetParameters().Count -eq 1 -and
+	$_.GetParameters()[0].ParameterType.Name -eq ('IAsyncOperation' + [char]96 + '1')
+})[0]
+function Await($op, $type) {
+	$task = $asTask.MakeGenericMethod($type).Invoke($null, @($op))
+	$task.Wait(-1) | Out-Null
+	$task.Result
+}
+$managerType = [Windows.Media.Control.GlobalSystemMediaTransportControlsSessionManager,Windows.Media.Control,ContentType=WindowsRuntime]
+$propertiesType = [Windows.Media.Control.GlobalSystemMediaTransportControlsSessionMediaProperties,Windows.Media.Control,ContentType=WindowsRuntime]
+$manager = Await ($managerType::RequestAsync()) $managerType
+while ($true) {
+	$session = $manager.GetCurrentSession()
+	if ($session -ne $null -and $session.GetPlaybackInfo().PlaybackStatus -eq 'Playing') {
+		$properties = Await ($session.TryGetMediaPropertiesAsync()) $propertiesType
+		$timeline = $session.GetTimelineProperties()
+		[Console]::WriteLine((@($properties.Title, $properties.Artist,
+			[int]$timeline.Position.TotalSeconds, [int]$timeline.EndTime.TotalSeconds) -join [char]9))
+	} else {
+		[Console]::WriteLine("")
+	}
+	Start-Sleep -Seconds %d
+}
+`
+
+// Run a loop that will continuously get the currently playing media, at the specified interval rounded to whole
+// seconds.
+func NowPlayingStats(ctx context.Context, interval time.Duration, results chan MediaResult) {
+	defer close(results)
+
+	seconds := int(interval.Seconds())
+	if seconds < 1 {
+		seconds = 1
+	}
+
+	cmd := exec.Command("PowerShell", "-NoProfile", "-NonInteractive", "-Command",
+		fmt.Sprintf(nowPlayingScript, seconds))
+	cmd.Stderr = os.Stderr
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		logError("Failed to connect stdout for PowerShell:", err)
+		return
+	} else if err := cmd.Start(); err != nil {
+		logError("Failed to start PowerShell:", err)
+		return
+	}
+
+	defer cmd.Process.Kill()
+
+	lines := make(chan string, 5)
+	go func() {
+		defer close(lines)
+		reader := bufio.NewReader(stdout)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				logError("Read error from PowerShell:", err)
+				return
+			}
+			lines <- strings.TrimRight(line, "\r\n")
+		}
+	}()
+
+	for {
+		select {
+		case line, more := <-lines:
+			if !more {
+				return
+			}
+
+			fields := strings.Split(line, "\t")
+			if len(fields) != 4 {
+				results <- MediaResult{}
+				continue
+			}
+
+			position, _ := strconv.Atoi(fields[2])
+			duration, _ := strconv.Atoi(fields[3])
+			results <- MediaResult{
+				Playing:  true,
+				Title:    fields[0],
+				Artist:   fields[1],
+				Position: time.Duration(position) * time.Second,
+				Duration: time.Duration(duration) * time.Second,
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
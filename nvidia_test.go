@@ -0,0 +1,100 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+// Tests of the status of NVIDIA graphics cards, with the status from NVML made up.
+
+package main
+
+import (
+	"testing"
+
+	"gitlab.com/Drauthius/gpu-monitoring-tools/bindings/go/nvml"
+)
+
+func uintPtr(value uint) *uint       { return &value }
+func uint64Ptr(value uint64) *uint64 { return &value }
+
+// Get a graphics card with a PCIe bandwidth of 1000, and a status with the specified throughput.
+func nvidiaStatus(rx, tx uint) (*nvml.Device, *nvml.DeviceStatus) {
+	var device nvml.Device
+	device.PCI.Bandwidth = uintPtr(1000)
+
+	var status nvml.DeviceStatus
+	status.Temperature = uintPtr(50)
+	status.FanSpeed = uintPtr(40)
+	status.Utilization.GPU = uintPtr(90)
+	status.Utilization.Memory = uintPtr(25)
+	status.Utilization.Encoder = uintPtr(10)
+	status.Utilization.Decoder = uintPtr(5)
+	status.PCI.Throughput.RX = uintPtr(rx)
+	status.PCI.Throughput.TX = uintPtr(tx)
+	return &device, &status
+}
+
+func TestNvidiaResult(t *testing.T) {
+	device, status := nvidiaStatus(100, 300)
+	got := nvidiaResult(device, status, "C")
+	want := GraphicCardResult{
+		Temperature:  50,
+		FanSpeed:     0.4,
+		GPU:          0.9,
+		Memory:       0.25,
+		Encoder:      0.1,
+		Decoder:      0.05,
+		PCIBandwidth: 0.4,
+	}
+	if got != want {
+		t.Errorf("nvidiaResult() = %+v, want %+v", got, want)
+	}
+}
+
+// The throughput used to be counted in one direction twice.
+func TestNvidiaResultPCIThroughput(t *testing.T) {
+	tests := []struct {
+		rx, tx uint
+		want   float64
+	}{
+		{0, 0, 0},
+		{100, 0, 0.1},
+		{0, 300, 0.3},
+		{100, 300, 0.4},
+		{500, 500, 1},
+	}
+
+	for _, test := range tests {
+		device, status := nvidiaStatus(test.rx, test.tx)
+		if got := nvidiaResult(device, status, "C").PCIBandwidth; got != test.want {
+			t.Errorf("nvidiaResult() with RX %d and TX %d has the PCIe bandwidth %v, want %v",
+				test.rx, test.tx, got, test.want)
+		}
+	}
+}
+
+func TestNvidiaResultTemperatureUnit(t *testing.T) {
+	device, status := nvidiaStatus(0, 0)
+	for unit, want := range map[string]float64{"C": 50, "F": 122, "K": 323.15} {
+		if got := nvidiaResult(device, status, unit).Temperature; got != want {
+			t.Errorf("nvidiaResult() in %s has the temperature %v, want %v", unit, got, want)
+		}
+	}
+}
+
+func TestNvidiaResultOptional(t *testing.T) {
+	device, status := nvidiaStatus(0, 0)
+	got := nvidiaResult(device, status, "C")
+	if got.Power != 0 || got.PowerLimit != 0 || got.MemoryUsed != 0 || got.MemoryTotal != 0 {
+		t.Errorf("nvidiaResult() without power or memory = %+v, want them to be zero", got)
+	}
+
+	status.Power = uintPtr(120)
+	device.Power = uintPtr(250)
+	status.Memory.Global.Used = uint64Ptr(2048)
+	device.Memory = uint64Ptr(8192)
+	got = nvidiaResult(device, status, "C")
+	if got.Power != 120 || got.PowerLimit != 250 {
+		t.Errorf("nvidiaResult() has the power %v of %v, want 120 of 250", got.Power, got.PowerLimit)
+	}
+	if got.MemoryUsed != 2048*1024*1024 || got.MemoryTotal != 8192*1024*1024 {
+		t.Errorf("nvidiaResult() has the memory %v of %v, want 2 GiB of 8 GiB", got.MemoryUsed, got.MemoryTotal)
+	}
+}
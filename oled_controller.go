@@ -9,11 +9,18 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"flag"
+	"fmt"
 	"log"
+	"math"
 	"os"
 	"os/signal"
 	"runtime"
+	"strconv"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
@@ -30,15 +37,150 @@ const (
 	INTERFACE  = 1      // The USB interface number to look for (Linux only)
 )
 
+// HID report size constants.
+const (
+	DEFAULT_REPORT_SIZE = 32 // The report size used by QMK raw HID by default.
+	MIN_REPORT_SIZE     = 25 // The smallest report size allowed (a line of a standard 21 column screen, and its header).
+	MAX_REPORT_SIZE     = 64 // The largest report size allowed (the maximum for full-speed devices).
+)
+
+// How long to wait for the firmware to acknowledge a command before giving up.
+const RESPONSE_TIMEOUT = 100 * time.Millisecond
+
+// Limits on consuming the lingering messages from the firmware when shutting down.
+const (
+	DRAIN_MAX_MESSAGES = 100                    // The most messages to consume.
+	DRAIN_TIMEOUT      = 500 * time.Millisecond // The longest time to spend consuming them.
+)
+
+// How long to wait for the data sources to stop before reloading the configuration, which they might be using.
+const SOURCES_STOP_TIMEOUT = 10 * time.Second
+
+// How long to wait for a message from the firmware in each read, by default.
+const DEFAULT_READ_TIMEOUT = 500 * time.Millisecond
+
+// Screen size constants, in characters. The firmware can be overridden with the -columns and -rows flags, e.g. when it
+// reports the size wrong.
+const (
+	MAX_COLUMNS     = 64 // The most columns that can be given.
+	MAX_ROWS        = 16 // The most rows that can be given.
+	DRY_RUN_COLUMNS = 21 // The number of columns to draw with -once, unless given.
+	DRY_RUN_ROWS    = 4  // The number of rows to draw with -once, unless given.
+)
+
+// Write retry constants. Used when writing a command to the device fails, e.g. due to a flaky USB connection.
+const (
+	DEFAULT_WRITE_RETRIES = 2                     // How many times to retry a failed write, by default.
+	WRITE_RETRY_DELAY     = 10 * time.Millisecond // How long to wait before retrying a failed write.
+)
+
+// Reconnection constants. Used when the device becomes unreachable while running, e.g. due to a brief USB hiccup.
+const (
+	RECONNECT_ATTEMPTS = 5               // How many times to try to reopen the device before giving up.
+	RECONNECT_DELAY    = 1 * time.Second // The delay between attempts.
+)
+
+// Device retry constants. The time between attempts to find and open the device grows each time opening it fails.
+const (
+	RETRY_MIN_DELAY = 2 * time.Second  // The delay between attempts normally, and after the device has been opened.
+	RETRY_MAX_DELAY = 30 * time.Second // The maximum delay between attempts.
+	RETRY_BACKOFF   = 2                // The factor with which the delay grows after a failed attempt.
+)
+
+// How long to pause the rotation of tags after the tag has been changed from the keyboard.
+const ROTATE_PAUSE = 1 * time.Minute
+
+// How long the tag changes from the keyboard have to settle before the last requested tag is shown. This keeps a held
+// key from starting and stopping every tag on the way.
+const TAG_CHANGE_DEBOUNCE = 150 * time.Millisecond
+
 // Struct containing the program arguments
 type Args struct {
-	debug            *bool   // Whether debugging is enabled
-	temperatureUnit  *string // The unit in which to display temperature (C, F, or K).
-	sysStatDisk      *string // The name of the disk for which to show I/O usage (Linux only)
-	gmailCredentials *string // The path to the JSON credential file for fetching GMail information.
-	gmailLabel       *string // The label for which to fetch the number of unread messages.
-	weatherKey       *string // The openweathermap.org API key
-	weatherLocation  *string // The location for which to get the current temperature.
+	debug               *bool          // Whether debugging is enabled
+	logLevel            *string        // The lowest level of the messages to log.
+	logJSON             *bool          // Whether to log the messages as JSON.
+	hidReportSize       *uint          // The size of the HID reports, in bytes.
+	readTimeout         *time.Duration // How long to wait for a message from the firmware in each read.
+	writeRetries        *uint          // How many times to retry writing a command to the device before giving up.
+	once                *bool          // Whether to draw each tag once to stdout instead of to the keyboard.
+	columns             *uint          // The number of columns of the screens, instead of what the firmware reports.
+	rows                *uint          // The number of rows of the screens, instead of what the firmware reports.
+	brightness          *int           // The brightness to set the screens to (0-255), or negative to leave it unchanged.
+	nightStart          *string        // The time at which to dim the screens for the night.
+	nightEnd            *string        // The time at which to stop dimming the screens.
+	nightBrightness     *int           // The brightness of the screens during the night.
+	enabledTags         *string        // The tags that can be shown.
+	masterTag           *string        // The tag (number or name) to show initially on the master screen.
+	slaveTag            *string        // The tag (number or name) to show initially on the slave screen.
+	masterRotate        *string        // The tags to cycle through on the master screen.
+	slaveRotate         *string        // The tags to cycle through on the slave screen.
+	layerNames          *string        // The names of the keyboard layers.
+	mirror              *bool          // Whether the other screens show the same tag as the master screen.
+	rotateInterval      *time.Duration // How long to show each tag when cycling through them.
+	barChar             *string        // The character filling the bars, possibly escape-coded.
+	barLeft             *string        // The character to the left of the bars, possibly escape-coded.
+	barRight            *string        // The character to the right of the bars, possibly escape-coded.
+	smoothing           *float64       // How much of the previous value to keep when smoothing the system and GPU bars.
+	temperatureUnit     *string        // The unit in which to display temperature (C, F, or K).
+	sysStatDisk         *string        // The name of the disk(s) for which to show I/O usage (Linux only)
+	sysStatInterval     *time.Duration // How often to get the system status.
+	cpuPerCore          *bool          // Whether to show the usage of each CPU core instead of system status (Linux and Windows only)
+	cpuTempSensor       *string        // The name of the hardware monitor giving the CPU temperature.
+	cpuAlert            *float64       // The CPU usage, in percent, at which to mark it.
+	cpuTempAlert        *float64       // The CPU temperature at which to mark it.
+	fanSensors          *string        // The fans for which to show the speed.
+	diskHealthDevices   *string        // The drives for which to show the SMART health and temperature.
+	fanMaxRPM           *float64       // The fan speed, in RPM, that fills the bars.
+	historyMetric       *string        // The metric to show over time.
+	diskSpaceMounts     *string        // The mount points for which to show disk space.
+	netInterface        *string        // The network interface for which to show throughput, or empty for all.
+	netMaxMbps          *float64       // The network throughput, in Mbit/s, that fills the bars.
+	dockerSocket        *string        // The path to the socket of the Docker daemon.
+	pingHost            *string        // The host to measure the round-trip time to.
+	showPublicIP        *bool          // Whether to get the public IP address from an external service.
+	gpuVendor           *string        // The vendor of the graphics card for which to show status (nvidia or amd).
+	gpuInterval         *time.Duration // How often to get the status of the graphics card.
+	gpuAlert            *float64       // The GPU usage, in percent, at which to mark it.
+	gpuTempAlert        *float64       // The GPU temperature at which to mark it.
+	tempTrend           *bool          // Whether to show arrows with the trend of the weather and GPU temperatures.
+	alertRGB            *bool          // Whether to show the alert state with the RGB underglow of the keyboard.
+	gmailCredentials    *string        // The path to the JSON credential file for fetching GMail information.
+	gmailLabel          *string        // The label for which to fetch the number of unread messages.
+	gmailInterval       *time.Duration // How often to get the number of unread messages from GMail.
+	gmailToken          *string        // The path to the file caching the GMail OAuth token.
+	gmailAuthCode       *string        // The authorization code to exchange for a GMail OAuth token, instead of prompting.
+	calendarID          *string        // The calendar for which to show the upcoming events.
+	imapServer          *string        // The IMAP server, as <host>:<port>, for fetching unread messages.
+	imapUser            *string        // The user name to log in to the IMAP server with.
+	imapPassword        *string        // The password to log in to the IMAP server with.
+	imapMailbox         *string        // The mailbox for which to fetch the number of unread messages.
+	weatherProvider     *string        // The provider of the current weather.
+	weatherKey          *string        // The openweathermap.org API key
+	weatherLocation     *string        // The location for which to get the current temperature.
+	weatherLatitude     *float64       // The latitude of the location for which to get the current weather.
+	weatherLongitude    *float64       // The longitude of the location for which to get the current weather.
+	weatherInterval     *time.Duration // How often to get the current weather.
+	weatherForecast     *uint          // The number of forecast entries, three hours apart, to show after the current weather.
+	spotifyClientID     *string        // The client ID of the Spotify app.
+	spotifyClientSecret *string        // The client secret of the Spotify app.
+	tickerSymbols       *string        // The symbols of the coins or stocks for which to show prices.
+	tickerProvider      *string        // The provider of the prices (coingecko or yahoo).
+	tickerRotation      *time.Duration // How long to show each price.
+	clockFormat         *string        // The format of the time shown by the clock.
+	clockTimezone       *string        // The second timezone shown by the clock, if any.
+	timerLength         *time.Duration // The length of the countdown timer.
+	messageFile         *string        // The file with the messages to show.
+	messageRotation     *time.Duration // How long to show each message.
+	rssURL              *string        // The URL of the feed to show headlines from.
+	rssRotation         *time.Duration // How long to show each headline.
+	splashText          *string        // Text to show on the screens while waiting for the tags to be drawn.
+	clearOnExit         *bool          // Whether to clear the screens when stopping.
+	exitText            *string        // Text to show on the screens when stopping, instead of clearing them.
+	httpAddr            *string        // The address on which to serve the status of the controller, if any.
+	stdinControl        *bool          // Whether to read commands controlling the screens from stdin.
+	mqttBroker          *string        // The MQTT broker to publish statistics to, if any.
+	mqttPrefix          *string        // The prefix of the MQTT topics.
+	mqttQoS             *uint          // The MQTT quality of service level (0, 1, or 2).
 }
 
 // Global argument object
@@ -46,13 +188,25 @@ var gArgs Args
 
 // Icon constants. Assumes a custom glcdfont.c to show some of the nicer icons.
 const (
-	BAR_CHAR     = "\x7F"     // The character to use for drawing a horizontal bar.
-	MAIL_ICON    = "\x01\x02" // The character(s) to use for drawing a mail icon.
-	DEGREES_ICON = "\x11"     // The character to use to draw the degree (°) symbol.
-	FAN_ICON_1   = "\x12\x13" // Characters showing a fan icon, variant 1
-	FAN_ICON_2   = "\x14\x15" // Characters showing a fan icon, variant 2
+	BAR_CHAR        = "\x7F"     // The character to use for drawing a horizontal bar.
+	MAIL_ICON       = "\x01\x02" // The character(s) to use for drawing a mail icon.
+	DEGREES_ICON    = "\x11"     // The character to use to draw the degree (°) symbol.
+	FAN_ICON_1      = "\x12\x13" // Characters showing a fan icon, variant 1
+	FAN_ICON_2      = "\x14\x15" // Characters showing a fan icon, variant 2
+	MUSIC_ICON      = "\x16"     // The character to use for drawing a music note.
+	ERROR_ICON      = "\x17"     // The character to use for drawing an error (X) icon.
+	UP_ARROW_ICON   = "\x18"     // The character to use for drawing an arrow pointing up.
+	DOWN_ARROW_ICON = "\x19"     // The character to use for drawing an arrow pointing down.
+	ALERT_ICON      = "!"        // The character to use for marking a value that has crossed its alert threshold.
+	FLAT_ICON       = "-"        // The character to use for showing that a value is unchanged, next to the arrows.
 )
 
+// The shortest bar worth drawing next to some text. Text that would make the bar shorter is left out.
+const MIN_BAR_LENGTH = 3
+
+// Characters showing a vertical bar filled from 1/8 to 8/8. Also assumes a custom glcdfont.c.
+const VERTICAL_BAR_CHARS = "\x80\x81\x82\x83\x84\x85\x86\x87"
+
 type MessageID byte // The type of a message to/from the OLED controller.
 // Messages understood by the OLED controller.
 const (
@@ -67,14 +221,66 @@ const (
 	Failure = 0x01
 )
 
+type ErrorCode byte // The type of an error code sent by the firmware when a command fails.
+// The error codes, which are the first parameter of a failed response. They mirror the ones in the firmware.
+const (
+	ErrorUnspecified    ErrorCode = iota // No reason given, which is what older firmware sends.
+	ErrorUnknownCommand                  // The command isn't supported by the firmware.
+	ErrorUnknownScreen                   // There is no screen with the specified identifier.
+	ErrorLineOutOfRange                  // The line index is beyond the rows of the screen.
+	ErrorOutOfRange                      // The characters or pixels are beyond the screen.
+	ErrorBadParameters                   // The parameters of the command are malformed.
+	ErrorBusy                            // The firmware can't handle the command right now.
+)
+
+// Map from an error code to a description of it.
+var ERROR_CODES = map[ErrorCode]string{
+	ErrorUnspecified:    "unspecified error",
+	ErrorUnknownCommand: "unknown command",
+	ErrorUnknownScreen:  "unknown screen",
+	ErrorLineOutOfRange: "line index out of range",
+	ErrorOutOfRange:     "position out of range",
+	ErrorBadParameters:  "bad parameters",
+	ErrorBusy:           "busy",
+}
+
+// Get the description of an error code.
+func (code ErrorCode) String() string {
+	if description, found := ERROR_CODES[code]; found {
+		return description
+	}
+	return fmt.Sprintf("error 0x%02X", byte(code))
+}
+
+// Error for a command that the firmware reported as failed.
+type CommandError struct {
+	Command CommandID // The command that failed.
+	Screen  ScreenID  // Which screen the command was for.
+	Code    ErrorCode // The reason it failed.
+	Detail  string    // Further details given by the firmware, if any.
+}
+
+// Describe the failure, e.g. "command 0x02 on screen 0x00 failed: line index out of range".
+func (err *CommandError) Error() string {
+	message := fmt.Sprintf("command 0x%02X on screen 0x%02X failed: %v", err.Command, err.Screen, err.Code)
+	if err.Detail != "" {
+		message += " (" + err.Detail + ")"
+	}
+	return message
+}
+
 type CommandID byte // The type of a command to the OLED controller.
 // Commands understood by the OLED controller.
 const (
-	SetUp    = 0x00 // Set up the OLED controller, and get the screen size.
-	Clear    = 0x01 // Clear an OLED screen.
-	SetLine  = 0x02 // Set the content of a line on an OLED screen.
-	SetChars = 0x03 // Set the content of a portion of the OLED screen.
-	Present  = 0x04 // Show changed lines to a screen.
+	SetUp       = 0x00 // Set up the OLED controller, and get the screen size.
+	Clear       = 0x01 // Clear an OLED screen.
+	SetLine     = 0x02 // Set the content of a line on an OLED screen.
+	SetChars    = 0x03 // Set the content of a portion of the OLED screen.
+	Present     = 0x04 // Show changed lines to a screen.
+	SetBitmap   = 0x05 // Set the pixels of a portion of the OLED screen.
+	SetContrast = 0x06 // Set the contrast (brightness) of an OLED screen.
+	SetLineInv  = 0x07 // Set the content of a line on an OLED screen, drawn inverted.
+	SetRGB      = 0x08 // Set the color of the RGB underglow of the keyboard.
 )
 
 type EventID byte // The type of an event from the OLED controller.
@@ -83,8 +289,18 @@ const (
 	ChangeTag    = 0x00 // Change the content of a screen.
 	IncrementTag = 0x01 // Increment the tag shown on the screen by one.
 	DecrementTag = 0x02 // Decrement the tag shown on the screen by one.
+	Brightness   = 0x03 // Set the brightness of the screen.
+	Layer        = 0x04 // The active layer of the keyboard changed.
+	TimerToggle  = 0x05 // Start or pause the countdown timer.
+	TimerReset   = 0x06 // Stop the countdown timer, and set it back to its full length.
+	TimerAdjust  = 0x07 // Change the length of the countdown timer by a signed number of minutes.
+	WPM          = 0x08 // The typing speed (and number of key presses) reported by the keyboard.
 )
 
+// The token in the content of a tag that is replaced by the name of the active layer. If the firmware doesn't report
+// the layer, the token is left for the firmware to replace.
+const LAYER_TOKEN = "%l"
+
 type ScreenID byte // The type of a screen identifier.
 // Screen identifiers
 const (
@@ -92,6 +308,9 @@ const (
 	Slave  = 0x01 // OLED screen on the slave side
 )
 
+// The number of screens to assume when the firmware doesn't report it.
+const DEFAULT_SCREEN_COUNT = 2
+
 // Structure holding a response from the OLED controller.
 type Response struct {
 	Success bool      // Whether the command was successful.
@@ -100,6 +319,24 @@ type Response struct {
 	Params  []byte    // Additional parameters sent by the firmware.
 }
 
+// Get the error of a failed response, or nil if it was successful.
+// The parameters of a failed response are the error code, optionally followed by a NUL-terminated detail message.
+func (resp Response) Err() error {
+	if resp.Success {
+		return nil
+	}
+	err := &CommandError{Command: resp.Command, Screen: resp.Screen}
+	if len(resp.Params) > 0 {
+		err.Code = ErrorCode(resp.Params[0])
+		detail := resp.Params[1:]
+		if end := bytes.IndexByte(detail, 0); end >= 0 {
+			detail = detail[:end]
+		}
+		err.Detail = strings.TrimSpace(ToLatin(string(detail)))
+	}
+	return err
+}
+
 // Structure holding an event from the OLED controller.
 type Event struct {
 	Event  EventID  // The event that was issued.
@@ -107,10 +344,33 @@ type Event struct {
 	Params []byte   // Addition parameters set by the firmware.
 }
 
+// The operations used on the HID device. Satisfied by *hid.Device, but allows another device to be used in its place.
+type HIDDevice interface {
+	Write(b []byte) (int, error)
+	ReadTimeout(b []byte, timeout int) (int, error)
+	SetNonblocking(nonblocking bool) error
+	Close() error
+}
+
 // Class for OLED control
 type OLEDController struct {
-	Device        *hid.Device // The associated HID device
-	Columns, Rows uint8       // The number of columns and rows available on the display(s)
+	Device        HIDDevice                  // The associated HID device
+	Info          hid.DeviceInfo             // Information about the device, used to reopen it
+	ReportSize    int                        // The size of the HID reports, in bytes
+	ReadTimeout   time.Duration              // How long to wait for a message in each read
+	WriteRetries  int                        // How many times to retry a failed write
+	SizeOverride  Area                       // The screen size to use instead of what the firmware reports, where non-zero
+	Columns, Rows uint8                      // The number of columns and rows available on the master display
+	Sizes         map[ScreenID]Area          // The size of each display
+	Responses     map[ScreenID]chan Response // Channels receiving the responses for each screen
+	Screens       []*Screen                  // The screens being controlled
+	Commands      chan Event                 // Channel receiving events from commands, if enabled
+	Connected     bool                       // Whether the device is connected and working
+
+	mutex       sync.Mutex     // Lock protecting the state read by the status server
+	deviceMutex sync.RWMutex   // Lock protecting the device from being replaced while in use
+	reconnected chan bool      // Channel notified when the device has been reconnected
+	signals     chan os.Signal // Channel receiving the signals to handle, including the control signals, made by Run if not set
 }
 
 // Screen size, in characters.
@@ -120,137 +380,438 @@ type Area struct {
 
 // Class for screen control
 type Screen struct {
-	ID         ScreenID        // The screen's unique ID
-	Controller *OLEDController // Reference to the OLED controller
-	Tag        uint8           // Which tag to show
-	Events     chan Event      // Channel to handle events
-	Quit       chan bool       // Channel to handle termination
+	ID         ScreenID         // The screen's unique ID
+	Controller *OLEDController  // Reference to the OLED controller
+	Tag        uint8            // Which tag to show
+	Rotation   []uint8          // The tags to cycle through automatically, if any
+	Layer      uint8            // The active layer of the keyboard, as last reported by the firmware
+	LayerNames map[uint8]string // The names of the layers, shown instead of the numbers
+	Events     chan Event       // Channel to handle events
+	Quit       chan bool        // Channel to handle termination
+	Mirrors    []chan uint8     // Channels of the screens showing the same tag as this one
+	Mirroring  chan uint8       // Channel receiving the tags to show, if the screen mirrors another one
 }
 
 // Start the screen handler.
-// It will run until the screen.Quit channel has been closed.
+// It will run until the screen.Quit channel has been closed. The caller must add the handler to the wait group before
+// starting it, since it's marked as done when the handler stops.
 func (screen *Screen) Run(wg *sync.WaitGroup) {
-	wg.Add(1)
 	defer wg.Done()
-	defer screen.Controller.SendCommand(Clear, screen.ID, nil)
+	defer screen.leave()
 
 	stopped := false
 	hasTag := false
-	stop := make(chan bool)
+	cancel := func() {} // Stops the tag currently shown.
 	results := make(chan []string, 5)
+	var shown []string // The last content drawn by the tag.
+	hasLayer := false  // Whether the firmware has reported the active layer.
+
+	// Draw the content of the tag, with the name of the active layer filled in.
+	draw := func(lines []string) {
+		shown = lines
+		if hasLayer {
+			name := screen.layerName()
+			lines = make([]string, len(shown))
+			for i, line := range shown {
+				lines[i] = strings.ReplaceAll(line, LAYER_TOKEN, name)
+			}
+		}
+		screen.Controller.DrawScreen(screen.ID, lines)
+	}
 
 	showTag := func(tagID uint8) {
 		tag, found := tags[tagID]
-		if !found {
-			log.Printf("Tag %d out of range.", tagID)
+		if len(tags) < 1 {
+			// Already logged when starting; the screen just stays cleared.
+			return
+		} else if !found {
+			logWarnf("Tag %d out of range.", tagID)
 		} else {
 			hasTag = true
+			screen.Controller.mutex.Lock()
 			screen.Tag = tagID
+			screen.Controller.mutex.Unlock()
+			var ctx context.Context
+			ctx, cancel = context.WithCancel(context.Background())
 			results = make(chan []string, 5)
-			go tag.Draw(Area{screen.Controller.Columns, screen.Controller.Rows}, results, stop)
+			go tag.Draw(ctx, screen.Controller.Sizes[screen.ID], results)
+		}
+	}
+
+	// Stop the current tag, and show the specified one instead, on this screen and the ones mirroring it.
+	switchTag := func(tag uint8) {
+		if hasTag {
+			cancel()
+			// Nothing more will be drawn from the old tag, but don't let it block while stopping.
+			go func(old chan []string) {
+				for range old {
+				}
+			}(results)
+		}
+		showTag(tag)
+
+		for _, mirror := range screen.Mirrors {
+			select {
+			case mirror <- tag:
+			case <-screen.Quit:
+			}
+		}
+	}
+
+	// Tag changes from the keyboard are held back until they settle, and then only the last one is shown.
+	var pendingTag uint8
+	var debounce <-chan time.Time
+
+	// Handle an event from the keyboard.
+	handleEvent := func(event Event) {
+		current := screen.Tag
+		if debounce != nil {
+			current = pendingTag // Cycle from the tag that is about to be shown.
+		}
+
+		switch event.Event {
+		case ChangeTag:
+			pendingTag = event.Params[0]
+		case IncrementTag, DecrementTag:
+			next, found := cycleTag(current, event.Event == IncrementTag)
+			if !found {
+				// There are no tags, which has already been logged when starting.
+				return
+			}
+			pendingTag = next
+		case Brightness:
+			screen.Controller.SetContrast(screen.ID, event.Params[0])
+			return
+		case Layer:
+			screen.Controller.mutex.Lock()
+			screen.Layer = event.Params[0]
+			screen.Controller.mutex.Unlock()
+			hasLayer = true
+			if shown != nil {
+				draw(shown)
+			}
+			return
+		case TimerToggle, TimerReset, TimerAdjust:
+			gTimer.HandleEvent(event)
+			return
+		case WPM:
+			gTyping.HandleEvent(event)
+			return
 		}
+
+		debounce = time.After(TAG_CHANGE_DEBOUNCE)
+	}
+
+	// Rotate through the tags, unless the tag was recently changed from the keyboard.
+	var rotate <-chan time.Time
+	var pausedUntil time.Time
+	if len(screen.Rotation) > 0 && *gArgs.rotateInterval > 0 {
+		ticker := time.NewTicker(*gArgs.rotateInterval)
+		defer ticker.Stop()
+		rotate = ticker.C
+	}
+
+	// Show the splash until the first tag has produced something to replace it with.
+	splash := false
+	if *gArgs.splashText != "" {
+		screen.Controller.DrawScreen(screen.ID, splashLines(screen.Controller.Sizes[screen.ID], *gArgs.splashText))
+		splash = true
 	}
 
-	showTag(screen.Tag)
+	// Without any tags, the screen stays blank (or keeps showing the splash).
+	if len(tags) > 0 {
+		showTag(screen.Tag)
+	}
 
+	quit := screen.Quit
 	for {
 		select {
 		case event := <-screen.Events:
 			if event.Screen != screen.ID {
 				log.Panicln("Received event intended for another screen:", event)
 			} else if stopped {
-				log.Println("Got event while shutting down:", event)
+				logDebug("Got event while shutting down:", event)
 				continue
 			}
 
-			var tag uint8
-			switch event.Event {
-			case ChangeTag:
-				tag = event.Params[0]
-			case IncrementTag:
-				if len(tags) < 1 {
-					log.Printf("Cannot increment tag: No tags found.")
+			if event.Event != Brightness && event.Event != Layer && event.Event != WPM && !isTimerEvent(event.Event) {
+				if screen.Mirroring != nil {
+					// The tag is controlled by the screen being mirrored.
 					continue
 				}
-				// TODO: This check doesn't account for a non-consecutive tags map, that doesn't start at 1.
-				tag = screen.Tag + 1
-				if _, found := tags[tag]; !found {
-					tag = 1
-					if _, found := tags[tag]; !found {
-						log.Printf("Failed to increment tag of screen 0x%02X currently on tag %d.\n", screen.ID, screen.Tag)
-						continue
-					}
-				}
-			case DecrementTag:
-				if len(tags) < 1 {
-					log.Printf("Cannot increment tag: No tags found.")
-					continue
-				}
-				// TODO: This check doesn't account for a non-consecutive tags map, that doesn't end on len(tags)
-				tag = screen.Tag - 1
-				if _, found := tags[tag]; !found {
-					tag = uint8(len(tags))
-					if _, found := tags[tag]; !found {
-						log.Printf("Failed to decrement tag of screen 0x%02X currently on tag %d.\n", screen.ID, screen.Tag)
-						continue
-					}
-				}
+				pausedUntil = time.Now().Add(ROTATE_PAUSE)
+			}
+			handleEvent(event)
+		case <-debounce:
+			debounce = nil
+			if !stopped {
+				switchTag(pendingTag)
+			}
+		case tag := <-screen.Mirroring:
+			if stopped {
+				continue
+			}
+			switchTag(tag)
+		case <-rotate:
+			if stopped || time.Now().Before(pausedUntil) {
+				continue
 			}
 
-			// Change the currently shown tag.
-			if hasTag {
-				stop <- true
+			// Pick the tag after the current one in the rotation, or the first one if the current isn't part of it.
+			next := screen.Rotation[0]
+			for i, tag := range screen.Rotation {
+				if tag == screen.Tag {
+					next = screen.Rotation[(i+1)%len(screen.Rotation)]
+					break
+				}
 			}
-			showTag(tag)
+			if next == screen.Tag && hasTag {
+				continue
+			}
+			switchTag(next)
 		case lines, more := <-results:
 			if !more {
 				if stopped {
 					return
 				}
 				hasTag = false
+				shown = nil
 				screen.Controller.SendCommand(Clear, screen.ID, nil)
+			} else if stopped {
+				// Keep whatever is left on the screen when stopping.
+				continue
 			} else {
-				screen.Controller.DrawScreen(screen.ID, lines)
-			}
-		case <-screen.Quit:
-			screen.Controller.SendCommand(Clear, screen.ID, nil)
-			if hasTag {
-				if !stopped {
-					stop <- true
+				if splash {
+					// The tag might not draw every line, so get rid of the splash first.
+					screen.Controller.SendCommand(Clear, screen.ID, nil)
+					splash = false
 				}
-			} else {
+				draw(lines)
+			}
+		case <-quit:
+			// The channel is closed, so it would be selected again every time.
+			quit = nil
+			if !hasTag {
 				return
 			}
+			// Wait for the tag to stop. The screen is left as configured afterwards.
+			cancel()
 			stopped = true
 		}
 	}
 }
 
+// Leave the screen when stopping. It's cleared, unless -clear-on-exit is disabled, in which case the last content is
+// left on it. If -exit-text is set, that is shown instead.
+func (screen *Screen) leave() {
+	if *gArgs.exitText != "" {
+		// The read loop might already have stopped, so don't wait for responses.
+		for i, line := range splashLines(screen.Controller.Sizes[screen.ID], *gArgs.exitText) {
+			cmd, params := lineCommand(uint8(i), StyledLine{Text: sanitizeForDisplay(line)})
+			screen.Controller.SendCommand(cmd, screen.ID, params)
+			time.Sleep(10 * time.Millisecond) // Give the firmware some time to handle the line.
+		}
+		screen.Controller.SendCommand(Present, screen.ID, nil)
+	} else if *gArgs.clearOnExit {
+		screen.Controller.SendCommand(Clear, screen.ID, nil)
+	}
+}
+
+// Get the lines of a splash screen showing the specified text, centered on a screen of the specified size.
+func splashLines(area Area, text string) []string {
+	lines := WrapText(ToLatin(text), area.Width, area.Height)
+	splash := make([]string, (int(area.Height)-len(lines))/2, area.Height)
+	for _, line := range lines {
+		splash = append(splash, CenterText(line, area.Width))
+	}
+	return splash
+}
+
 // Draw the specified content to the specified screen.
-func (oled *OLEDController) DrawScreen(screen ScreenID, lines []string) {
+// Returns false if drawing it failed, in which case the screen keeps showing the previous content.
+func (oled *OLEDController) DrawScreen(screen ScreenID, lines []string) bool {
+	styled := make([]StyledLine, len(lines))
 	for i, line := range lines {
-		if i > int(oled.Rows) {
-			log.Printf("Attempting to draw more rows than the OLED supports: %d/%d\n", i, oled.Rows)
+		styled[i].Text = line
+	}
+	return oled.DrawScreenStyled(screen, styled)
+}
+
+// A line of text, with how it should be drawn.
+type StyledLine struct {
+	Text     string // The text of the line.
+	Inverted bool   // Whether the line is drawn inverted (highlighted).
+}
+
+// Get the command and parameters setting the content of a line.
+func lineCommand(row uint8, line StyledLine) (CommandID, []byte) {
+	cmd := CommandID(SetLine)
+	if line.Inverted {
+		cmd = SetLineInv
+	}
+	return cmd, append([]byte{row}, line.Text...)
+}
+
+// Draw the specified content to the specified screen, with some of the lines possibly inverted.
+// Returns false if drawing it failed, in which case the screen keeps showing the previous content.
+// Note: Inverted lines require firmware support for the SetLineInv command.
+func (oled *OLEDController) DrawScreenStyled(screen ScreenID, lines []StyledLine) bool {
+	size := oled.Sizes[screen]
+	for i, line := range lines {
+		if i >= int(size.Height) {
+			logWarnf("Attempting to draw more rows than the OLED supports: %d/%d\n", len(lines), size.Height)
 			break
 		}
-		if len(line) > int(oled.Columns) && *gArgs.debug {
-			log.Printf("Attempting to draw more columns than the OLED supports: %d/%d\n", len(line), oled.Columns)
+		if len(line.Text) > int(size.Width) {
+			logDebugf("Attempting to draw more columns than the OLED supports: %d/%d\n", len(line.Text), size.Width)
+		}
+		// Wait for each line to be handled, so that the firmware isn't flooded.
+		line.Text = sanitizeForDisplay(line.Text)
+		cmd, params := lineCommand(uint8(i), line)
+		if err := oled.ExecuteCommand(cmd, screen, params); err != nil {
+			if _, failed := err.(*CommandError); failed {
+				logWarnf("Failed to draw line %d of screen 0x%02X: %v\n", i, screen, err)
+			}
+			// Don't present a half-written screen.
+			return false
 		}
-		oled.SendCommand(SetLine, screen, append([]byte{byte(i)}, line...))
-		time.Sleep(10 * time.Millisecond) // Ensure that the command gets handled properly.
 	}
-	oled.SendCommand(Present, screen, nil)
+	return oled.SendCommand(Present, screen, nil)
 }
 
-// Draw over a part of the screen
+// Draw over a part of the screen, optionally waiting for the firmware to handle it.
+// Characters that don't fit in one report are sent in several commands. Returns false, without sending the rest, if
+// any of them failed.
 // Note: Start offset is zero indexed
-func (oled *OLEDController) DrawChars(screen ScreenID, start uint8, chars string) {
-	oled.SendCommand(SetChars, screen, append([]byte{byte(start), byte(len(chars))}, chars...))
+func (oled *OLEDController) DrawChars(screen ScreenID, start uint8, chars string, wait bool) bool {
+	chunkSize := oled.reportSize() - 3 - 2 // Report size minus the command header, start offset, and length.
+	chars = sanitizeForDisplay(chars)
+
+	for offset := 0; offset < len(chars) || offset == 0; offset += chunkSize {
+		end := offset + chunkSize
+		if end > len(chars) {
+			end = len(chars)
+		}
+		data := append([]byte{byte(int(start) + offset), byte(end - offset)}, chars[offset:end]...)
+		var ok bool
+		if wait {
+			ok = oled.SendCommandAndWait(SetChars, screen, data)
+		} else {
+			ok = oled.SendCommand(SetChars, screen, data)
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Redraw part of a single row on the screen, starting at the specified column, and present the result. Text that
+// doesn't fit on the row is cut off. This sends less data than redrawing the whole screen.
+// Returns false if the region is outside the screen, or if drawing it failed.
+func (oled *OLEDController) UpdateRegion(screen ScreenID, row, col uint8, text string) bool {
+	size := oled.Sizes[screen]
+	if row >= size.Height || col >= size.Width {
+		logWarnf("Attempting to update a region outside of the OLED: %d,%d/%dx%d\n", row, col, size.Width, size.Height)
+		return false
+	}
+	if len(text) > int(size.Width-col) {
+		text = text[:size.Width-col]
+	}
+
+	// The characters are addressed as one long line.
+	start := int(row)*int(size.Width) + int(col)
+	if start > math.MaxUint8 {
+		logWarnf("Region at %d,%d is beyond the addressable characters.\n", row, col)
+		return false
+	}
+
+	return oled.DrawChars(screen, uint8(start), text, true) && oled.SendCommand(Present, screen, nil)
+}
+
+// Set the contrast of a screen, which effectively controls the brightness. 0 is the dimmest, and 255 the brightest.
+// Note: Requires firmware support for the SetContrast command.
+func (oled *OLEDController) SetContrast(screen ScreenID, level uint8) bool {
+	return oled.SendCommandAndWait(SetContrast, screen, []byte{level})
+}
+
+// Split a bitmap into the parameters of SetBitmap commands, each fitting in one report.
+// Each chunk starts with the column (x) and page (y) where the bitmap starts, followed by the offset of the chunk into
+// the bitmap (16 bits, little endian), the number of bytes in the chunk, and then the bytes themselves.
+func bitmapChunks(reportSize int, x, y uint8, data []byte) [][]byte {
+	const header = 5
+	chunkSize := reportSize - 3 - header // Report size minus the command header and the chunk header.
+
+	var chunks [][]byte
+	for offset := 0; offset < len(data); offset += chunkSize {
+		end := offset + chunkSize
+		if end > len(data) {
+			end = len(data)
+		}
+		chunk := []byte{x, y, byte(offset), byte(offset >> 8), byte(end - offset)}
+		chunks = append(chunks, append(chunk, data[offset:end]...))
+	}
+	return chunks
+}
+
+// Draw a bitmap to a part of the screen.
+// The data is in the format of the SSD1306 page memory, where each byte is a column of eight vertical pixels (least
+// significant bit at the top). The bitmap starts at column x of page y (i.e. pixel row y*8), and wraps to the next page
+// at the end of the screen.
+// Returns false if drawing it failed.
+// Note: Requires firmware support for the SetBitmap command.
+func (oled *OLEDController) DrawBitmap(screen ScreenID, x, y uint8, data []byte) bool {
+	for _, chunk := range bitmapChunks(oled.reportSize(), x, y, data) {
+		if !oled.SendCommandAndWait(SetBitmap, screen, chunk) {
+			return false
+		}
+	}
+	return oled.SendCommand(Present, screen, nil)
+}
+
+// Get the size of the HID reports, falling back to the default if it hasn't been set.
+func (oled *OLEDController) reportSize() int {
+	if oled.ReportSize == 0 {
+		return DEFAULT_REPORT_SIZE
+	}
+	return oled.ReportSize
+}
+
+// Get how long to wait for a message from the firmware in each read, falling back to the default if it hasn't been set.
+func (oled *OLEDController) readTimeout() time.Duration {
+	if oled.ReadTimeout < time.Millisecond {
+		return DEFAULT_READ_TIMEOUT
+	}
+	return oled.ReadTimeout
 }
 
-// Send a command to the OLED controller.
+// Send a command to the OLED controller. A failed write is retried up to WriteRetries times before giving up.
 func (oled *OLEDController) SendCommand(cmd CommandID, screen ScreenID, data []byte) bool {
-	buf := make([]byte, 32)
+	buf := encodeCommand(oled.reportSize(), cmd, screen, data)
+
+	for attempt := 0; ; attempt++ {
+		oled.deviceMutex.RLock()
+		_, err := oled.Device.Write(buf)
+		oled.deviceMutex.RUnlock()
+		if err == nil {
+			break
+		} else if attempt >= oled.WriteRetries {
+			logWarn("Failed to write to device:", err)
+			return false
+		}
+
+		logDebugf("Failed to write to device: %v (retry %d/%d)\n", err, attempt+1, oled.WriteRetries)
+		time.Sleep(WRITE_RETRY_DELAY)
+	}
+	logDebug(">", buf[:])
+
+	return true
+}
+
+// Construct the report for a command to the OLED controller.
+// The report is always reportSize bytes long, and data that doesn't fit after the header is silently truncated.
+func encodeCommand(reportSize int, cmd CommandID, screen ScreenID, data []byte) []byte {
+	buf := make([]byte, reportSize)
 
 	buf[0] = byte(CommandMsg)
 	buf[1] = byte(cmd)
@@ -258,36 +819,73 @@ func (oled *OLEDController) SendCommand(cmd CommandID, screen ScreenID, data []b
 
 	// Remaining bytes are command-specific.
 	if data != nil {
-		copy(buf[3:32], data)
+		copy(buf[3:], data)
 	}
 
-	_, err := oled.Device.Write(buf)
-	if err != nil {
-		log.Println("Failed to write to device:", err)
-		return false
+	return buf
+}
+
+// Send a command to the OLED controller, and wait for the firmware to respond to it.
+// Returns false if the command couldn't be sent, or if it failed or timed out. Failures reported by the firmware are
+// logged.
+func (oled *OLEDController) SendCommandAndWait(cmd CommandID, screen ScreenID, data []byte) bool {
+	err := oled.ExecuteCommand(cmd, screen, data)
+	if _, failed := err.(*CommandError); failed {
+		logWarn("Command failed:", err)
 	}
-	if *gArgs.debug {
-		log.Println(">", buf[:])
+	return err == nil
+}
+
+// Send a command to the OLED controller, and wait for the firmware to respond to it.
+// Returns a *CommandError if the firmware reported that the command failed, or another error if it couldn't be sent or
+// timed out.
+func (oled *OLEDController) ExecuteCommand(cmd CommandID, screen ScreenID, data []byte) error {
+	responses, found := oled.Responses[screen]
+	if !found {
+		// Nothing is reading responses for this screen, so just give the firmware some time to handle the command.
+		defer time.Sleep(10 * time.Millisecond)
+		if !oled.SendCommand(cmd, screen, data) {
+			return errors.New("failed to write to device")
+		}
+		return nil
 	}
 
-	return true
+	// Throw away any stale responses, so that they aren't mistaken for the response to this command.
+	for len(responses) > 0 {
+		<-responses
+	}
+
+	if !oled.SendCommand(cmd, screen, data) {
+		return errors.New("failed to write to device")
+	}
+
+	timeout := time.After(RESPONSE_TIMEOUT)
+	for {
+		select {
+		case resp := <-responses:
+			if resp.Command == cmd {
+				return resp.Err()
+			}
+		case <-timeout:
+			logDebugf("Timed out waiting for response to command 0x%02X on screen 0x%02X.\n", cmd, screen)
+			return fmt.Errorf("timed out waiting for response to command 0x%02X on screen 0x%02X", cmd, screen)
+		}
+	}
 }
 
 // Read a response or event from the OLED controller.
 func (oled *OLEDController) ReadResponse() (interface{}, error) {
-	buf := make([]byte, 32)
-	size, err := oled.Device.ReadTimeout(buf, 500)
+	buf := make([]byte, oled.reportSize())
+	size, err := oled.Device.ReadTimeout(buf, int(oled.readTimeout()/time.Millisecond))
 	if err != nil {
-		log.Println("Failed to read from device:", err)
+		logWarn("Failed to read from device:", err)
 		return nil, err
 	} else if size < 1 {
 		// Timed out
 		return nil, nil
 	}
 
-	if *gArgs.debug {
-		log.Println("<", buf[:size])
-	}
+	logDebug("<", buf[:size])
 	switch buf[0] {
 	case Success, Failure:
 		resp := Response{
@@ -297,9 +895,9 @@ func (oled *OLEDController) ReadResponse() (interface{}, error) {
 			Params:  buf[3:],
 		}
 
-		if !resp.Success {
-			log.Printf("Command 0x%02X failed with error 0x%02X.\n", resp.Command, buf[0])
-			return nil, nil
+		// Failed responses are passed on as well, so that whoever waits for them learns why.
+		if err := resp.Err(); err != nil {
+			logDebug("Got a failed response:", err)
 		}
 
 		return resp, nil
@@ -311,76 +909,233 @@ func (oled *OLEDController) ReadResponse() (interface{}, error) {
 		}
 		return event, nil
 	default:
-		log.Printf("Received unknown message 0x%02X\n", buf[0])
+		logWarnf("Received unknown message 0x%02X\n", buf[0])
 		return nil, nil
 	}
 }
 
-// Loop setting up and filling the OLED screens.
-func (oled *OLEDController) Run() {
-	defer oled.Device.Close()
+// Set up a screen, and get its size.
+// Note that this reads the response directly, and must not be used while anything else is reading from the device.
+func (oled *OLEDController) SetUp(screen ScreenID) (Area, uint8, bool) {
+	oled.SendCommand(SetUp, screen, nil)
+
+	var size Area
+	var count uint8
+	for size.Width == 0 && size.Height == 0 {
+		resp, _ := oled.ReadResponse()
+		if resp == nil {
+			logErrorf("Set up of screen 0x%02X failed.\n", screen)
+			return Area{}, 0, false
+		}
+
+		switch resp.(type) {
+		case Response:
+			if resp.(Response).Command != SetUp || resp.(Response).Screen != screen {
+				// Could be the response to a command sent before the set up, e.g. when reconnecting.
+				logDebug("Ignoring unrelated response while setting up:", resp)
+				continue
+			} else if err := resp.(Response).Err(); err != nil {
+				logErrorf("Set up of screen 0x%02X failed: %v\n", screen, err)
+				return Area{}, 0, false
+			}
+			size = Area{resp.(Response).Params[0], resp.(Response).Params[1]}
+			if reported := size; oled.SizeOverride != (Area{}) {
+				if oled.SizeOverride.Width > 0 {
+					size.Width = oled.SizeOverride.Width
+				}
+				if oled.SizeOverride.Height > 0 {
+					size.Height = oled.SizeOverride.Height
+				}
+				if size != reported {
+					logInfof("Using a size of %dx%d for screen 0x%02X instead of the %dx%d reported by the firmware.\n",
+						size.Width, size.Height, screen, reported.Width, reported.Height)
+				}
+			}
+			if size.Width < 1 || size.Height < 1 {
+				logErrorf("Failed to get screen size of screen 0x%02X from set up.\n", screen)
+				return Area{}, 0, false
+			}
+			count = resp.(Response).Params[2]
+		default:
+			logDebug("Ignoring event while setting up:", resp)
+		}
+	}
+
+	logDebugf("OLED size of screen 0x%02X %dx%d\n", screen, size.Width, size.Height)
+
+	return size, count, true
+}
+
+// Check that the device responds as an OLED controller, by setting up the master screen. This is needed where the
+// device can't be told apart by its usage, since a keyboard without raw HID enabled has the same interface.
+// Note that this reads from the device, and must not be used while anything else is reading from it.
+func (oled *OLEDController) Probe() bool {
+	if err := oled.Device.SetNonblocking(false); err != nil {
+		logWarn("Failed to set the device blocking.")
+		return false
+	}
+	_, _, ok := oled.SetUp(Master)
+	return ok
+}
+
+// Try to reopen the device after it has become unreachable, keeping the screens running so that they keep showing the
+// same tags once the device is back. Gives up after a few attempts, or when quit is closed.
+// Note that this reads from the device, and must only be called from the read loop.
+func (oled *OLEDController) Reconnect(quit chan bool) bool {
+	oled.setConnected(false)
+
+	for attempt := 1; attempt <= RECONNECT_ATTEMPTS; attempt++ {
+		select {
+		case <-time.After(RECONNECT_DELAY):
+		case <-quit:
+			return false
+		}
+
+		logInfof("Reconnecting to device (attempt %d/%d).\n", attempt, RECONNECT_ATTEMPTS)
+		device, err := oled.Info.Open()
+		if err != nil {
+			logWarn("Failed to reopen device:", err)
+			continue
+		}
+		if err := device.SetNonblocking(false); err != nil {
+			logWarn("Failed to set the device blocking.")
+			device.Close()
+			continue
+		}
+
+		oled.deviceMutex.Lock()
+		oled.Device.Close()
+		oled.Device = device
+		oled.deviceMutex.Unlock()
+
+		// The keyboard might have been reset, so set it up again. The size is assumed to be the same.
+		if _, _, ok := oled.SetUp(Master); !ok {
+			continue
+		}
+
+		logInfo("Reconnected to device.")
+		oled.setConnected(true)
+		select {
+		case oled.reconnected <- true:
+		default:
+		}
+		return true
+	}
+
+	return false
+}
+
+// Loop setting up and filling the OLED screens. Returns whether the configuration should be reloaded before running
+// again.
+func (oled *OLEDController) Run() (reload bool) {
+	defer func() { oled.Device.Close() }() // The device might be replaced when reconnecting.
 
 	if err := oled.Device.SetNonblocking(false); err != nil {
-		log.Println("Failed to set the device blocking.")
+		logWarn("Failed to set the device blocking.")
 		return
 	}
 
-	// Start by setting up
-	oled.SendCommand(SetUp, Master, nil)
-	resp, _ := oled.ReadResponse()
-	if resp == nil {
-		log.Println("Set up failed.")
+	// Start by setting up, which gives the size of the master screen, and the number of screens.
+	master, count, ok := oled.SetUp(Master)
+	if !ok {
 		return
 	}
-	switch resp.(type) {
-	case Response:
-		oled.Columns = resp.(Response).Params[0]
-		oled.Rows = resp.(Response).Params[1]
-	default:
-		log.Println("Wrong response for set up command.")
-		return
+	oled.Columns = master.Width
+	oled.Rows = master.Height
+	if count < 1 {
+		// Older firmware doesn't report the number of screens.
+		count = DEFAULT_SCREEN_COUNT
 	}
+	oled.Sizes = map[ScreenID]Area{Master: master}
 
-	if *gArgs.debug {
-		log.Printf("OLED size %dx%d\n", oled.Columns, oled.Rows)
+	// The other screens might be of a different size. Older firmware doesn't report it, so assume that they are the
+	// same size as the master screen in that case.
+	for id := ScreenID(1); id < ScreenID(count); id++ {
+		size, _, ok := oled.SetUp(id)
+		if !ok {
+			logDebugf("Assuming that screen 0x%02X is the same size as the master screen.\n", id)
+			size = master
+		}
+		oled.Sizes[id] = size
 	}
-	if oled.Columns < 1 || oled.Rows < 1 {
-		log.Println("Failed to get screen size from set up.")
-		return
+
+	if len(tags) < 1 {
+		logError("There are no tags to show, so the screens will stay blank.")
+	}
+	for id, size := range oled.Sizes {
+		// The command header and the row come before the line.
+		if 3+1+int(size.Width) > oled.reportSize() {
+			logWarnf("Lines of screen 0x%02X don't fit in a report of %d bytes, so they will be cut off.\n", id, oled.reportSize())
+		}
 	}
 
-	sigs := make(chan os.Signal, 1)
+	oled.setConnected(true)
+	defer oled.setConnected(false)
+
+	// Let systemd know that the program is up and running (again).
+	sdNotify("READY=1\nSTATUS=Connected to " + oled.Info.Path)
+
+	sigs := oled.signals
+	if sigs == nil {
+		sigs = make(chan os.Signal, 1)
+	}
 	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
 
 	var wg sync.WaitGroup
 	quit := make(chan bool, 5)
-	masterCtrl := make(chan Event, 1)
-	slaveCtrl := make(chan Event, 1)
+	oled.reconnected = make(chan bool, 1)
+	events := make(map[ScreenID]chan Event, len(oled.Sizes))
+	oled.Responses = make(map[ScreenID]chan Response, len(oled.Sizes))
+	for id := range oled.Sizes {
+		events[id] = make(chan Event, 1)
+		oled.Responses[id] = make(chan Response, 5)
+	}
 
 	// Read loop. Makes sure that responses and events are processed.
+	// Each read blocks for up to the read timeout (regardless of whether the device is nonblocking), which is also the
+	// longest it takes for the loop to notice that it should quit.
+	wg.Add(1)
 	go func() {
-		wg.Add(1)
 		defer wg.Done()
 		for {
 			select {
 			case <-quit:
 				return
 			default:
+				// Reading from the device is the first thing to stop if the program hangs.
+				notifyWatchdog()
+
 				resp, err := oled.ReadResponse()
 				if err != nil {
-					// Read error. Device is probably unreachable.
+					// Read error. Device is probably unreachable. Try to reopen it before tearing everything down.
+					if oled.Reconnect(quit) {
+						continue
+					}
+					oled.setConnected(false)
 					sigs <- syscall.SIGHUP
 					return
 				} else if resp != nil {
 					switch resp.(type) {
+					case Response:
+						// Never block on the responses, since the screens might not be waiting for them.
+						if responses, found := oled.Responses[resp.(Response).Screen]; found {
+							select {
+							case responses <- resp.(Response):
+							default:
+							}
+						}
 					case Event:
-						switch resp.(Event).Screen {
-						case Master:
-							masterCtrl <- resp.(Event)
-						case Slave:
-							slaveCtrl <- resp.(Event)
-						default:
-							log.Printf("Got event 0x%02X for unknown screen 0x%02X.\n",
+						if resp.(Event).Event == Layer {
+							// The layer is the same for the whole keyboard, so let every screen know about it.
+							for id, screenEvents := range events {
+								event := resp.(Event)
+								event.Screen = id
+								screenEvents <- event
+							}
+						} else if screenEvents, found := events[resp.(Event).Screen]; found {
+							screenEvents <- resp.(Event)
+						} else {
+							logWarnf("Got event 0x%02X for unknown screen 0x%02X.\n",
 								resp.(Event).Event,
 								resp.(Event).Screen)
 						}
@@ -391,78 +1146,498 @@ func (oled *OLEDController) Run() {
 	}()
 
 	// Start the handlers for the different screens, and specify which tag to show on them initially.
-	go (&Screen{ID: Master, Controller: oled, Tag: 1, Events: masterCtrl, Quit: quit}).Run(&wg)
-	go (&Screen{ID: Slave, Controller: oled, Tag: 2, Events: slaveCtrl, Quit: quit}).Run(&wg)
+	oled.mutex.Lock()
+	oled.Screens = make([]*Screen, 0, len(oled.Sizes))
+	names := layerNames(*gArgs.layerNames) // Shared by the screens, which only read it.
+	for id := ScreenID(0); id < ScreenID(len(oled.Sizes)); id++ {
+		screen := &Screen{ID: id, Controller: oled, Events: events[id], Quit: quit, LayerNames: names}
+		switch id {
+		case Master:
+			screen.Tag, screen.Rotation = initialTag(*gArgs.masterTag), tagList(*gArgs.masterRotate)
+		case Slave:
+			screen.Tag, screen.Rotation = initialTag(*gArgs.slaveTag), tagList(*gArgs.slaveRotate)
+		default:
+			// Any additional screens show the tag after the one on the previous screen.
+			screen.Tag, _ = cycleTag(oled.Screens[id-1].Tag, true)
+		}
+		if *gArgs.mirror && id != Master {
+			// Show the same tag as the master, and follow it when it changes.
+			screen.Tag, screen.Rotation = oled.Screens[Master].Tag, nil
+			screen.Mirroring = make(chan uint8, 1)
+			oled.Screens[Master].Mirrors = append(oled.Screens[Master].Mirrors, screen.Mirroring)
+		}
+		oled.Screens = append(oled.Screens, screen)
+	}
+	oled.mutex.Unlock()
+	for _, screen := range oled.Screens {
+		wg.Add(1)
+		go screen.Run(&wg)
+	}
 
-	// Wait for signal
-	sig := <-sigs
+	// Set the brightness of the screens, and keep it according to the schedule.
+	if scheduledBrightness(time.Now()) >= 0 {
+		wg.Add(1)
+		go oled.RunBrightness(events, &wg, quit)
+	}
 
-	log.Println("Stopping due to", sig)
-	signal.Reset() // Reset signal handling to terminate in case another one is issued
+	// Show the alert state of the system with the underglow.
+	if *gArgs.alertRGB {
+		wg.Add(1)
+		go oled.RunAlertLight(&wg, quit)
+	}
+
+	// Wait for a signal to stop, handling the control signals and commands in the meantime.
+	var sig os.Signal
+	commands := oled.Commands
+	for sig == nil {
+		select {
+		case sig = <-sigs:
+			switch sig {
+			case NEXT_TAG_SIGNAL:
+				events[Master] <- Event{Event: IncrementTag, Screen: Master}
+				sig = nil
+			case RELOAD_SIGNAL:
+				// Restart with the new configuration, like on SIGHUP. It's read by the caller once everything has
+				// stopped using it.
+			}
+		case event, more := <-commands:
+			if !more {
+				commands = nil
+				continue
+			}
+			// Handle the command like an event from the keyboard.
+			if screenEvents, found := events[event.Screen]; found {
+				screenEvents <- event
+			} else {
+				logWarnf("Got command for unknown screen 0x%02X.\n", event.Screen)
+			}
+		}
+	}
+
+	logInfo("Stopping due to", sig)
+	if sig == syscall.SIGHUP || sig == RELOAD_SIGNAL {
+		sdNotify("STATUS=Waiting for the keyboard")
+	} else {
+		sdNotify("STOPPING=1")
+	}
+	signal.Reset(syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP) // Terminate in case another one is issued
 
 	close(quit)
 	wg.Wait()
 
 	if err := oled.Device.SetNonblocking(true); err == nil {
 		// Consume any lingering messages.
-		// This prevents junk from lying around in the HID pipe, causing failures next run.
-		for {
+		// This prevents junk from lying around in the HID pipe, causing failures next run. A device that keeps sending
+		// data mustn't stall the shutdown, so give up after a while.
+		deadline := time.Now().Add(DRAIN_TIMEOUT)
+		for drained := 0; ; drained++ {
 			if resp, _ := oled.ReadResponse(); resp == nil {
 				break
+			} else if drained >= DRAIN_MAX_MESSAGES || time.Now().After(deadline) {
+				logWarnf("Gave up consuming lingering messages after %d of them.\n", drained+1)
+				break
 			}
 		}
 	}
 
-	if sig == syscall.SIGHUP {
-		return
-	} else {
+	if sig != syscall.SIGHUP && sig != RELOAD_SIGNAL {
 		os.Exit(0)
 	}
+	return sig == RELOAD_SIGNAL
+}
+
+// Set whether the device is connected and working.
+func (oled *OLEDController) setConnected(connected bool) {
+	oled.mutex.Lock()
+	oled.Connected = connected
+	oled.mutex.Unlock()
+}
+
+// Get the tag to show initially on a screen, given its number or name. Falls back to the lowest defined tag if the
+// requested one doesn't exist, or 0 if there are no tags at all.
+func initialTag(requested string) uint8 {
+	if id, found := resolveTag(requested); found {
+		return id
+	}
+
+	ids := sortedTagIDs()
+	if len(ids) < 1 {
+		// Already logged when starting; the screens just stay blank.
+		return 0
+	}
+	lowest := ids[0]
+	logWarnf("Tag '%s' doesn't exist. Using tag %d instead.\n", requested, lowest)
+	return lowest
+}
+
+// Parse a comma-separated list of tag numbers or names, leaving out the ones that don't exist.
+func tagList(list string) []uint8 {
+	var ids []uint8
+	for _, field := range strings.Split(list, ",") {
+		if field = strings.TrimSpace(field); field == "" {
+			continue
+		}
+		id, found := resolveTag(field)
+		if !found {
+			logWarnf("Tag '%s' in rotation doesn't exist.\n", field)
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// Parse a comma-separated list of layer names, each given as <layer>=<name>, leaving out the ones that are invalid.
+func layerNames(list string) map[uint8]string {
+	names := make(map[uint8]string)
+	for _, field := range strings.Split(list, ",") {
+		if field = strings.TrimSpace(field); field == "" {
+			continue
+		}
+		parts := strings.SplitN(field, "=", 2)
+		layer, err := strconv.ParseUint(strings.TrimSpace(parts[0]), 10, 8)
+		if len(parts) != 2 || err != nil {
+			logWarnf("Layer name '%s' is not in the format <layer>=<name>.\n", field)
+			continue
+		}
+		names[uint8(layer)] = ToLatin(strings.TrimSpace(parts[1]))
+	}
+	return names
+}
+
+// Get the name of the active layer of the keyboard, or its number if it hasn't been named.
+func (screen *Screen) layerName() string {
+	if name, found := screen.LayerNames[screen.Layer]; found {
+		return name
+	}
+	return strconv.Itoa(int(screen.Layer))
+}
+
+// Check that the program arguments are valid, and normalize them. Returns an error describing the first bad argument.
+func checkArgs() error {
+	if _, found := LOG_LEVELS[strings.ToLower(*gArgs.logLevel)]; !found {
+		return fmt.Errorf("Bad -log-level: '%s' is not one of debug, info, warn, and error", *gArgs.logLevel)
+	}
+
+	if *gArgs.hidReportSize < MIN_REPORT_SIZE || *gArgs.hidReportSize > MAX_REPORT_SIZE {
+		return fmt.Errorf("Bad -hid-report-size: %d is not within %d-%d", *gArgs.hidReportSize, MIN_REPORT_SIZE, MAX_REPORT_SIZE)
+	}
+
+	if *gArgs.brightness > math.MaxUint8 {
+		return fmt.Errorf("Bad -brightness: %d is not within 0-%d", *gArgs.brightness, math.MaxUint8)
+	}
+
+	if (*gArgs.nightStart == "") != (*gArgs.nightEnd == "") {
+		return errors.New("Both -night-start and -night-end need to be given for the brightness schedule")
+	} else if *gArgs.nightStart != "" {
+		if _, err := parseTimeOfDay(*gArgs.nightStart); err != nil {
+			return fmt.Errorf("Bad -night-start: %v", err)
+		} else if _, err := parseTimeOfDay(*gArgs.nightEnd); err != nil {
+			return fmt.Errorf("Bad -night-end: %v", err)
+		}
+		if *gArgs.nightBrightness < 0 || *gArgs.nightBrightness > math.MaxUint8 {
+			return fmt.Errorf("Bad -night-brightness: %d is not within 0-%d", *gArgs.nightBrightness, math.MaxUint8)
+		}
+	}
+
+	if *gArgs.columns > MAX_COLUMNS || *gArgs.rows > MAX_ROWS {
+		return fmt.Errorf("Bad -columns/-rows: %dx%d is not within %dx%d", *gArgs.columns, *gArgs.rows, MAX_COLUMNS, MAX_ROWS)
+	} else if *gArgs.columns*(*gArgs.rows) > math.MaxUint8+1 {
+		return fmt.Errorf("Bad -columns/-rows: %dx%d is more than the %d characters that can be addressed", *gArgs.columns, *gArgs.rows, math.MaxUint8+1)
+	}
+
+	for name, glyph := range map[string]string{"bar-char": *gArgs.barChar, "bar-left": *gArgs.barLeft, "bar-right": *gArgs.barRight} {
+		if _, err := ParseGlyph(glyph); err != nil {
+			return fmt.Errorf("Bad -%s: %v", name, err)
+		}
+	}
+
+	// The tags switch to the next item after these, so they would be switching constantly otherwise.
+	rotations := map[string]time.Duration{
+		"ticker-rotation":  *gArgs.tickerRotation,
+		"message-rotation": *gArgs.messageRotation,
+		"rss-rotation":     *gArgs.rssRotation,
+	}
+	for name, duration := range rotations {
+		if duration <= 0 {
+			return fmt.Errorf("Bad -%s: %v is not a positive duration", name, duration)
+		}
+	}
+
+	if *gArgs.smoothing < 0 || *gArgs.smoothing >= 1 {
+		return fmt.Errorf("Bad -smoothing: %g is not within 0-1 (exclusive)", *gArgs.smoothing)
+	}
+
+	unit, err := ParseTemperatureUnit(*gArgs.temperatureUnit)
+	if err != nil {
+		return fmt.Errorf("Bad -temperature-unit: %v", err)
+	}
+	*gArgs.temperatureUnit = unit
+
+	switch *gArgs.weatherProvider = strings.ToLower(*gArgs.weatherProvider); *gArgs.weatherProvider {
+	case OPEN_WEATHER_MAP, OPEN_METEO:
+	default:
+		return fmt.Errorf("Bad -weather-provider: '%s' is not one of %s and %s", *gArgs.weatherProvider, OPEN_WEATHER_MAP, OPEN_METEO)
+	}
+	if math.Abs(*gArgs.weatherLatitude) > 90 || math.Abs(*gArgs.weatherLongitude) > 180 {
+		return fmt.Errorf("Bad -weather-lat/-weather-lon: %f,%f is not a valid position", *gArgs.weatherLatitude, *gArgs.weatherLongitude)
+	}
+
+	if *gArgs.mqttQoS > 2 {
+		logWarnf("Invalid MQTT QoS level %d. Using 0 instead.\n", *gArgs.mqttQoS)
+		*gArgs.mqttQoS = 0
+	}
+
+	setLogSettings()
+	return nil
+}
+
+// Define the flags in the specified set, with the defaults given by the configuration, and point the arguments at
+// them.
+func defineFlags(flags *flag.FlagSet, config Config) {
+	gArgs.debug = flags.Bool("debug", config.Debug, "Whether debug output should be produced (the same as -log-level debug)")
+	gArgs.logLevel = flags.String("log-level", config.LogLevel, "The lowest level of the messages to log: debug, info, warn, or error")
+	gArgs.logJSON = flags.Bool("log-json", config.LogJSON, "Whether to log one JSON object per message, e.g. when running as a service")
+
+	gArgs.hidReportSize = flags.Uint("hid-report-size", config.HIDReportSize, "The size of the raw HID reports used by the firmware, in bytes")
+	gArgs.readTimeout = flags.Duration("read-timeout", config.ReadTimeout, "How long to wait for a message from the keyboard in each read")
+	gArgs.writeRetries = flags.Uint("write-retries", config.WriteRetries, "How many times to retry writing a command to the keyboard before giving up")
+
+	gArgs.brightness = flags.Int("brightness", config.Brightness, "The brightness of the screens (0-255), or -1 to leave it unchanged")
+
+	gArgs.nightStart = flags.String("night-start", config.NightStart, "The time (HH:MM) at which to dim the screens for the night (none if empty)")
+	gArgs.nightEnd = flags.String("night-end", config.NightEnd, "The time (HH:MM) at which to stop dimming the screens")
+	gArgs.nightBrightness = flags.Int("night-brightness", config.NightBrightness, "The brightness of the screens (0-255) during the night")
+
+	gArgs.enabledTags = flags.String("enabled-tags", config.EnabledTags, "Comma-separated list of the tags (numbers or names) that can be shown (all if empty)")
+	gArgs.masterTag = flags.String("master-tag", string(config.MasterTag), "The tag (number or name) to show initially on the master screen")
+	gArgs.slaveTag = flags.String("slave-tag", string(config.SlaveTag), "The tag (number or name) to show initially on the slave screen")
+	gArgs.masterRotate = flags.String("master-rotate", config.MasterRotate, "Comma-separated list of tags to cycle through on the master screen")
+	gArgs.slaveRotate = flags.String("slave-rotate", config.SlaveRotate, "Comma-separated list of tags to cycle through on the slave screen")
+	gArgs.mirror = flags.Bool("mirror", config.Mirror, "Whether the slave screen should always show the same tag as the master screen")
+	gArgs.layerNames = flags.String("layer-names", config.LayerNames, "Comma-separated list of names of the keyboard layers, e.g. \"0=base,1=nav\"")
+	gArgs.rotateInterval = flags.Duration("rotate-interval", config.RotateInterval, "How long to show each tag when cycling through them")
+
+	gArgs.barChar = flags.String("bar-char", config.BarChar, "The character filling the bars, as is or escape-coded (e.g. '\\x7F')")
+	gArgs.barLeft = flags.String("bar-left", config.BarLeft, "The character to the left of the bars, as is or escape-coded")
+	gArgs.barRight = flags.String("bar-right", config.BarRight, "The character to the right of the bars, as is or escape-coded")
+	gArgs.smoothing = flags.Float64("smoothing", config.Smoothing, "How much of the previous value to keep when smoothing the system and GPU bars (0 to disable, up to 1)")
+
+	gArgs.once = flags.Bool("once", false, "Draw each tag once to stdout and exit, without using the keyboard")
+	gArgs.columns = flags.Uint("columns", config.Columns, "The number of columns of the screens, overriding what the firmware reports (as reported if 0)")
+	gArgs.rows = flags.Uint("rows", config.Rows, "The number of rows of the screens, overriding what the firmware reports (as reported if 0)")
+
+	if runtime.GOOS == "linux" {
+		gArgs.sysStatDisk = flags.String("sysstat-disk", config.SysStatDisk, "Which disk(s) to monitor for I/O usage, as a comma-separated list")
+	}
+	gArgs.cpuPerCore = flags.Bool("cpu-per-core", config.CPUPerCore, "Whether to show the usage of each CPU core instead of the system status (Linux and Windows only)")
+	gArgs.sysStatInterval = flags.Duration("sysstat-interval", config.SysStatInterval, "How often to get the system status")
+	gArgs.cpuAlert = flags.Float64("cpu-alert", config.CPUAlert, "The CPU usage in percent at which to mark it (0 to disable)")
+	gArgs.cpuTempAlert = flags.Float64("cpu-temp-alert", config.CPUTempAlert, "The CPU temperature (in -temperature-unit) at which to mark it (0 to disable)")
+	gArgs.cpuTempSensor = flags.String("cpu-temp-sensor", config.CPUTempSensor, "The name of the hardware monitor giving the CPU temperature, if autodetection fails (Linux only)")
+
+	gArgs.fanSensors = flags.String("fan-sensors", config.FanSensors, "Comma-separated list of fans to show the speed of (all if empty) (Linux only)")
+	gArgs.diskHealthDevices = flags.String("disk-health-devices", config.DiskHealthDevices, "Comma-separated list of drives to show the health of, e.g. 'sda,nvme0n1' (all if empty) (Linux only)")
+	gArgs.fanMaxRPM = flags.Float64("fan-max-rpm", config.FanMaxRPM, "The fan speed in RPM that fills the bars")
+
+	gArgs.historyMetric = flags.String("history-metric", config.HistoryMetric, "The metric to show over time (cpu/mem/gpu/net)")
+
+	gArgs.diskSpaceMounts = flags.String("diskspace-mounts", config.DiskSpaceMounts, "Comma-separated list of mount points (or drives) to show disk space for")
+
+	gArgs.netInterface = flags.String("net-interface", config.NetInterface, "The network interface to show throughput for (all if empty)")
+	gArgs.netMaxMbps = flags.Float64("net-max-mbps", config.NetMaxMbps, "The network throughput in Mbit/s that fills the bars")
+
+	gArgs.pingHost = flags.String("ping-host", config.PingHost, "The host to measure the round-trip time to")
+	gArgs.showPublicIP = flags.Bool("show-public-ip", config.ShowPublicIP, "Whether to show the public IP address, which is fetched from ipify.org")
+
+	gArgs.dockerSocket = flags.String("docker-socket", config.DockerSocket, "The path to the socket of the Docker daemon")
+
+	gArgs.gpuVendor = flags.String("gpu-vendor", config.GPUVendor, "The vendor of the graphics card to monitor (nvidia/amd/intel)")
+	gArgs.gpuAlert = flags.Float64("gpu-alert", config.GPUAlert, "The GPU usage in percent at which to mark it (0 to disable)")
+	gArgs.gpuTempAlert = flags.Float64("gpu-temp-alert", config.GPUTempAlert, "The GPU temperature (in -temperature-unit) at which to mark it (0 to disable)")
+	gArgs.gpuInterval = flags.Duration("gpu-interval", config.GPUInterval, "How often to get the status of the graphics card")
+
+	gArgs.temperatureUnit = flags.String("temperature-unit", config.TemperatureUnit, "Temperature unit to use (C/F/K)")
+	gArgs.alertRGB = flags.Bool("alert-rgb", config.AlertRGB, "Whether to turn the RGB underglow red when a metric crosses its alert threshold, and green otherwise (requires firmware support)")
+	gArgs.tempTrend = flags.Bool("temp-trend", config.TempTrend, "Whether to show an arrow with the trend of the weather and GPU temperatures (requires the arrow glyphs in the font)")
+
+	gArgs.gmailCredentials = flags.String("gmail-credentials", config.GmailCredentials, "Path to JSON credential file for GMail access")
+	gArgs.gmailLabel = flags.String("gmail-label", config.GmailLabel, "For which label to count unread messages")
+	gArgs.gmailInterval = flags.Duration("gmail-interval", config.GmailInterval, "How often to get the number of unread messages from GMail")
+	gArgs.gmailToken = flags.String("gmail-token", config.GmailToken, "Path to the file caching the GMail OAuth token (token.json in the configuration directory if empty)")
+	gArgs.gmailAuthCode = flags.String("gmail-auth-code", config.GmailAuthCode, "The authorization code to exchange for a GMail OAuth token, instead of prompting for it")
+	gArgs.calendarID = flags.String("calendar-id", config.CalendarID, "The ID of the Google Calendar to show upcoming events for")
+
+	gArgs.imapServer = flags.String("imap-server", config.IMAPServer, "The IMAP server to get unread messages from as '<host>:<port>'")
+	gArgs.imapUser = flags.String("imap-user", config.IMAPUser, "The user name for the IMAP server")
+	gArgs.imapPassword = flags.String("imap-pass", config.IMAPPassword, "The password for the IMAP server")
+	gArgs.imapMailbox = flags.String("imap-mailbox", config.IMAPMailbox, "For which mailbox to count unread messages")
+
+	gArgs.weatherProvider = flags.String("weather-provider", config.WeatherProvider, "The provider of the current weather (openweathermap/open-meteo)")
+	gArgs.weatherKey = flags.String("weather-api-key", config.WeatherKey, "API key to openweathermap.org")
+	gArgs.weatherLocation = flags.String("weather-location", config.WeatherLocation, "The location to get the current weather as '<city>,<country>'")
+	gArgs.weatherLatitude = flags.Float64("weather-lat", config.WeatherLatitude, "The latitude of the location to get the current weather for (Open-Meteo only), and the times of the sun for")
+	gArgs.weatherLongitude = flags.Float64("weather-lon", config.WeatherLongitude, "The longitude of the location to get the current weather for (Open-Meteo only), and the times of the sun for")
+	gArgs.weatherInterval = flags.Duration("weather-interval", config.WeatherInterval, "How often to get the current weather")
+	gArgs.weatherForecast = flags.Uint("weather-forecast", config.WeatherForecast, "The number of forecast entries, three hours apart, to show (0 to disable)")
+
+	gArgs.spotifyClientID = flags.String("spotify-client-id", config.SpotifyClientID, "The client ID of the Spotify app")
+	gArgs.spotifyClientSecret = flags.String("spotify-client-secret", config.SpotifyClientSecret, "The client secret of the Spotify app")
+
+	gArgs.tickerSymbols = flags.String("ticker-symbols", config.TickerSymbols, "Comma-separated list of coins or stocks to show prices for")
+	gArgs.tickerProvider = flags.String("ticker-provider", config.TickerProvider, "The provider of the prices (coingecko/yahoo)")
+	gArgs.tickerRotation = flags.Duration("ticker-rotation", config.TickerRotation, "How long to show each price")
+
+	gArgs.clockFormat = flags.String("clock-format", config.ClockFormat, "The format of the time on the clock, as a Go time layout")
+	gArgs.clockTimezone = flags.String("clock-timezone", config.ClockTimezone, "A second timezone to show on the clock (e.g. 'America/New_York')")
+
+	gArgs.timerLength = flags.Duration("timer-length", config.TimerLength, "The length of the countdown timer, until changed from the keyboard")
+
+	gArgs.messageFile = flags.String("message-file", config.MessageFile, "A file with messages to show, one per line")
+	gArgs.messageRotation = flags.Duration("message-rotation", config.MessageRotation, "How long to show each message")
+
+	gArgs.splashText = flags.String("splash-text", config.SplashText, "Text to show on the screens while waiting for the tags to be drawn (none if empty)")
+	gArgs.clearOnExit = flags.Bool("clear-on-exit", config.ClearOnExit, "Whether to clear the screens when stopping, instead of leaving the last content on them")
+	gArgs.exitText = flags.String("exit-text", config.ExitText, "Text to show on the screens when stopping, instead of clearing them (none if empty)")
+
+	gArgs.rssURL = flags.String("rss-url", config.RSSURL, "The URL of an RSS or Atom feed to show headlines from")
+	gArgs.rssRotation = flags.Duration("rss-rotation", config.RSSRotation, "How long to show each headline")
+
+	gArgs.httpAddr = flags.String("http-addr", config.HTTPAddr, "The address on which to serve the controller status as JSON (e.g. ':8080')")
+
+	gArgs.stdinControl = flags.Bool("stdin-control", config.StdinControl, "Whether to read commands controlling the screens from stdin, as JSON")
+
+	gArgs.mqttBroker = flags.String("mqtt-broker", config.MQTTBroker, "The MQTT broker to publish statistics to (e.g. 'tcp://localhost:1883')")
+	gArgs.mqttPrefix = flags.String("mqtt-prefix", config.MQTTPrefix, "The prefix of the published MQTT topics")
+	gArgs.mqttQoS = flags.Uint("mqtt-qos", config.MQTTQoS, "The MQTT quality of service level (0/1/2)")
 }
 
 // Main function, which handles flags and looks for the correct USB HID device.
 func main() {
 	log.SetPrefix("oled_controller ")
-	log.Println("Started.")
+	logInfo("Started.")
 
-	gArgs.debug = flag.Bool("debug", false, "Whether debug output should be produced")
+	// The configuration file provides the defaults, which can be overridden by the flags.
+	defineFlags(flag.CommandLine, loadConfig())
+	flag.Parse()
+	if err := checkArgs(); err != nil {
+		logFatal(err)
+	}
+	enableTags(*gArgs.enabledTags)
 
-	if runtime.GOOS == "linux" {
-		gArgs.sysStatDisk = flag.String("sysstat-disk", "sda", "Which disk to monitor for I/O usage")
+	if *gArgs.once {
+		area := Area{Width: DRY_RUN_COLUMNS, Height: DRY_RUN_ROWS}
+		if *gArgs.columns > 0 {
+			area.Width = uint8(*gArgs.columns)
+		}
+		if *gArgs.rows > 0 {
+			area.Height = uint8(*gArgs.rows)
+		}
+		DryRun(area)
+		return
+	}
+
+	// The status is served while looking for the keyboard as well, so that it can tell that it isn't connected.
+	var statusServer *StatusServer
+	if *gArgs.httpAddr != "" {
+		statusServer = StartStatusServer(*gArgs.httpAddr)
 	}
 
-	gArgs.temperatureUnit = flag.String("temperature-unit", "C", "Temperature unit to use (C/F/K)")
+	stopPublishing := StartPublishing()
 
-	gArgs.gmailCredentials = flag.String("gmail-credentials", "", "Path to JSON credential file for GMail access")
-	gArgs.gmailLabel = flag.String("gmail-label", "INBOX", "For which label to count unread messages")
+	var commands chan Event
+	if *gArgs.stdinControl {
+		commands = ReadCommands(os.Stdin)
+	}
 
-	gArgs.weatherKey = flag.String("weather-api-key", "", "API key to openweathermap.org")
-	gArgs.weatherLocation = flag.String("weather-location", "", "The location to get the current weather as '<city>,<country>'")
+	// The control signals are registered once, so that they're queued while looking for the keyboard, instead of
+	// terminating the program.
+	signals := make(chan os.Signal, 5)
+	if controls := controlSignals(); len(controls) > 0 {
+		signal.Notify(signals, controls...)
+	}
 
-	flag.Parse()
+	// The configuration is reloaded once everything using it has stopped. The statistics are published with the
+	// settings they were started with, so they're stopped as well, and started again afterwards.
+	reload := func() {
+		stopPublishing()
+		if !waitForSources(SOURCES_STOP_TIMEOUT) {
+			logError("Not reloading the configuration, since some of the data sources haven't stopped.")
+		} else if err := reloadConfig(flag.CommandLine); err != nil {
+			logError("Not reloading the configuration:", err)
+		} else {
+			enableTags(*gArgs.enabledTags)
+			logInfo("Reloaded the configuration.")
+		}
+		stopPublishing = StartPublishing()
+	}
 
+	delay := RETRY_MIN_DELAY
+	backOff := func() {
+		delay = delay * RETRY_BACKOFF
+		if delay > RETRY_MAX_DELAY {
+			delay = RETRY_MAX_DELAY
+		}
+	}
 	for {
+		notifyWatchdog()
 		for _, devInfo := range hid.Enumerate(VENDOR_ID, PRODUCT_ID) {
+			// Usage and UsagePage are only supported on Windows/Mac. On Linux, the interface number will also match a
+			// keyboard without raw HID enabled, so the device is probed before being used.
+			probe := runtime.GOOS == "linux"
 			found := false
-			if runtime.GOOS != "linux" {
+			if !probe {
 				found = devInfo.Usage == USAGE && devInfo.UsagePage == USAGE_PAGE
 			} else {
-				// FIXME: This check is weak, and will match a keyboard without raw HID enabled...
-				//        Usage and UsagePage are only supported on Windows/Mac.
 				found = devInfo.Interface == INTERFACE
 			}
 
 			if found {
-				log.Println("Found device at:", devInfo.Path, devInfo.Usage, devInfo.UsagePage)
+				logInfo("Found device at:", devInfo.Path, devInfo.Usage, devInfo.UsagePage)
 				device, err := devInfo.Open()
 				if err != nil {
-					log.Println("Failed to open device:", err)
-				} else {
-					oled := OLEDController{Device: device}
-					oled.Run()
+					backOff()
+					logWarnf("Failed to open device: %v (retrying in %v)\n", err, delay)
+					continue
 				}
+
+				oled := OLEDController{
+					Device:       device,
+					Info:         devInfo,
+					ReportSize:   int(*gArgs.hidReportSize),
+					ReadTimeout:  *gArgs.readTimeout,
+					WriteRetries: int(*gArgs.writeRetries),
+					SizeOverride: Area{Width: uint8(*gArgs.columns), Height: uint8(*gArgs.rows)},
+					Commands:     commands,
+					signals:      signals,
+				}
+				if probe && !oled.Probe() {
+					device.Close()
+					backOff()
+					logWarnf("Device at %s doesn't respond as an OLED controller. Is raw HID enabled in the firmware? (retrying in %v)\n", devInfo.Path, delay)
+					continue
+				}
+
+				delay = RETRY_MIN_DELAY
+				if statusServer != nil {
+					statusServer.SetController(&oled)
+				}
+				reloadRequested := oled.Run()
+				if statusServer != nil {
+					statusServer.SetController(nil)
+				}
+				if reloadRequested {
+					reload()
+				}
+			}
+		}
+		sleepNotifying(delay)
+
+		// There is no tag to switch without the keyboard, but the configuration can still be reloaded.
+		for len(signals) > 0 {
+			if <-signals == RELOAD_SIGNAL {
+				reload()
 			}
 		}
-		time.Sleep(2 * time.Second)
 	}
 }
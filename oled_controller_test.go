@@ -0,0 +1,573 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+// Tests of the communication with the OLED controller.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEncodeCommand(t *testing.T) {
+	tests := []struct {
+		name       string
+		reportSize int
+		cmd        CommandID
+		screen     ScreenID
+		data       []byte
+		want       []byte
+	}{
+		{
+			name:       "padded to the report size",
+			reportSize: 8,
+			cmd:        SetLine,
+			screen:     Master,
+			data:       []byte{0x01, 'h', 'i'},
+			want:       []byte{CommandMsg, SetLine, Master, 0x01, 'h', 'i', 0x00, 0x00},
+		},
+		{
+			name:       "over-long data is truncated",
+			reportSize: 6,
+			cmd:        SetChars,
+			screen:     Slave,
+			data:       []byte{0x00, 0x05, 'a', 'b', 'c', 'd', 'e'},
+			want:       []byte{CommandMsg, SetChars, Slave, 0x00, 0x05, 'a'},
+		},
+		{
+			name:       "data filling the report exactly",
+			reportSize: 5,
+			cmd:        SetContrast,
+			screen:     Master,
+			data:       []byte{0xAB, 0xCD},
+			want:       []byte{CommandMsg, SetContrast, Master, 0xAB, 0xCD},
+		},
+		{
+			name:       "screen and command bytes",
+			reportSize: 4,
+			cmd:        CommandID(0x7E),
+			screen:     ScreenID(0x03),
+			data:       []byte{0xFF},
+			want:       []byte{CommandMsg, 0x7E, 0x03, 0xFF},
+		},
+		{
+			name:       "no data",
+			reportSize: 4,
+			cmd:        Present,
+			screen:     Slave,
+			data:       nil,
+			want:       []byte{CommandMsg, Present, Slave, 0x00},
+		},
+		{
+			name:       "empty data",
+			reportSize: 4,
+			cmd:        Clear,
+			screen:     Master,
+			data:       []byte{},
+			want:       []byte{CommandMsg, Clear, Master, 0x00},
+		},
+		{
+			name:       "default report size",
+			reportSize: DEFAULT_REPORT_SIZE,
+			cmd:        SetUp,
+			screen:     Master,
+			data:       nil,
+			want:       append([]byte{CommandMsg, SetUp, Master}, make([]byte, DEFAULT_REPORT_SIZE-3)...),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := encodeCommand(test.reportSize, test.cmd, test.screen, test.data)
+			if !bytes.Equal(got, test.want) {
+				t.Errorf("encodeCommand(%d, 0x%02X, 0x%02X, % X) = % X, want % X",
+					test.reportSize, test.cmd, test.screen, test.data, got, test.want)
+			}
+		})
+	}
+}
+
+func TestSendCommand(t *testing.T) {
+	dev := newFakeDevice()
+	oled := &OLEDController{Device: dev, ReportSize: 8}
+
+	if !oled.SendCommand(SetLine, Slave, []byte{0x01, 'a', 'b'}) {
+		t.Fatal("SendCommand() failed")
+	}
+	want := [][]byte{{CommandMsg, SetLine, Slave, 0x01, 'a', 'b', 0x00, 0x00}}
+	if got := dev.Written(); !reportsEqual(got, want) {
+		t.Errorf("SendCommand() wrote % X, want % X", got, want)
+	}
+}
+
+func TestSendCommandRetries(t *testing.T) {
+	tests := []struct {
+		name        string
+		retries     int
+		writeErrors int
+		want        bool
+	}{
+		{"no failures", 0, 0, true},
+		{"recovers within the retries", 2, 2, true},
+		{"gives up after the retries", 2, 3, false},
+		{"no retries", 0, 1, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dev := newFakeDevice()
+			dev.writeErrors = test.writeErrors
+			oled := &OLEDController{Device: dev, ReportSize: 4, WriteRetries: test.retries}
+
+			if got := oled.SendCommand(Present, Master, nil); got != test.want {
+				t.Errorf("SendCommand() = %v, want %v", got, test.want)
+			}
+			if written := len(dev.Written()); test.want && written != 1 {
+				t.Errorf("SendCommand() wrote %d reports, want 1", written)
+			} else if !test.want && written != 0 {
+				t.Errorf("SendCommand() wrote %d reports, want none", written)
+			}
+		})
+	}
+}
+
+func TestDrawScreen(t *testing.T) {
+	dev := newFakeDevice()
+	oled := &OLEDController{Device: dev, ReportSize: 8, Sizes: map[ScreenID]Area{Master: {Width: 4, Height: 2}}}
+
+	// The third line doesn't fit on the screen, and the NUL byte would end the line early in the firmware.
+	if !oled.DrawScreen(Master, []string{"ab", "c\x00d", "ef"}) {
+		t.Fatal("DrawScreen() failed")
+	}
+	want := [][]byte{
+		{CommandMsg, SetLine, Master, 0x00, 'a', 'b', 0x00, 0x00},
+		{CommandMsg, SetLine, Master, 0x01, 'c', ' ', 'd', 0x00},
+		{CommandMsg, Present, Master, 0x00, 0x00, 0x00, 0x00, 0x00},
+	}
+	if got := dev.Written(); !reportsEqual(got, want) {
+		t.Errorf("DrawScreen() wrote % X, want % X", got, want)
+	}
+}
+
+// The smallest report size used to leave no room for the bitmap, so that splitting it never ended.
+func TestBitmapChunks(t *testing.T) {
+	data := make([]byte, 100)
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	for _, reportSize := range []int{MIN_REPORT_SIZE, DEFAULT_REPORT_SIZE, MAX_REPORT_SIZE} {
+		var joined []byte
+		for _, chunk := range bitmapChunks(reportSize, 1, 2, data) {
+			if 3+len(chunk) > reportSize {
+				t.Errorf("bitmapChunks(%d) made a chunk of %d bytes, which doesn't fit in a report", reportSize, len(chunk))
+			}
+			if offset := int(chunk[2]) | int(chunk[3])<<8; offset != len(joined) || int(chunk[4]) != len(chunk)-5 {
+				t.Errorf("bitmapChunks(%d) made a chunk at offset %d of %d bytes, want offset %d of %d bytes",
+					reportSize, offset, chunk[4], len(joined), len(chunk)-5)
+			}
+			joined = append(joined, chunk[5:]...)
+		}
+		if !bytes.Equal(joined, data) {
+			t.Errorf("bitmapChunks(%d) split the bitmap into % X, want % X", reportSize, joined, data)
+		}
+	}
+}
+
+func TestReadResponse(t *testing.T) {
+	tests := []struct {
+		name   string
+		report []byte
+		want   interface{}
+	}{
+		{
+			name:   "successful response",
+			report: responseReport(true, SetUp, Slave, 21, 4, 2),
+			want:   Response{Success: true, Command: SetUp, Screen: Slave, Params: []byte{21, 4, 2}},
+		},
+		{
+			name:   "failed response",
+			report: responseReport(false, SetLine, Master, byte(ErrorLineOutOfRange)),
+			want:   Response{Success: false, Command: SetLine, Screen: Master, Params: []byte{byte(ErrorLineOutOfRange)}},
+		},
+		{
+			name:   "event",
+			report: eventReport(ChangeTag, Slave, 5),
+			want:   Event{Event: ChangeTag, Screen: Slave, Params: []byte{5}},
+		},
+		{
+			name:   "unknown message",
+			report: []byte{0x42, 0x00, 0x00},
+			want:   nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dev := newFakeDevice()
+			dev.Queue(test.report)
+			oled := &OLEDController{Device: dev, ReadTimeout: 10 * time.Millisecond}
+
+			got, err := oled.ReadResponse()
+			if err != nil {
+				t.Fatal("ReadResponse() failed:", err)
+			}
+			if !messagesEqual(got, test.want) {
+				t.Errorf("ReadResponse() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+
+	t.Run("timeout", func(t *testing.T) {
+		oled := &OLEDController{Device: newFakeDevice(), ReadTimeout: 10 * time.Millisecond}
+		if got, err := oled.ReadResponse(); got != nil || err != nil {
+			t.Errorf("ReadResponse() = %v, %v, want nil, nil", got, err)
+		}
+	})
+
+	t.Run("read error", func(t *testing.T) {
+		dev := newFakeDevice()
+		dev.SetReadError(errors.New("unplugged"))
+		oled := &OLEDController{Device: dev, ReadTimeout: 10 * time.Millisecond}
+		if got, err := oled.ReadResponse(); got != nil || err == nil {
+			t.Errorf("ReadResponse() = %v, %v, want nil and an error", got, err)
+		}
+	})
+}
+
+func TestResponseErr(t *testing.T) {
+	tests := []struct {
+		name string
+		resp Response
+		want error
+	}{
+		{
+			name: "success",
+			resp: Response{Success: true, Command: SetLine, Screen: Master, Params: []byte{byte(ErrorBusy)}},
+			want: nil,
+		},
+		{
+			name: "no parameters",
+			resp: Response{Command: SetLine, Screen: Slave},
+			want: &CommandError{Command: SetLine, Screen: Slave, Code: ErrorUnspecified},
+		},
+		{
+			name: "error code",
+			resp: Response{Command: SetLine, Screen: Master, Params: []byte{byte(ErrorLineOutOfRange), 0x00, 0x00}},
+			want: &CommandError{Command: SetLine, Screen: Master, Code: ErrorLineOutOfRange},
+		},
+		{
+			name: "error code and detail",
+			resp: Response{Command: SetChars, Screen: Master, Params: append([]byte{byte(ErrorOutOfRange)}, "start 300\x00junk"...)},
+			want: &CommandError{Command: SetChars, Screen: Master, Code: ErrorOutOfRange, Detail: "start 300"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := test.resp.Err()
+			if test.want == nil {
+				if got != nil {
+					t.Errorf("Err() = %v, want nil", got)
+				}
+				return
+			}
+			if cmdErr, ok := got.(*CommandError); !ok || *cmdErr != *test.want.(*CommandError) {
+				t.Errorf("Err() = %#v, want %#v", got, test.want)
+			}
+		})
+	}
+
+	err := &CommandError{Command: SetLine, Screen: Master, Code: ErrorLineOutOfRange, Detail: "row 9"}
+	if want := "command 0x02 on screen 0x00 failed: line index out of range (row 9)"; err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+	if got, want := ErrorCode(0x7F).String(), "error 0x7F"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestDrawScreenFailure(t *testing.T) {
+	dev := newFakeDevice()
+	dev.respond = func(report []byte) [][]byte {
+		if CommandID(report[1]) == SetLine && report[3] == 1 {
+			return [][]byte{responseReport(false, SetLine, ScreenID(report[2]), byte(ErrorLineOutOfRange))}
+		}
+		return [][]byte{responseReport(true, CommandID(report[1]), ScreenID(report[2]))}
+	}
+	oled := &OLEDController{
+		Device:      dev,
+		ReadTimeout: 10 * time.Millisecond,
+		Sizes:       map[ScreenID]Area{Master: {Width: 4, Height: 2}},
+		Responses:   map[ScreenID]chan Response{Master: make(chan Response, 5)},
+	}
+	stop := pumpResponses(oled)
+	defer stop()
+
+	err := oled.ExecuteCommand(SetLine, Master, []byte{0x01, 'x'})
+	if cmdErr, ok := err.(*CommandError); !ok || cmdErr.Code != ErrorLineOutOfRange {
+		t.Errorf("ExecuteCommand() = %v, want a line index out of range error", err)
+	}
+	if err := oled.ExecuteCommand(SetLine, Master, []byte{0x00, 'x'}); err != nil {
+		t.Errorf("ExecuteCommand() = %v, want success", err)
+	}
+
+	// The frame mustn't be presented when a line fails.
+	if oled.DrawScreen(Master, []string{"ab", "cd"}) {
+		t.Error("DrawScreen() succeeded, want failure")
+	}
+	if presents := dev.WrittenCommands(Present); len(presents) != 0 {
+		t.Errorf("DrawScreen() presented the screen %d times after a failure", len(presents))
+	}
+}
+
+// Read responses from the device and pass them on to whoever waits for them, like the read loop of Run. Returns a
+// function stopping it.
+func pumpResponses(oled *OLEDController) func() {
+	quit := make(chan bool)
+	done := make(chan bool)
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-quit:
+				return
+			default:
+				if resp, _ := oled.ReadResponse(); resp != nil {
+					if resp, ok := resp.(Response); ok {
+						oled.Responses[resp.Screen] <- resp
+					}
+				}
+			}
+		}
+	}()
+	return func() {
+		close(quit)
+		<-done
+	}
+}
+
+// Check whether two lists of reports are the same.
+func reportsEqual(a, b [][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Check whether two messages read from the device are the same, ignoring the padding of the parameters.
+func messagesEqual(a, b interface{}) bool {
+	trim := func(params []byte) []byte { return bytes.TrimRight(params, "\x00") }
+	switch a := a.(type) {
+	case Response:
+		b, ok := b.(Response)
+		return ok && a.Success == b.Success && a.Command == b.Command && a.Screen == b.Screen &&
+			bytes.Equal(trim(a.Params), trim(b.Params))
+	case Event:
+		b, ok := b.(Event)
+		return ok && a.Event == b.Event && a.Screen == b.Screen && bytes.Equal(trim(a.Params), trim(b.Params))
+	default:
+		return a == b
+	}
+}
+
+func TestRunJunkFlood(t *testing.T) {
+	useTags(t, map[uint8]Tag{
+		1: &fakeTag{lines: []string{"one"}},
+		2: &fakeTag{lines: []string{"two"}},
+		3: &fakeTag{lines: []string{"three"}},
+	})
+	dev := newFakeDevice()
+	dev.respond = keyboardResponder(Area{Width: 21, Height: 4}, 2)
+	oled, stop := runController(t, dev)
+
+	// Flood the read loop with junk, followed by a valid event.
+	for i := 0; i < 500; i++ {
+		dev.Queue([]byte{byte(0x42 + i%0x40), byte(i), byte(i >> 8)})
+	}
+	dev.Queue(eventReport(ChangeTag, Master, 3))
+
+	waitFor(t, "the tag to change", func() bool {
+		oled.mutex.Lock()
+		defer oled.mutex.Unlock()
+		return oled.Screens[Master].Tag == 3
+	})
+	waitFor(t, "the new tag to be drawn", func() bool {
+		for _, report := range dev.WrittenCommands(SetLine) {
+			if ScreenID(report[2]) == Master && bytes.HasPrefix(report[4:], []byte("three")) {
+				return true
+			}
+		}
+		return false
+	})
+
+	// The device keeps sending messages while stopping, which mustn't stall the shutdown.
+	flooding := make(chan bool)
+	go func() {
+		for {
+			select {
+			case <-flooding:
+				return
+			case dev.reports <- responseReport(true, Present, Master):
+			}
+		}
+	}()
+	defer close(flooding)
+
+	start := time.Now()
+	stop()
+	if elapsed := time.Since(start); elapsed > DRAIN_TIMEOUT+2*time.Second {
+		t.Errorf("Stopping took %v while flooded with junk.", elapsed)
+	}
+}
+
+// Run with -race, this checks that the screens, the tags, and the read loop don't share state unsafely.
+func TestRunConcurrently(t *testing.T) {
+	useTags(t, map[uint8]Tag{
+		1: &fakeTag{lines: []string{"one", "%l"}, interval: time.Millisecond},
+		2: &fakeTag{lines: []string{"two"}, interval: 2 * time.Millisecond},
+		3: &fakeTag{lines: []string{"three"}},
+		5: &fakeTag{lines: []string{"five", "5"}, interval: time.Millisecond},
+	})
+	dev := newFakeDevice()
+	dev.respond = keyboardResponder(Area{Width: 21, Height: 4}, 3)
+	oled, stop := runController(t, dev)
+
+	var wg sync.WaitGroup
+	quit := make(chan bool)
+	send := func(report []byte) {
+		select {
+		case dev.reports <- report:
+		case <-quit:
+		}
+	}
+
+	// Change the tags on all screens from the keyboard.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			screen := ScreenID(i % 3)
+			switch i % 6 {
+			case 0:
+				send(eventReport(IncrementTag, screen))
+			case 1:
+				send(eventReport(DecrementTag, screen))
+			case 2:
+				send(eventReport(ChangeTag, screen, byte(i%6)))
+			case 3:
+				send(eventReport(Layer, screen, byte(i%4)))
+			case 4:
+				send(eventReport(Brightness, screen, byte(i)))
+			case 5:
+				send(eventReport(WPM, screen, byte(i%100)))
+			}
+			select {
+			case <-quit:
+				return
+			case <-time.After(time.Millisecond):
+			}
+		}
+	}()
+
+	// Read the state like the status server.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			status := oled.Status()
+			if len(status.Screens) != 3 {
+				t.Errorf("Status() has %d screens, want 3", len(status.Screens))
+			}
+			select {
+			case <-quit:
+				return
+			case <-time.After(time.Millisecond):
+			}
+		}
+	}()
+
+	time.Sleep(500 * time.Millisecond)
+	close(quit)
+	wg.Wait()
+	stop()
+
+	if len(dev.WrittenCommands(Present)) < 1 {
+		t.Error("Nothing was drawn.")
+	}
+}
+
+// Starting and stopping the controller right away mustn't race with the goroutines it starts.
+func TestRunStartStop(t *testing.T) {
+	useTags(t, map[uint8]Tag{
+		1: &fakeTag{lines: []string{"one"}, interval: time.Millisecond},
+		2: &fakeTag{lines: []string{"two"}},
+	})
+	for i := 0; i < 10; i++ {
+		dev := newFakeDevice()
+		dev.respond = keyboardResponder(Area{Width: 21, Height: 4}, 2)
+		_, stop := runController(t, dev)
+		stop()
+	}
+}
+
+// Reloading the configuration used to race with the screens, so the controller leaves it to the caller.
+func TestRunReload(t *testing.T) {
+	if RELOAD_SIGNAL == nil {
+		t.Skip("There is no signal for reloading the configuration on this platform.")
+	}
+	useTags(t, map[uint8]Tag{
+		1: &fakeTag{lines: []string{"one"}, interval: time.Millisecond},
+	})
+	dev := newFakeDevice()
+	dev.respond = keyboardResponder(Area{Width: 21, Height: 4}, 2)
+	oled, stop := runController(t, dev)
+
+	oled.signals <- RELOAD_SIGNAL
+	if !stop() {
+		t.Error("The controller didn't ask for the configuration to be reloaded.")
+	}
+
+	dev = newFakeDevice()
+	dev.respond = keyboardResponder(Area{Width: 21, Height: 4}, 2)
+	_, stop = runController(t, dev)
+	if stop() {
+		t.Error("The controller asked for the configuration to be reloaded when stopped with SIGHUP.")
+	}
+}
+
+// A tag that takes a while to stop.
+type slowTag struct{}
+
+func (*slowTag) Draw(ctx context.Context, area Area, results chan []string) {
+	defer close(results)
+	results <- []string{"slow"}
+	<-ctx.Done()
+	time.Sleep(100 * time.Millisecond)
+}
+
+// The screens used to be cleared over and over while waiting for the tags to stop.
+func TestRunClearOnExit(t *testing.T) {
+	setFlag(t, "clear-on-exit", "true")
+	useTags(t, map[uint8]Tag{1: &slowTag{}})
+	dev := newFakeDevice()
+	dev.respond = keyboardResponder(Area{Width: 21, Height: 4}, 2)
+	_, stop := runController(t, dev)
+	waitFor(t, "the tag to be drawn", func() bool { return len(dev.WrittenCommands(Present)) >= 2 })
+
+	before := len(dev.WrittenCommands(Clear))
+	stop()
+	if cleared := len(dev.WrittenCommands(Clear)) - before; cleared != 2 {
+		t.Errorf("The screens were cleared %d times when stopping, want once each", cleared)
+	}
+}
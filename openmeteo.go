@@ -0,0 +1,94 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+// Get weather information for a position from Open-Meteo.com, which doesn't require an API key.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// The URL of the current weather, given the latitude and longitude.
+const OPEN_METEO_URL = "https://api.open-meteo.com/v1/forecast?latitude=%f&longitude=%f&current_weather=true&current=relative_humidity_2m&wind_speed_unit=ms"
+
+// Translate a WMO weather interpretation code, as used by Open-Meteo, to a weather condition.
+func wmoWeatherCondition(code int, day bool) WeatherCondition {
+	switch {
+	case code == 0:
+		if !day {
+			return ClearNight
+		}
+		return ClearSky
+	case code == 1 || code == 2:
+		if !day {
+			return FewCloudsNight
+		}
+		return FewClouds
+	case code == 3:
+		return BrokenClouds
+	case code == 45 || code == 48:
+		return Mist
+	case code >= 51 && code <= 67, code >= 80 && code <= 82:
+		return Rain
+	case code >= 71 && code <= 77, code == 85 || code == 86:
+		return Snow
+	case code >= 95 && code <= 99:
+		return Thunderstorm
+	default:
+		return ClearSky
+	}
+}
+
+// Start a loop that gets the current temperature (in the specified unit as "C", "F", or "K") and weather status at the
+// specified latitude and longitude.
+func OpenMeteoStats(ctx context.Context, latitude, longitude float64, unit string, interval time.Duration, result chan WeatherResult) {
+	defer close(result)
+
+	key := cacheKey("open-meteo", latitude, longitude, unit)
+	if cached, found := cachedValue(key); found {
+		result <- cached.(WeatherResult)
+	}
+
+	var response struct {
+		CurrentWeather struct {
+			Temperature float64 `json:"temperature"`
+			WeatherCode int     `json:"weathercode"`
+			IsDay       int     `json:"is_day"`
+			WindSpeed   float64 `json:"windspeed"`
+		} `json:"current_weather"`
+		Current struct {
+			Humidity int `json:"relative_humidity_2m"`
+		} `json:"current"`
+	}
+
+	delay := interval
+	for {
+		if err := getJSON(ctx, fmt.Sprintf(OPEN_METEO_URL, latitude, longitude), &response); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			delay = weatherRetryDelay(delay, interval)
+			logWarnf("Failed to get weather report: %v (retrying in %v)\n", err, delay)
+		} else {
+			delay = interval
+			current := WeatherResult{
+				Time:        time.Now(),
+				Temperature: ConvertTemperature(response.CurrentWeather.Temperature, unit),
+				Weather:     wmoWeatherCondition(response.CurrentWeather.WeatherCode, response.CurrentWeather.IsDay != 0),
+				Humidity:    response.Current.Humidity,
+				WindSpeed:   response.CurrentWeather.WindSpeed,
+			}
+			cacheValue(key, current)
+			result <- current
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
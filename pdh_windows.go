@@ -0,0 +1,165 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+// Read performance counters through the Performance Data Helper (PDH) library (Windows only)
+
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// PDH constants, from pdh.h and pdhmsg.h.
+const (
+	PDH_FMT_DOUBLE       = 0x00000200 // Return the value as a double.
+	PDH_FMT_NOCAP100     = 0x00008000 // Don't cap percentages at 100.
+	PDH_MORE_DATA        = 0x800007D2 // The buffer is too small.
+	PDH_CSTATUS_NEW_DATA = 0x00000001 // The value was successfully read, and differs from the previous one.
+
+	// The size of a PDH_FMT_COUNTERVALUE_ITEM_W, and the offsets into it, which are the same for 32 and 64 bit since
+	// the value is 8-byte aligned.
+	PDH_ITEM_SIZE          = 24
+	PDH_ITEM_STATUS_OFFSET = 8
+	PDH_ITEM_VALUE_OFFSET  = 16
+)
+
+var (
+	pdh                             = windows.NewLazySystemDLL("pdh.dll")
+	procPdhOpenQueryW               = pdh.NewProc("PdhOpenQueryW")
+	procPdhAddEnglishCounterW       = pdh.NewProc("PdhAddEnglishCounterW")
+	procPdhCollectQueryData         = pdh.NewProc("PdhCollectQueryData")
+	procPdhGetFormattedCounterArray = pdh.NewProc("PdhGetFormattedCounterArrayW")
+	procPdhCloseQuery               = pdh.NewProc("PdhCloseQuery")
+)
+
+// A value of a counter, which is one of several if the counter path has a wildcard instance (e.g. "Processor(*)").
+type pdhValue struct {
+	Instance string  // The name of the instance, e.g. "0" or "_Total".
+	Value    float64 // The value of the counter.
+}
+
+// A query of one or more counters, which are all collected at the same time.
+type pdhQuery struct {
+	handle   uintptr   // The handle of the query.
+	counters []uintptr // The handles of the counters.
+}
+
+// Create an error from the status returned by a PDH function.
+func pdhError(function string, status uintptr) error {
+	return fmt.Errorf("%s failed with 0x%08X", function, uint32(status))
+}
+
+// Open a query for the specified counter paths, using their English names (e.g. `\Memory\% Committed Bytes In Use`).
+// Counters measuring a rate need two collections before they have a value, so the first collection is done directly.
+func openPDHQuery(paths []string) (*pdhQuery, error) {
+	if err := pdh.Load(); err != nil {
+		return nil, err
+	}
+
+	query := &pdhQuery{}
+	if status, _, _ := procPdhOpenQueryW.Call(0, 0, uintptr(unsafe.Pointer(&query.handle))); status != 0 {
+		return nil, pdhError("PdhOpenQuery", status)
+	}
+
+	for _, path := range paths {
+		pathPtr, err := windows.UTF16PtrFromString(path)
+		if err != nil {
+			query.Close()
+			return nil, err
+		}
+		var counter uintptr
+		status, _, _ := procPdhAddEnglishCounterW.Call(query.handle, uintptr(unsafe.Pointer(pathPtr)), 0,
+			uintptr(unsafe.Pointer(&counter)))
+		if status != 0 {
+			query.Close()
+			return nil, fmt.Errorf("%s: %v", path, pdhError("PdhAddEnglishCounter", status))
+		}
+		query.counters = append(query.counters, counter)
+	}
+
+	if status, _, _ := procPdhCollectQueryData.Call(query.handle); status != 0 {
+		query.Close()
+		return nil, pdhError("PdhCollectQueryData", status)
+	}
+	return query, nil
+}
+
+// Collect the current values of the counters, in the order they were added. Each counter has one value per instance,
+// sorted by the name of the instance. Instances without a valid value are left out.
+func (query *pdhQuery) Collect() ([][]pdhValue, error) {
+	if status, _, _ := procPdhCollectQueryData.Call(query.handle); status != 0 {
+		return nil, pdhError("PdhCollectQueryData", status)
+	}
+
+	values := make([][]pdhValue, len(query.counters))
+	for i, counter := range query.counters {
+		var size, count uint32
+		status, _, _ := procPdhGetFormattedCounterArray.Call(counter, PDH_FMT_DOUBLE|PDH_FMT_NOCAP100,
+			uintptr(unsafe.Pointer(&size)), uintptr(unsafe.Pointer(&count)), 0)
+		if status == 0 {
+			continue // No instances, e.g. when no network interface matches.
+		} else if status != PDH_MORE_DATA {
+			return nil, pdhError("PdhGetFormattedCounterArray", status)
+		}
+
+		buf := make([]byte, size)
+		status, _, _ = procPdhGetFormattedCounterArray.Call(counter, PDH_FMT_DOUBLE|PDH_FMT_NOCAP100,
+			uintptr(unsafe.Pointer(&size)), uintptr(unsafe.Pointer(&count)), uintptr(unsafe.Pointer(&buf[0])))
+		if status != 0 {
+			return nil, pdhError("PdhGetFormattedCounterArray", status)
+		}
+
+		for item := 0; item < int(count); item++ {
+			offset := item * PDH_ITEM_SIZE
+			if status := binary.LittleEndian.Uint32(buf[offset+PDH_ITEM_STATUS_OFFSET:]); status > PDH_CSTATUS_NEW_DATA {
+				continue
+			}
+			// The name points into the same buffer, after the items.
+			name := *(**uint16)(unsafe.Pointer(&buf[offset]))
+			values[i] = append(values[i], pdhValue{
+				Instance: windows.UTF16PtrToString(name),
+				Value:    math.Float64frombits(binary.LittleEndian.Uint64(buf[offset+PDH_ITEM_VALUE_OFFSET:])),
+			})
+		}
+		sort.Slice(values[i], func(a, b int) bool {
+			return instanceLess(values[i][a].Instance, values[i][b].Instance)
+		})
+	}
+	return values, nil
+}
+
+// Close the query, and all its counters.
+func (query *pdhQuery) Close() {
+	procPdhCloseQuery.Call(query.handle)
+}
+
+// Sort instance names numerically if they are numbers (such as processor cores), otherwise alphabetically.
+func instanceLess(a, b string) bool {
+	numA, errA := strconv.Atoi(a)
+	numB, errB := strconv.Atoi(b)
+	if errA == nil && errB == nil {
+		return numA < numB
+	} else if (errA == nil) != (errB == nil) {
+		return errA == nil // Numbers first.
+	}
+	return a < b
+}
+
+// Sum the values of all instances of a counter, e.g. the throughput of every network interface.
+func sumValues(values []pdhValue) float64 {
+	var sum float64
+	for _, value := range values {
+		sum += value.Value
+	}
+	return sum
+}
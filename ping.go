@@ -0,0 +1,118 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+// Measure the round-trip time to a host with ICMP echo requests. Unprivileged ICMP sockets are used where the platform
+// allows it (Linux, if permitted by net.ipv4.ping_group_range, and macOS), otherwise raw sockets are used, which requires
+// elevated privileges.
+
+package main
+
+import (
+	"context"
+	"net"
+	"os"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+// How long to wait for a reply before considering the host unreachable.
+const PING_TIMEOUT = 2 * time.Second
+
+// The type of a ping result
+type PingResult struct {
+	RTT     time.Duration // The round-trip time, if a reply was received.
+	Timeout bool          // Whether the host didn't reply in time.
+}
+
+// Open an ICMP socket, preferring an unprivileged one.
+// Returns whether the socket is privileged (raw), which changes how the destination is addressed.
+func openPingSocket() (*icmp.PacketConn, bool, error) {
+	conn, err := icmp.ListenPacket("udp4", "0.0.0.0")
+	if err == nil {
+		return conn, false, nil
+	}
+	logDebug("Failed to open unprivileged ICMP socket:", err)
+	conn, err = icmp.ListenPacket("ip4:icmp", "0.0.0.0")
+	return conn, true, err
+}
+
+// Send an echo request, and wait for the reply.
+func ping(conn *icmp.PacketConn, dst net.Addr, seq int) (time.Duration, error) {
+	msg := icmp.Message{
+		Type: ipv4.ICMPTypeEcho,
+		Body: &icmp.Echo{ID: os.Getpid() & 0xFFFF, Seq: seq, Data: []byte("oled-controller")},
+	}
+	data, err := msg.Marshal(nil)
+	if err != nil {
+		return 0, err
+	}
+
+	start := time.Now()
+	if _, err := conn.WriteTo(data, dst); err != nil {
+		return 0, err
+	}
+
+	if err := conn.SetReadDeadline(start.Add(PING_TIMEOUT)); err != nil {
+		return 0, err
+	}
+	buf := make([]byte, 1500)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			return 0, err // Including timeouts.
+		}
+		reply, err := icmp.ParseMessage(ipv4.ICMPTypeEcho.Protocol(), buf[:n])
+		if err != nil || reply.Type != ipv4.ICMPTypeEchoReply {
+			continue
+		}
+		// The ID is replaced by the kernel for unprivileged sockets, so only the sequence number is checked.
+		if echo, ok := reply.Body.(*icmp.Echo); ok && echo.Seq == seq {
+			return time.Since(start), nil
+		}
+	}
+}
+
+// Run a loop that will continuously ping a host, at the specified interval.
+func PingStats(ctx context.Context, host string, interval time.Duration, results chan PingResult) {
+	defer close(results)
+
+	addr, err := net.ResolveIPAddr("ip4", host)
+	if err != nil {
+		logErrorf("Failed to resolve %s: %v\n", host, err)
+		return
+	}
+
+	conn, privileged, err := openPingSocket()
+	if err != nil {
+		logError("Failed to open ICMP socket:", err)
+		return
+	}
+	defer conn.Close()
+
+	var dst net.Addr = &net.UDPAddr{IP: addr.IP}
+	if privileged {
+		dst = addr
+	}
+
+	for seq := 1; ; seq = (seq + 1) & 0xFFFF {
+		start := time.Now()
+
+		rtt, err := ping(conn, dst, seq)
+		if err != nil {
+			if netErr, ok := err.(net.Error); !ok || !netErr.Timeout() {
+				logWarnf("Failed to ping %s: %v\n", host, err)
+			}
+			results <- PingResult{Timeout: true}
+		} else {
+			results <- PingResult{RTT: rtt}
+		}
+
+		select {
+		case <-time.After(interval - time.Since(start)):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
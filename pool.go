@@ -0,0 +1,91 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+// Get the state and capacity of ZFS pools (from zpool) and software RAID arrays (from mdadm, through /proc/mdstat). The
+// platform specific parts are found in pool_<platform>.go
+
+package main
+
+import (
+	"context"
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The type of a pool result
+type PoolResult struct {
+	Name   string  // The name of the pool or array, e.g. "tank" or "md0".
+	Health string  // The state, e.g. ONLINE or DEGRADED for a ZFS pool, and ACTIVE or DEGRADED for an array.
+	Used   float64 // How much of the pool is used (0-1), or NaN if it's unknown.
+}
+
+// Get whether the pool is in a state that needs attention.
+func (pool PoolResult) Degraded() bool {
+	return pool.Health != "ONLINE" && pool.Health != "ACTIVE"
+}
+
+// Get the state and capacity of the ZFS pools from zpool.
+func zfsPools(ctx context.Context) ([]PoolResult, error) {
+	// Scripted mode, with exact sizes in bytes.
+	output, err := exec.CommandContext(ctx, "zpool", "list", "-H", "-p", "-o", "name,health,size,alloc").Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var pools []PoolResult
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) < 4 {
+			continue
+		}
+		pool := PoolResult{Name: fields[0], Health: fields[1], Used: math.NaN()}
+		size, errSize := strconv.ParseFloat(fields[2], 64)
+		alloc, errAlloc := strconv.ParseFloat(fields[3], 64)
+		if errSize == nil && errAlloc == nil && size > 0 {
+			pool.Used = alloc / size
+		}
+		pools = append(pools, pool)
+	}
+	return pools, nil
+}
+
+// Run a loop that will continuously get the state and capacity of the ZFS pools and RAID arrays, at the specified
+// interval. Stops if there are neither when starting, e.g. because the tools aren't installed.
+func PoolStats(ctx context.Context, interval time.Duration, results chan []PoolResult) {
+	defer close(results)
+
+	_, err := exec.LookPath("zpool")
+	hasZFS := err == nil
+	first := true
+	for {
+		var pools []PoolResult
+		if hasZFS {
+			if zfs, err := zfsPools(ctx); err != nil {
+				logWarn("Failed to get the ZFS pools:", err)
+			} else {
+				pools = append(pools, zfs...)
+			}
+		}
+		if arrays, err := mdArrays(); err != nil {
+			logDebug("Failed to get the RAID arrays:", err)
+		} else {
+			pools = append(pools, arrays...)
+		}
+
+		if first && len(pools) < 1 {
+			logError("Failed to find any ZFS pools or RAID arrays.")
+			return
+		}
+		first = false
+		results <- pools
+
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
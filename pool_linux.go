@@ -0,0 +1,73 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+//go:build linux
+// +build linux
+
+// Get the state and capacity of the software RAID arrays from /proc/mdstat (Linux edition)
+
+package main
+
+import (
+	"bufio"
+	"math"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Matches the status of the devices of an array in /proc/mdstat, e.g. "[UU]", or "[U_]" if one of them is missing.
+var mdstatDevices = regexp.MustCompile(`\[([U_]+)\]`)
+
+// Find where the device of an array is mounted. Returns an empty string if it isn't.
+func mountPoint(device string) string {
+	f, err := os.Open("/proc/mounts")
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if fields := strings.Fields(scanner.Text()); len(fields) >= 2 && fields[0] == device {
+			return fields[1]
+		}
+	}
+	return ""
+}
+
+// Get the state and capacity of the software RAID arrays. An array is DEGRADED if any of its devices is missing. The
+// capacity is that of the filesystem on the array, if it's mounted.
+func mdArrays() ([]PoolResult, error) {
+	f, err := os.Open("/proc/mdstat")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var arrays []PoolResult
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "md") && strings.Contains(line, " : ") {
+			// E.g. "md0 : active raid1 sdb1[1] sda1[0]"
+			fields := strings.Fields(line)
+			array := PoolResult{Name: fields[0], Health: "ACTIVE", Used: math.NaN()}
+			if len(fields) >= 3 && fields[2] != "active" {
+				array.Health = strings.ToUpper(fields[2])
+			}
+			if mount := mountPoint("/dev/" + array.Name); mount != "" {
+				if total, free, err := diskSpace(mount); err == nil && total > 0 {
+					array.Used = 1 - float64(free)/float64(total)
+				}
+			}
+			arrays = append(arrays, array)
+		} else if len(arrays) > 0 && strings.HasPrefix(line, " ") {
+			// E.g. "      976630464 blocks super 1.2 [2/1] [U_]"
+			if match := mdstatDevices.FindStringSubmatch(line); match != nil && strings.Contains(match[1], "_") {
+				arrays[len(arrays)-1].Health = "DEGRADED"
+			}
+		}
+	}
+	return arrays, scanner.Err()
+}
@@ -0,0 +1,16 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+//go:build !linux
+// +build !linux
+
+// Get the state and capacity of the software RAID arrays (unsupported platform edition)
+
+package main
+
+import "fmt"
+
+// Software RAID arrays are only supported on Linux; ZFS pools are supported wherever zpool is installed.
+func mdArrays() ([]PoolResult, error) {
+	return nil, fmt.Errorf("not supported on this platform")
+}
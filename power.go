@@ -0,0 +1,12 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+// Get the power draw of the CPU. The platform specific parts are found in power_<platform>.go
+
+package main
+
+// The type of a power result. All values are in watts.
+type PowerResult struct {
+	Power float64 // The current power draw.
+	Limit float64 // The power limit (TDP), or zero if unknown.
+}
@@ -0,0 +1,80 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+//go:build linux
+// +build linux
+
+// Get the power draw of the CPU package from the RAPL energy counter in /sys/ (Linux edition)
+
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The powercap directory of the CPU package.
+const RAPL_PATH = "/sys/class/powercap/intel-rapl:0"
+
+// Read a file from the powercap directory containing a single number.
+func readRAPLValue(name string) (float64, error) {
+	content, err := ioutil.ReadFile(filepath.Join(RAPL_PATH, name))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(strings.TrimSpace(string(content)), 64)
+}
+
+// Run a loop that will continuously get the power draw of the CPU package, at the specified interval. The power draw
+// is the energy used since the previous reading, so the first result comes after one interval.
+func CPUPowerStats(ctx context.Context, interval time.Duration, results chan PowerResult) {
+	defer close(results)
+
+	previous, err := readRAPLValue("energy_uj")
+	if os.IsPermission(err) {
+		logErrorf("Not allowed to read the CPU energy counter in %s. It's only readable by root on newer kernels.\n", RAPL_PATH)
+		return
+	} else if err != nil {
+		logError("Failed to find the CPU energy counter:", err)
+		return
+	}
+	last := time.Now()
+
+	// The limits and counters are in microwatts and microjoules.
+	limit, err := readRAPLValue("constraint_0_max_power_uw")
+	if err != nil || limit <= 0 {
+		limit, _ = readRAPLValue("constraint_0_power_limit_uw")
+	}
+	maxRange, _ := readRAPLValue("max_energy_range_uj")
+
+	for {
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return
+		}
+
+		energy, err := readRAPLValue("energy_uj")
+		if err != nil {
+			logError("Failed to read the CPU energy counter:", err)
+			return
+		}
+		now := time.Now()
+
+		used := energy - previous
+		if used < 0 {
+			// The counter has wrapped around.
+			used += maxRange
+		}
+		results <- PowerResult{
+			Power: used / 1e6 / now.Sub(last).Seconds(),
+			Limit: limit / 1e6,
+		}
+		previous, last = energy, now
+	}
+}
@@ -0,0 +1,20 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+//go:build !linux
+// +build !linux
+
+// Get the power draw of the CPU (unsupported platform edition)
+
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// Getting the power draw of the CPU is not supported on this platform.
+func CPUPowerStats(ctx context.Context, interval time.Duration, results chan PowerResult) {
+	defer close(results)
+	logError("CPU power draw is not supported on this platform.")
+}
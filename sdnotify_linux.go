@@ -0,0 +1,90 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+//go:build linux
+// +build linux
+
+// Notify systemd about the state of the service, for services of Type=notify, and keep its watchdog happy (Linux
+// edition). Nothing is sent unless systemd has set NOTIFY_SOCKET.
+
+package main
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// State of the watchdog notifications.
+var (
+	watchdogMutex sync.Mutex // Lock protecting the time of the last notification.
+	watchdogLast  time.Time  // When the watchdog was last notified.
+)
+
+// Send a state (e.g. "READY=1") to systemd. Returns false if not running under systemd, or if sending it failed.
+func sdNotify(state string) bool {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return false
+	}
+	if socket[0] == '@' {
+		// Abstract socket.
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		logWarn("Failed to connect to the systemd notification socket:", err)
+		return false
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		logWarn("Failed to notify systemd:", err)
+		return false
+	}
+	return true
+}
+
+// Get how often systemd expects the watchdog to be notified, or zero if the watchdog isn't enabled for this process.
+// The watchdog is notified twice as often as required, to leave some margin.
+func watchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond / 2
+}
+
+// Notify the systemd watchdog that the program is still alive, if it's enabled and hasn't been notified recently.
+// Called from the loops that stop running when the program hangs.
+func notifyWatchdog() {
+	interval := watchdogInterval()
+	if interval == 0 {
+		return
+	}
+
+	watchdogMutex.Lock()
+	defer watchdogMutex.Unlock()
+	if time.Since(watchdogLast) >= interval {
+		sdNotify("WATCHDOG=1")
+		watchdogLast = time.Now()
+	}
+}
+
+// Sleep for the specified duration, notifying the watchdog in the meantime so that it doesn't expire during long sleeps.
+func sleepNotifying(duration time.Duration) {
+	// Wake up often enough that the watchdog is notified in time, even if it was notified just before.
+	step := watchdogInterval() / 2
+	for step > 0 && duration > step {
+		time.Sleep(step)
+		duration -= step
+		notifyWatchdog()
+	}
+	time.Sleep(duration)
+}
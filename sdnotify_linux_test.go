@@ -0,0 +1,95 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+//go:build linux
+// +build linux
+
+// Tests of the notifications to systemd (Linux edition).
+
+package main
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Listen for notifications as systemd would, returning a function that stops listening and gives the times at which
+// the watchdog was notified.
+func listenNotifications(t *testing.T) func() []time.Time {
+	socket := filepath.Join(t.TempDir(), "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Fatal("Failed to listen for notifications:", err)
+	}
+	t.Setenv("NOTIFY_SOCKET", socket)
+
+	var times []time.Time
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		buf := make([]byte, 64)
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				return
+			}
+			if string(buf[:n]) == "WATCHDOG=1" {
+				times = append(times, time.Now())
+			}
+		}
+	}()
+
+	return func() []time.Time {
+		conn.Close()
+		wg.Wait()
+		return times
+	}
+}
+
+// Sleeping while waiting for the keyboard used to let the watchdog expire.
+func TestSleepNotifying(t *testing.T) {
+	const watchdog = 200 * time.Millisecond
+	stop := listenNotifications(t)
+	t.Setenv("WATCHDOG_USEC", strconv.FormatInt(watchdog.Microseconds(), 10))
+	t.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()))
+
+	notifyWatchdog()
+	start := time.Now()
+	sleepNotifying(5 * watchdog / 2)
+	end := time.Now()
+	times := stop()
+
+	if end.Sub(start) < 5*watchdog/2 {
+		t.Errorf("Slept for %v, want at least %v", end.Sub(start), 5*watchdog/2)
+	}
+	if len(times) < 3 {
+		t.Fatalf("The watchdog was notified %d times, want at least 3", len(times))
+	}
+	previous := times[0]
+	for _, notified := range append(times[1:], end) {
+		if gap := notified.Sub(previous); gap >= watchdog {
+			t.Errorf("The watchdog wasn't notified for %v, which is longer than its timeout of %v", gap, watchdog)
+		}
+		previous = notified
+	}
+}
+
+func TestSleepNotifyingWithoutWatchdog(t *testing.T) {
+	stop := listenNotifications(t)
+	t.Setenv("WATCHDOG_USEC", "")
+
+	start := time.Now()
+	sleepNotifying(50 * time.Millisecond)
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("Slept for %v, want at least %v", elapsed, 50*time.Millisecond)
+	}
+	if times := stop(); len(times) > 0 {
+		t.Errorf("The watchdog was notified %d times without being enabled", len(times))
+	}
+}
@@ -0,0 +1,27 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+//go:build !linux
+// +build !linux
+
+// Notify systemd about the state of the service (unsupported platform edition)
+
+package main
+
+import (
+	"time"
+)
+
+// There is no systemd on this platform.
+func sdNotify(state string) bool {
+	return false
+}
+
+// There is no systemd watchdog on this platform.
+func notifyWatchdog() {
+}
+
+// There is no systemd watchdog to notify while sleeping on this platform.
+func sleepNotifying(duration time.Duration) {
+	time.Sleep(duration)
+}
@@ -0,0 +1,17 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+// Get the users logged in to the system. The platform specific parts are found in sessions_<platform>.go
+
+package main
+
+import (
+	"time"
+)
+
+// The type of a sessions result
+type SessionsResult struct {
+	Count     int       // The number of sessions of logged in users.
+	LastUser  string    // The user of the most recent login, if any.
+	LastLogin time.Time // The time of the most recent login. Zero if unknown.
+}
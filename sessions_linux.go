@@ -0,0 +1,83 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+//go:build linux
+// +build linux
+
+// Get the users logged in to the system from utmp (Linux edition)
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// The file listing the current sessions.
+const UTMP_FILE = "/var/run/utmp"
+
+// The layout of an entry in the utmp file, as defined by glibc. The layout is the same on 32 and 64 bit systems.
+type utmpEntry struct {
+	Type    int16     // The type of the entry.
+	_       [2]byte   // Padding.
+	Pid     int32     // The process ID of the login process.
+	Line    [32]byte  // The device name of the terminal (e.g. "pts/0").
+	ID      [4]byte   // The terminal name suffix.
+	User    [32]byte  // The user name.
+	Host    [256]byte // The host name for remote logins.
+	Exit    [2]int16  // The exit status of a dead process.
+	Session int32     // The session ID.
+	Sec     int32     // The time of the entry, in seconds.
+	Usec    int32     // The time of the entry, in microseconds.
+	Addr    [4]int32  // The IP address of the remote host.
+	_       [20]byte  // Reserved.
+}
+
+// The type of the entries for logged in users.
+const UTMP_USER_PROCESS = 7
+
+// Run a loop that will continuously get the logged in users, at the specified interval.
+func SessionsStats(ctx context.Context, interval time.Duration, results chan SessionsResult) {
+	defer close(results)
+
+	entrySize := binary.Size(utmpEntry{})
+	for {
+		data, err := ioutil.ReadFile(UTMP_FILE)
+		if os.IsPermission(err) {
+			logErrorf("Not allowed to read %s. Check the permissions of the file.\n", UTMP_FILE)
+			return
+		} else if err != nil {
+			logError("Failed to retrieve the logged in users:", err)
+			return
+		}
+
+		var result SessionsResult
+		for offset := 0; offset+entrySize <= len(data); offset += entrySize {
+			var entry utmpEntry
+			if err := binary.Read(bytes.NewReader(data[offset:offset+entrySize]), binary.LittleEndian, &entry); err != nil {
+				logError("Failed to parse utmp entry:", err)
+				break
+			} else if entry.Type != UTMP_USER_PROCESS {
+				continue
+			}
+
+			result.Count++
+			login := time.Unix(int64(entry.Sec), int64(entry.Usec)*int64(time.Microsecond))
+			if login.After(result.LastLogin) {
+				result.LastLogin = login
+				result.LastUser = string(bytes.TrimRight(entry.User[:], "\x00"))
+			}
+		}
+		results <- result
+
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,20 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+//go:build !linux && !windows
+// +build !linux,!windows
+
+// Get the users logged in to the system (unsupported platform edition)
+
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// Getting the logged in users is not supported on this platform.
+func SessionsStats(ctx context.Context, interval time.Duration, results chan SessionsResult) {
+	defer close(results)
+	logError("Logged in users are not supported on this platform.")
+}
@@ -0,0 +1,80 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+// Get the users logged in to the system from "query user" (Windows edition)
+
+package main
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// The formats of the logon time printed by "query user", which depends on the locale.
+var LOGON_TIME_LAYOUTS = []string{
+	"1/2/2006 3:04 PM",
+	"2/1/2006 15:04",
+	"2006-01-02 15:04",
+	"02.01.2006 15:04",
+}
+
+// Run a loop that will continuously get the logged in users, at the specified interval.
+func SessionsStats(ctx context.Context, interval time.Duration, results chan SessionsResult) {
+	defer close(results)
+
+	for {
+		// The command fails when nobody is logged in, in which case nothing is printed to stdout.
+		output, err := exec.Command("query", "user").Output()
+		if err != nil && len(output) == 0 {
+			if _, ok := err.(*exec.ExitError); !ok {
+				logError("Failed to run query user:", err)
+				return
+			}
+		}
+
+		var result SessionsResult
+		lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+		for i, line := range lines {
+			// The first line is the header. The current session is marked with a ">".
+			fields := strings.Fields(strings.TrimLeft(line, " >"))
+			if i == 0 || len(fields) < 4 {
+				continue
+			}
+
+			result.Count++
+			// The logon time is made up of the last two or three fields, depending on the locale.
+			var login time.Time
+			for _, count := range []int{3, 2} {
+				if len(fields) <= count {
+					continue
+				}
+				value := strings.Join(fields[len(fields)-count:], " ")
+				for _, layout := range LOGON_TIME_LAYOUTS {
+					if parsed, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+						login = parsed
+						break
+					}
+				}
+				if !login.IsZero() {
+					break
+				}
+			}
+			if result.LastUser == "" || login.After(result.LastLogin) {
+				result.LastLogin = login
+				result.LastUser = fields[0]
+			}
+		}
+		results <- result
+
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
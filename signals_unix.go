@@ -0,0 +1,26 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+//go:build !windows
+// +build !windows
+
+// Signals that control the program while it's running (Unix edition)
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// The signals controlling the program. NEXT_TAG_SIGNAL advances the tag on the master screen, and RELOAD_SIGNAL reloads
+// the configuration file.
+var (
+	NEXT_TAG_SIGNAL os.Signal = syscall.SIGUSR1
+	RELOAD_SIGNAL   os.Signal = syscall.SIGUSR2
+)
+
+// Get the signals controlling the program.
+func controlSignals() []os.Signal {
+	return []os.Signal{NEXT_TAG_SIGNAL, RELOAD_SIGNAL}
+}
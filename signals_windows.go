@@ -0,0 +1,23 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+// Signals that control the program while it's running (Windows edition)
+
+package main
+
+import "os"
+
+// Windows lacks the user defined signals, so the program can't be controlled this way. The signals are left as nil,
+// which never matches a received signal.
+var (
+	NEXT_TAG_SIGNAL os.Signal
+	RELOAD_SIGNAL   os.Signal
+)
+
+// Get the signals controlling the program, of which there are none on Windows.
+func controlSignals() []os.Signal {
+	return nil
+}
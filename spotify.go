@@ -0,0 +1,159 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+// Get the currently playing track from the Spotify Web API. This requires an app to be registered on
+// developer.spotify.com, with SPOTIFY_REDIRECT_URL added as a redirect URI. The client ID and secret of the app are
+// given as arguments to the program, and the first time you will be prompted with an URL to visit. After logging in,
+// the browser is redirected to a page that doesn't exist, and the address of that page needs to be filled in.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kirsle/configdir"
+	"golang.org/x/oauth2"
+)
+
+// The redirect URI of the Spotify app.
+const SPOTIFY_REDIRECT_URL = "http://localhost:8888/callback"
+
+// The parts of the currently playing response from the Spotify Web API that are of interest.
+type spotifyCurrentlyPlaying struct {
+	IsPlaying  bool  `json:"is_playing"`
+	ProgressMs int64 `json:"progress_ms"`
+	Item       *struct {
+		Name       string `json:"name"`
+		DurationMs int64  `json:"duration_ms"`
+		Artists    []struct {
+			Name string `json:"name"`
+		} `json:"artists"`
+	} `json:"item"`
+}
+
+// Get an API token from the web, by having the user log in and fill in the address they were redirected to.
+func getSpotifyTokenFromWeb(config *oauth2.Config) *oauth2.Token {
+	authUrl := config.AuthCodeURL("state-token")
+	fmt.Printf("Please visit this URL to authenticate with Spotify: \n%v\n", authUrl)
+
+	fmt.Print("Address redirected to: ")
+	var redirected string
+	if _, err := fmt.Scan(&redirected); err != nil {
+		logError("Failed to read address:", err)
+		return nil
+	}
+
+	// Accept the code on its own as well as the whole address.
+	authCode := redirected
+	if parsed, err := url.Parse(redirected); err == nil && parsed.Query().Get("code") != "" {
+		authCode = parsed.Query().Get("code")
+	}
+
+	token, err := config.Exchange(context.TODO(), authCode)
+	if err != nil {
+		logError("Failed to exchange OAuth token:", err)
+		return nil
+	}
+	return token
+}
+
+// Get an HTTP client authenticated for the Spotify Web API.
+func getSpotifyClient(config *oauth2.Config) *http.Client {
+	configDir := configdir.LocalConfig("oled-controller")
+	err := configdir.MakePath(configDir)
+	if err != nil {
+		logErrorf("Failed to create configuration path %s: %v\n", configDir, err)
+		return nil
+	}
+	tokenFile := filepath.Join(configDir, "spotify-token.json")
+	token, err := getTokenFromFile(tokenFile)
+	if err != nil {
+		token = getSpotifyTokenFromWeb(config)
+		if token == nil {
+			return nil
+		}
+		saveTokenToFile(tokenFile, token)
+	}
+
+	return config.Client(context.Background(), token)
+}
+
+// Get the track currently playing on Spotify.
+func currentSpotifyTrack(client *http.Client) (MediaResult, error) {
+	resp, err := client.Get("https://api.spotify.com/v1/me/player/currently-playing")
+	if err != nil {
+		return MediaResult{}, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNoContent:
+		// Nothing is playing.
+		return MediaResult{}, nil
+	default:
+		return MediaResult{}, fmt.Errorf("%s (%d)", http.StatusText(resp.StatusCode), resp.StatusCode)
+	}
+
+	var playing spotifyCurrentlyPlaying
+	if err := json.NewDecoder(resp.Body).Decode(&playing); err != nil {
+		return MediaResult{}, err
+	} else if !playing.IsPlaying || playing.Item == nil {
+		return MediaResult{}, nil
+	}
+
+	artists := make([]string, 0, len(playing.Item.Artists))
+	for _, artist := range playing.Item.Artists {
+		artists = append(artists, artist.Name)
+	}
+	return MediaResult{
+		Playing:  true,
+		Title:    playing.Item.Name,
+		Artist:   strings.Join(artists, ", "),
+		Position: time.Duration(playing.ProgressMs) * time.Millisecond,
+		Duration: time.Duration(playing.Item.DurationMs) * time.Millisecond,
+	}, nil
+}
+
+// Start a loop that gets the track currently playing on Spotify, at the specified interval.
+func SpotifyStats(ctx context.Context, clientID string, clientSecret string, interval time.Duration, result chan MediaResult) {
+	defer close(result)
+
+	config := &oauth2.Config{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  "https://accounts.spotify.com/authorize",
+			TokenURL: "https://accounts.spotify.com/api/token",
+		},
+		RedirectURL: SPOTIFY_REDIRECT_URL,
+		Scopes:      []string{"user-read-currently-playing"},
+	}
+
+	client := getSpotifyClient(config)
+	if client == nil {
+		return
+	}
+
+	for {
+		track, err := currentSpotifyTrack(client)
+		if err != nil {
+			logWarn("Failed to get currently playing track from Spotify:", err)
+		} else {
+			result <- track
+		}
+
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
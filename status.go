@@ -0,0 +1,110 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+// Report the current state of the OLED controller as JSON over HTTP. This is useful for confirming what the firmware
+// reported, and what is being shown on the screens, without having to read through the debug output.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// The state of a screen, as reported by the status endpoint.
+type ScreenStatus struct {
+	ID      ScreenID `json:"id"`      // The screen's unique ID
+	Tag     uint8    `json:"tag"`     // Which tag is shown
+	Columns uint8    `json:"columns"` // The number of columns reported by the firmware
+	Rows    uint8    `json:"rows"`    // The number of rows reported by the firmware
+}
+
+// The state of the OLED controller, as reported by the status endpoint.
+type ControllerStatus struct {
+	Connected bool           `json:"connected"` // Whether the device is connected
+	Columns   uint8          `json:"columns"`   // The number of columns of the master screen
+	Rows      uint8          `json:"rows"`      // The number of rows of the master screen
+	Screens   []ScreenStatus `json:"screens"`   // The state of each screen
+}
+
+// Get the current state of the OLED controller.
+func (oled *OLEDController) Status() ControllerStatus {
+	oled.mutex.Lock()
+	defer oled.mutex.Unlock()
+
+	status := ControllerStatus{
+		Connected: oled.Connected,
+		Columns:   oled.Columns,
+		Rows:      oled.Rows,
+		Screens:   make([]ScreenStatus, 0, len(oled.Screens)),
+	}
+	for _, screen := range oled.Screens {
+		size := oled.Sizes[screen.ID]
+		status.Screens = append(status.Screens, ScreenStatus{
+			ID:      screen.ID,
+			Tag:     screen.Tag,
+			Columns: size.Width,
+			Rows:    size.Height,
+		})
+	}
+	return status
+}
+
+// Serves the state of the OLED controller in use, which is replaced when reconnecting to the keyboard.
+type StatusServer struct {
+	server *http.Server
+	mutex  sync.Mutex
+	oled   *OLEDController // The controller in use, or nil while looking for the keyboard.
+}
+
+// Start serving the state of the OLED controller on the specified address, reporting it as not connected until a
+// controller has been set with SetController. The returned server should be stopped with Stop.
+func StartStatusServer(addr string) *StatusServer {
+	status := &StatusServer{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(status.Status()); err != nil {
+			logError("Failed to write status:", err)
+		}
+	})
+
+	status.server = &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		if err := status.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logError("Failed to serve status:", err)
+		}
+	}()
+	return status
+}
+
+// Set the OLED controller whose state to serve, or nil if there is none.
+func (status *StatusServer) SetController(oled *OLEDController) {
+	status.mutex.Lock()
+	defer status.mutex.Unlock()
+	status.oled = oled
+}
+
+// Get the current state of the OLED controller in use, if any.
+func (status *StatusServer) Status() ControllerStatus {
+	status.mutex.Lock()
+	oled := status.oled
+	status.mutex.Unlock()
+
+	if oled == nil {
+		return ControllerStatus{Screens: []ScreenStatus{}}
+	}
+	return oled.Status()
+}
+
+// Stop the server, giving ongoing requests a moment to finish.
+func (status *StatusServer) Stop() {
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+	if err := status.server.Shutdown(ctx); err != nil {
+		logError("Failed to stop status server:", err)
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+// Tests of the status endpoint.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Get the status served by the status server.
+func getStatus(t *testing.T, server *StatusServer) ControllerStatus {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	server.server.Handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Got the status code %d, want %d", recorder.Code, http.StatusOK)
+	}
+
+	var status ControllerStatus
+	if err := json.NewDecoder(recorder.Body).Decode(&status); err != nil {
+		t.Fatal("Failed to decode the status:", err)
+	}
+	return status
+}
+
+// The status used to be served only while connected to the keyboard.
+func TestStatusServer(t *testing.T) {
+	useTags(t, map[uint8]Tag{1: &fakeTag{lines: []string{"one"}}})
+	server := StartStatusServer("127.0.0.1:0")
+	defer server.Stop()
+
+	if status := getStatus(t, server); status.Connected || len(status.Screens) != 0 {
+		t.Errorf("Got the status %+v before connecting, want not connected without screens", status)
+	}
+
+	dev := newFakeDevice()
+	dev.respond = keyboardResponder(Area{Width: 21, Height: 4}, 2)
+	oled, stop := runController(t, dev)
+	server.SetController(oled)
+	waitFor(t, "the controller to be connected", func() bool { return oled.Status().Connected })
+	status := getStatus(t, server)
+	if !status.Connected || status.Columns != 21 || status.Rows != 4 || len(status.Screens) != 2 {
+		t.Errorf("Got the status %+v while connected, want connected with two screens of 21x4", status)
+	}
+
+	// The keyboard is disconnected.
+	stop()
+	if status := getStatus(t, server); status.Connected {
+		t.Errorf("Got the status %+v after stopping, want not connected", status)
+	}
+	server.SetController(nil)
+	if status := getStatus(t, server); status.Connected || len(status.Screens) != 0 {
+		t.Errorf("Got the status %+v while looking for the keyboard, want not connected without screens", status)
+	}
+}
@@ -0,0 +1,36 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+// Get system status. The platform specific parts are found in sysstats_<platform>.go
+
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// The system statistics, with the usage as fractions (0.0-1.0).
+type SystemStatsResult struct {
+	Labels []string  // The labels of the values, e.g. "CPU%" and the names of the monitored disks.
+	Values []float64 // The CPU, memory, swap, and disk usage, in the order of the labels.
+	Cores  []float64 // The usage of each logical core, if -cpu-per-core is set.
+}
+
+// Run a loop that will continuously get system statistics at the specified interval, which is shared with everything
+// else getting them at the same interval.
+func SystemStats(ctx context.Context, interval time.Duration, results chan SystemStatsResult) {
+	defer close(results)
+
+	key := cacheKey("sysstats", interval, *gArgs.sysStatDisk, *gArgs.cpuPerCore)
+	values := subscribe(ctx, key, func(ctx context.Context, values chan interface{}) {
+		stats := make(chan SystemStatsResult, 5)
+		go systemStats(ctx, interval, stats)
+		for stat := range stats {
+			values <- stat
+		}
+	})
+	for value := range values {
+		results <- value.(SystemStatsResult)
+	}
+}
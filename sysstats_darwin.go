@@ -0,0 +1,160 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+//go:build darwin
+// +build darwin
+
+// Get system status from the Mach kernel, sysctl, and IOKit (macOS edition)
+
+package main
+
+/*
+#cgo LDFLAGS: -framework IOKit -framework CoreFoundation
+#include <mach/mach.h>
+#include <sys/sysctl.h>
+#include <IOKit/IOKitLib.h>
+#include <IOKit/storage/IOBlockStorageDriver.h>
+
+// Get the CPU ticks spent busy and in total.
+static int cpu_ticks(uint64_t *busy, uint64_t *total) {
+	host_cpu_load_info_data_t info;
+	mach_msg_type_number_t count = HOST_CPU_LOAD_INFO_COUNT;
+	if (host_statistics(mach_host_self(), HOST_CPU_LOAD_INFO, (host_info_t)&info, &count) != KERN_SUCCESS) {
+		return -1;
+	}
+	*busy = (uint64_t)info.cpu_ticks[CPU_STATE_USER] + info.cpu_ticks[CPU_STATE_SYSTEM] + info.cpu_ticks[CPU_STATE_NICE];
+	*total = *busy + info.cpu_ticks[CPU_STATE_IDLE];
+	return 0;
+}
+
+// Get the number of bytes of memory in use (app memory, wired, and compressed), and in total.
+static int memory_usage(uint64_t *used, uint64_t *total) {
+	vm_statistics64_data_t info;
+	mach_msg_type_number_t count = HOST_VM_INFO64_COUNT;
+	if (host_statistics64(mach_host_self(), HOST_VM_INFO64, (host_info64_t)&info, &count) != KERN_SUCCESS) {
+		return -1;
+	}
+	size_t size = sizeof(*total);
+	if (sysctlbyname("hw.memsize", total, &size, NULL, 0) != 0) {
+		return -1;
+	}
+	uint64_t pages = (uint64_t)info.internal_page_count - info.purgeable_count + info.wire_count +
+		info.compressor_page_count;
+	*used = pages * vm_kernel_page_size;
+	return 0;
+}
+
+// Get the number of bytes of swap in use, and in total.
+static int swap_usage(uint64_t *used, uint64_t *total) {
+	struct xsw_usage usage;
+	size_t size = sizeof(usage);
+	if (sysctlbyname("vm.swapusage", &usage, &size, NULL, 0) != 0) {
+		return -1;
+	}
+	*used = usage.xsu_used;
+	*total = usage.xsu_total;
+	return 0;
+}
+
+// Get the total time, in nanoseconds, spent reading from and writing to all disks.
+static int disk_time(uint64_t *time) {
+	io_iterator_t drives;
+	// A port of zero means the default main port.
+	if (IOServiceGetMatchingServices(0, IOServiceMatching(kIOBlockStorageDriverClass), &drives) != KERN_SUCCESS) {
+		return -1;
+	}
+
+	*time = 0;
+	io_registry_entry_t drive;
+	while ((drive = IOIteratorNext(drives))) {
+		CFDictionaryRef stats = IORegistryEntryCreateCFProperty(drive,
+			CFSTR(kIOBlockStorageDriverStatisticsKey), kCFAllocatorDefault, 0);
+		if (stats) {
+			int64_t value;
+			CFNumberRef number = CFDictionaryGetValue(stats, CFSTR(kIOBlockStorageDriverStatisticsTotalReadTimeKey));
+			if (number && CFNumberGetValue(number, kCFNumberSInt64Type, &value)) {
+				*time += value;
+			}
+			number = CFDictionaryGetValue(stats, CFSTR(kIOBlockStorageDriverStatisticsTotalWriteTimeKey));
+			if (number && CFNumberGetValue(number, kCFNumberSInt64Type, &value)) {
+				*time += value;
+			}
+			CFRelease(stats);
+		}
+		IOObjectRelease(drive);
+	}
+	IOObjectRelease(drives);
+	return 0;
+}
+*/
+import "C"
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// The labels of the values produced by systemStats.
+var SYSTEM_STATS_LABELS = []string{"CPU%", "Mem%", "Swap", "Disk"}
+
+// Get system statistics at the specified interval.
+// This will get the current CPU, memory, swap, and disk usage in fractions (0.0-1.0)
+func systemStats(ctx context.Context, interval time.Duration, results chan SystemStatsResult) {
+	var prevBusy, prevTotal, prevDiskTime C.uint64_t
+	var prevTime time.Time
+
+	defer close(results)
+
+	for {
+		var cpu, mem, swap, disk float64
+
+		var busy, total C.uint64_t
+		if C.cpu_ticks(&busy, &total) != 0 {
+			logError("Failed to retrieve CPU information.")
+		} else {
+			if prevTotal != 0 && total > prevTotal {
+				cpu = math.Max(float64(busy-prevBusy)/float64(total-prevTotal), 0)
+				logDebug("CPU%: ", cpu*100)
+			}
+			prevBusy = busy
+			prevTotal = total
+		}
+
+		var used C.uint64_t
+		if C.memory_usage(&used, &total) != 0 {
+			logError("Failed to retrieve memory information.")
+		} else {
+			mem = math.Max(float64(used)/float64(total), 0)
+			logDebug("Mem%: ", mem*100)
+		}
+
+		if C.swap_usage(&used, &total) != 0 {
+			logError("Failed to retrieve swap information.")
+		} else if total > 0 {
+			swap = math.Max(float64(used)/float64(total), 0)
+			logDebug("Swap%:", swap*100)
+		}
+
+		var diskTime C.uint64_t
+		now := time.Now()
+		if C.disk_time(&diskTime) != 0 {
+			logError("Failed to retrieve disk status information.")
+		} else {
+			if prevDiskTime != 0 && diskTime >= prevDiskTime {
+				disk = math.Max(float64(diskTime-prevDiskTime)/float64(now.Sub(prevTime).Nanoseconds()), 0)
+				logDebug("Disk%:", disk*100)
+			}
+			prevDiskTime = diskTime
+			prevTime = now
+		}
+
+		results <- SystemStatsResult{Labels: SYSTEM_STATS_LABELS, Values: []float64{cpu, mem, swap, disk}}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
@@ -1,6 +1,7 @@
 // Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
 // Licensed under the MIT License.
 
+//go:build linux
 // +build linux
 
 // Get system status from /proc/ and /sys/ (Linux edition)
@@ -8,48 +9,109 @@
 package main
 
 import (
-	"log"
+	"context"
 	"math"
+	"strings"
 	"time"
 
 	linuxproc "github.com/c9s/goprocinfo/linux"
 )
 
+// Get the names of the disks to monitor, as specified by the -sysstat-disk flag, split into the ones that could be
+// found in /proc/diskstats and the ones that couldn't.
+func monitoredDisks() (found []string, missing []string) {
+	diskStats, err := linuxproc.ReadDiskStats("/proc/diskstats")
+	if err != nil {
+		logError("Failed to retrieve disk status information:", err)
+		return nil, nil
+	}
+
+	for _, name := range strings.Split(*gArgs.sysStatDisk, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		exists := false
+		for _, diskStat := range diskStats {
+			if diskStat.Name == name {
+				exists = true
+				break
+			}
+		}
+		if exists {
+			found = append(found, name)
+		} else {
+			missing = append(missing, name)
+		}
+	}
+	return found, missing
+}
+
+// Calculate the CPU usage since the previous call, given the previous idle and total times, which are updated.
+// Returns zero on the first call.
+func cpuUsage(stat linuxproc.CPUStat, prevIdle *uint64, prevTotal *uint64) float64 {
+	idle := stat.Idle + stat.IOWait
+	nonIdle := stat.User + stat.Nice + stat.System + stat.IRQ + stat.SoftIRQ + stat.Steal
+	total := idle + nonIdle
+
+	var usage float64
+	if *prevIdle != 0 && *prevTotal != 0 {
+		totalDelta := total - *prevTotal
+		idleDelta := idle - *prevIdle
+		usage = math.Max(float64(totalDelta-idleDelta)/float64(totalDelta), 0)
+	}
+
+	*prevIdle = idle
+	*prevTotal = total
+	return usage
+}
+
 // Get system statistics at the specified interval.
-// This will get the current CPU, memory, swap, and disk usage in fractions (0.0-1.0)
-func SystemStats(interval time.Duration, results chan []float64, quit chan bool) {
-	var prevIdle, prevTotal, prevIOTicks uint64
+// This will get the current CPU, memory, swap, and disk usage (one value per monitored disk) in fractions (0.0-1.0)
+// If -cpu-per-core is set, the usage of each logical core is given as well.
+func systemStats(ctx context.Context, interval time.Duration, results chan SystemStatsResult) {
+	var prevIdle, prevTotal uint64
+	var prevCoreIdle, prevCoreTotal []uint64
 	var prevUptime float64
+	prevIOTicks := make(map[string]uint64)
 
 	defer close(results)
 
+	disks, missing := monitoredDisks()
+	for _, name := range missing {
+		logWarnf("Disk '%s' not found in /proc/diskstats. Skipping.\n", name)
+	}
+	labels := append([]string{"CPU%", "Mem%", "Swap"}, disks...)
+
 	for {
-		var cpu, mem, swap, disk float64
+		var cpu, mem, swap float64
+		var cores []float64
+		diskUsage := make([]float64, len(disks))
 
 		stats, err := linuxproc.ReadStat("/proc/stat")
 		if err != nil {
-			log.Println("Failed to retrieve stat information:", err)
+			logError("Failed to retrieve stat information:", err)
 		} else {
-			idle := stats.CPUStatAll.Idle + stats.CPUStatAll.IOWait
-			nonIdle := stats.CPUStatAll.User + stats.CPUStatAll.Nice + stats.CPUStatAll.System + stats.CPUStatAll.IRQ + stats.CPUStatAll.SoftIRQ + stats.CPUStatAll.Steal
-			total := idle + nonIdle
-
-			if prevIdle != 0 && prevTotal != 0 {
-				totalDelta := total - prevTotal
-				idleDelta := idle - prevIdle
-				cpu = math.Max(float64(totalDelta-idleDelta)/float64(totalDelta), 0)
-				if *gArgs.debug {
-					log.Println("CPU%: ", cpu*100)
+			cpu = cpuUsage(stats.CPUStatAll, &prevIdle, &prevTotal)
+			logDebug("CPU%: ", cpu*100)
+
+			if *gArgs.cpuPerCore {
+				if len(prevCoreIdle) != len(stats.CPUStats) {
+					// First time, or the number of cores changed.
+					prevCoreIdle = make([]uint64, len(stats.CPUStats))
+					prevCoreTotal = make([]uint64, len(stats.CPUStats))
+				}
+				cores = make([]float64, len(stats.CPUStats))
+				for i, stat := range stats.CPUStats {
+					cores[i] = cpuUsage(stat, &prevCoreIdle[i], &prevCoreTotal[i])
 				}
 			}
-
-			prevIdle = idle
-			prevTotal = total
 		}
 
 		meminfo, err := linuxproc.ReadMemInfo("/proc/meminfo")
 		if err != nil {
-			log.Println("Failed to retrieve meminfo information:", err)
+			logError("Failed to retrieve meminfo information:", err)
 		} else {
 			mem = math.Max(float64(meminfo.MemTotal-meminfo.MemAvailable)/float64(meminfo.MemTotal), 0)
 			swap = math.Max(float64(meminfo.SwapTotal-meminfo.SwapFree)/float64(meminfo.SwapTotal), 0)
@@ -57,30 +119,28 @@ func SystemStats(interval time.Duration, results chan []float64, quit chan bool)
 				// In case there is no swap.
 				swap = 0
 			}
-			if *gArgs.debug {
-				log.Println("Mem%: ", mem*100)
-				log.Println("Swap%:", swap*100)
-			}
+			logDebug("Mem%: ", mem*100)
+			logDebug("Swap%:", swap*100)
 		}
 
 		uptime, err := linuxproc.ReadUptime("/proc/uptime")
 		if err != nil {
-			log.Println("Failed to retrieve uptime information:", err)
+			logError("Failed to retrieve uptime information:", err)
 		} else {
 			diskStats, err := linuxproc.ReadDiskStats("/proc/diskstats")
 			if err != nil {
-				log.Println("Failed to retrieve disk status information:", err)
+				logError("Failed to retrieve disk status information:", err)
 			} else {
 				for _, diskStat := range diskStats {
-					if diskStat.Name == *gArgs.sysStatDisk {
-						if prevIOTicks != 0 {
-							disk = math.Max(float64(diskStat.IOTicks-prevIOTicks)/(uptime.Total-prevUptime)/1000, 0)
-							if *gArgs.debug {
-								log.Println("Disk%:", disk*100)
-							}
+					for i, name := range disks {
+						if diskStat.Name != name {
+							continue
+						}
+						if prevIOTicks[name] != 0 {
+							diskUsage[i] = math.Max(float64(diskStat.IOTicks-prevIOTicks[name])/(uptime.Total-prevUptime)/1000, 0)
+							logDebugf("Disk%% (%s): %v\n", name, diskUsage[i]*100)
 						}
-						prevIOTicks = diskStat.IOTicks
-						break
+						prevIOTicks[name] = diskStat.IOTicks
 					}
 				}
 			}
@@ -88,10 +148,10 @@ func SystemStats(interval time.Duration, results chan []float64, quit chan bool)
 			prevUptime = uptime.Total
 		}
 
-		results <- []float64{cpu, mem, swap, disk}
+		results <- SystemStatsResult{Labels: labels, Values: append([]float64{cpu, mem, swap}, diskUsage...), Cores: cores}
 
 		select {
-		case <-quit:
+		case <-ctx.Done():
 			return
 		case <-time.After(interval):
 		}
@@ -1,112 +1,84 @@
 // Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
 // Licensed under the MIT License.
 
+//go:build windows
 // +build windows
 
-// Get system status from TypePerf (Windows edition)
+// Get system status from the performance counters (Windows edition)
 
 package main
 
 import (
-	"bufio"
-	"log"
-	"os"
-	"os/exec"
-	"strconv"
-	"strings"
+	"context"
 	"time"
 )
 
-// Start TypePerf for the specified fields (counters), and feed the results to the specified channel.
-func typeperf(interval uint, result chan []string, quit chan bool, fields []string) {
-	defer close(result)
+// The counters read by systemStats, in the order of the values produced.
+var SYSTEM_STATS_COUNTERS = []string{
+	`\Processor(_Total)\% Processor Time`,
+	`\Memory\% Committed Bytes In Use`,
+	`\Paging file(_Total)\% Usage`,
+	`\PhysicalDisk(_Total)\% Disk Time`,
+}
+
+// The counter of the usage of each logical core, read if -cpu-per-core is set.
+const CORE_COUNTER = `\Processor(*)\% Processor Time`
 
-	fields = append(append(fields, "-si"), strconv.Itoa(int(interval)))
-	cmd := exec.Command("TypePerf", fields...)
-	cmd.Stderr = os.Stderr
+// The labels of the values read from the counters.
+var SYSTEM_STATS_LABELS = []string{"CPU%", "Mem%", "Swap", "Disk"}
 
-	stdout, err := cmd.StdoutPipe()
+// Get system statistics at the specified interval.
+// This will get the current CPU, memory, swap (page file), and disk usage in fractions (0.0-1.0)
+// If -cpu-per-core is set, the usage of each logical core is given as well.
+func systemStats(ctx context.Context, interval time.Duration, results chan SystemStatsResult) {
+	defer close(results)
+
+	counters := SYSTEM_STATS_COUNTERS
+	if *gArgs.cpuPerCore {
+		counters = append(counters[:len(counters):len(counters)], CORE_COUNTER)
+	}
+	query, err := openPDHQuery(counters)
 	if err != nil {
-		log.Println("Failed to connect stdout for TypePerf: ", err)
-		return
-	} else if err := cmd.Start(); err != nil {
-		log.Println("Failed to start TypePerf:", err)
+		logError("Failed to open performance counters:", err)
 		return
 	}
+	defer query.Close()
 
-	defer cmd.Process.Kill()
-
-	reader := bufio.NewReader(stdout)
-
-	// Read the first empty line and header.
-	reader.ReadString('\n')
-	reader.ReadString('\n')
+	// System status will take a second to fill up. To avoid it feeling like lag, send an empty result directly.
+	results <- SystemStatsResult{Labels: SYSTEM_STATS_LABELS, Values: make([]float64, len(SYSTEM_STATS_COUNTERS))}
 
 	for {
 		select {
-		case <-quit:
+		case <-time.After(interval):
+		case <-ctx.Done():
 			return
-		default:
-			line, err := reader.ReadString('\n')
-			if err != nil {
-				log.Println("Read error from TypePerf:", err)
-				return
-			}
-
-			result <- strings.Split(strings.TrimSuffix(line, "\r\n"), ",")
 		}
-	}
-}
 
-// Get system statistics at the specified interval, rounded to whole seconds.
-// This will get the current CPU, memory, swap (page file), and disk usage in fractions (0.0-1.0)
-func SystemStats(interval time.Duration, results chan []float64, quit chan bool) {
-	defer close(results)
-
-	tp := make(chan []string, 5)
-	go typeperf(uint(interval.Seconds()), tp, quit, []string{
-		`\Processor(_Total)\% Processor Time`,
-		`\Memory\% Committed Bytes In Use`,
-		`\Paging file(_Total)\% Usage`,
-		`\PhysicalDisk(_Total)\% Disk Time`,
-	})
-
-	// System status will take a second to fill up. To avoid it feeling like lag, send an empty result directly.
-	results <- make([]float64, 4)
+		counterValues, err := query.Collect()
+		if err != nil {
+			logWarn("Failed to collect performance counters:", err)
+			continue
+		}
 
-	for {
-		select {
-		case fields, more := <-tp:
-			if !more {
-				return
+		result := SystemStatsResult{Labels: SYSTEM_STATS_LABELS, Values: make([]float64, len(SYSTEM_STATS_COUNTERS))}
+		for i, label := range SYSTEM_STATS_LABELS {
+			if len(counterValues[i]) > 0 {
+				result.Values[i] = counterValues[i][0].Value / 100
 			}
-			values := make([]float64, 4)
-			for i, field := range fields {
-				if i != 0 { // First field is a timestamp
-					value, err := strconv.ParseFloat(strings.TrimRight(strings.TrimLeft(field, `"`), `"`), 64)
-					if err != nil {
-						log.Printf("Failed to parse field %d in TypePerf data: '%s'\n", i, field)
-						value = 0
-					}
-					values[i-1] = value / 100
-					if *gArgs.debug {
-						switch i {
-						case 1:
-							log.Println("CPU%: ", value)
-						case 2:
-							log.Println("Mem%: ", value)
-						case 3:
-							log.Println("Swap: ", value)
-						case 4:
-							log.Println("Disk: ", value)
-						}
-					}
+			logDebugf("%s: %v\n", label, result.Values[i]*100)
+		}
+		if *gArgs.cpuPerCore {
+			for _, core := range counterValues[len(SYSTEM_STATS_COUNTERS)] {
+				if core.Instance != "_Total" {
+					result.Cores = append(result.Cores, core.Value/100)
 				}
 			}
-			results <- values
-		case <-time.After(10 * time.Second):
-			log.Println("TypePerf read timed out")
-			quit <- true // FIXME: This is not enough if the process has hung
+		}
+
+		select {
+		case results <- result:
+		case <-ctx.Done():
+			return
 		}
 	}
 }
@@ -8,137 +8,519 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"math"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
-
-	"golang.org/x/text/transform"
-	"golang.org/x/text/unicode/norm"
 )
 
 // The tag interface
 type Tag interface {
 	// Function to draw content to a tag. The function will be run in a goroutine, and must close the results channel
-	// upon exit. Put content to draw on the results channel, which expects lines up to area.Height.
-	Draw(area Area, results chan []string, quit chan bool)
+	// upon exit, which it should do once the context has been canceled. Put content to draw on the results channel,
+	// which expects lines up to area.Height.
+	Draw(ctx context.Context, area Area, results chan []string)
+}
+
+type GeneralInfo struct{}  // Tag interface for showing general information.
+type SysStats struct{}     // Tag interface for showing system status.
+type GPUStats struct{}     // Tag interface for showing status of the graphics card.
+type NowPlaying struct{}   // Tag interface for showing the currently playing media.
+type Clock struct{}        // Tag interface for showing the time and date.
+type NetStats struct{}     // Tag interface for showing the network throughput.
+type Spotify struct{}      // Tag interface for showing the track playing on Spotify.
+type Ticker struct{}       // Tag interface for showing prices of cryptocurrencies or stocks.
+type DiskSpace struct{}    // Tag interface for showing how full the filesystems are.
+type Docker struct{}       // Tag interface for showing the status of the Docker containers.
+type Calendar struct{}     // Tag interface for showing the upcoming calendar events.
+type LoadAvg struct{}      // Tag interface for showing the load averages and uptime (Linux only).
+type Ping struct{}         // Tag interface for showing the round-trip time to a host.
+type Fans struct{}         // Tag interface for showing the speed of the system fans (Linux only).
+type Message struct{}      // Tag interface for showing messages from a file.
+type Memory struct{}       // Tag interface for showing a breakdown of the memory usage (Linux only).
+type Sessions struct{}     // Tag interface for showing the logged in users (Linux and Windows only).
+type News struct{}         // Tag interface for showing headlines from an RSS or Atom feed.
+type Dashboard struct{}    // Tag interface for showing an overview of several metrics.
+type History struct{}      // Tag interface for showing a metric over time.
+type Power struct{}        // Tag interface for showing the power draw of the CPU and graphics card.
+type IPInfo struct{}       // Tag interface for showing the local and public IP addresses.
+type ActiveWindow struct{} // Tag interface for showing the title of the focused window (Linux and Windows only).
+type Timer struct{}        // Tag interface for showing a countdown timer controlled from the keyboard.
+type DiskHealth struct{}   // Tag interface for showing the temperature and SMART health of the drives (Linux only).
+type Pool struct{}         // Tag interface for showing the state and capacity of ZFS pools and RAID arrays.
+type TypingStats struct{}  // Tag interface for showing the typing speed reported by the keyboard.
+type Weather struct{}      // Tag interface for showing the current weather in detail.
+type Astro struct{}        // Tag interface for showing the phase of the moon, and the times of sunrise and sunset.
+
+// Map containing all the tags and their unique index.
+// The set_tag event will send the number that was pressed (e.g. KC_1 => 1).
+var allTags = map[uint8]Tag{
+	1:  &GeneralInfo{},
+	2:  &SysStats{},
+	3:  &GPUStats{},
+	4:  &NowPlaying{},
+	5:  &Clock{},
+	6:  &NetStats{},
+	7:  &Spotify{},
+	8:  &Ticker{},
+	9:  &DiskSpace{},
+	10: &Docker{},
+	11: &Calendar{},
+	12: &LoadAvg{},
+	13: &Ping{},
+	14: &Fans{},
+	15: &Message{},
+	16: &Memory{},
+	17: &Sessions{},
+	18: &News{},
+	19: &Dashboard{},
+	20: &History{},
+	21: &Power{},
+	22: &IPInfo{},
+	23: &ActiveWindow{},
+	24: &Timer{},
+	25: &DiskHealth{},
+	26: &Pool{},
+	27: &TypingStats{},
+	28: &Weather{},
+	29: &Astro{},
+}
+
+// Map containing the available tags, which are the ones enabled out of all the tags. Set with setTags.
+var tags = allTags
+
+// The indices of the available tags in ascending order, which are needed each time the tag is switched.
+var tagOrder = sortTagIDs(allTags)
+
+// Map containing the names of the tags, which can be used instead of the indices when selecting which tags to show.
+var tagNames = map[string]uint8{
+	"general":    1,
+	"sysstats":   2,
+	"gpu":        3,
+	"nowplaying": 4,
+	"clock":      5,
+	"netstats":   6,
+	"spotify":    7,
+	"ticker":     8,
+	"diskspace":  9,
+	"docker":     10,
+	"calendar":   11,
+	"loadavg":    12,
+	"ping":       13,
+	"fans":       14,
+	"message":    15,
+	"memory":     16,
+	"sessions":   17,
+	"news":       18,
+	"dashboard":  19,
+	"history":    20,
+	"power":      21,
+	"ipinfo":     22,
+	"window":     23,
+	"timer":      24,
+	"diskhealth": 25,
+	"pool":       26,
+	"typing":     27,
+	"weather":    28,
+	"astro":      29,
+}
+
+// Get the index of a tag given either its index or its name (in any case).
+// Returns false if there is no such tag.
+func resolveTag(ref string) (uint8, bool) {
+	ref = strings.TrimSpace(ref)
+	id, found := tagNames[strings.ToLower(ref)]
+	if !found {
+		number, err := strconv.ParseUint(ref, 10, 8)
+		if err != nil {
+			return 0, false
+		}
+		id = uint8(number)
+	}
+	_, found = tags[id]
+	return id, found
+}
+
+// Make only the tags in the comma-separated list of tag numbers or names available, so that the others are never shown.
+// All tags are available if the list is empty, or if none of the tags in it exist.
+func enableTags(list string) {
+	setTags(allTags) // The list might have changed since the last time.
+	enabled := make(map[uint8]Tag)
+	for _, field := range strings.Split(list, ",") {
+		if field = strings.TrimSpace(field); field == "" {
+			continue
+		}
+		if id, found := resolveTag(field); found {
+			enabled[id] = tags[id]
+		} else {
+			logWarnf("Enabled tag '%s' doesn't exist.\n", field)
+		}
+	}
+
+	if len(enabled) < 1 {
+		if strings.TrimSpace(list) != "" {
+			logWarn("None of the enabled tags exist. Enabling all tags.")
+		}
+		return
+	}
+	setTags(enabled)
+}
+
+// Set the available tags, and sort their indices.
+func setTags(available map[uint8]Tag) {
+	tags = available
+	tagOrder = sortTagIDs(available)
+}
+
+// Get the indices of a set of tags in ascending order.
+func sortTagIDs(set map[uint8]Tag) []uint8 {
+	ids := make([]uint8, 0, len(set))
+	for id := range set {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// Get the indices of the available tags in ascending order. The slice is shared, and mustn't be modified.
+func sortedTagIDs() []uint8 {
+	return tagOrder
 }
 
-type GeneralInfo struct{} // Tag interface for showing general information.
-type SysStats struct{}    // Tag interface for showing system status.
-type GPUStats struct{}    // Tag interface for showing status of the graphics card.
+// Get the tag that comes after (or before, if forward is false) the current one, wrapping around at the ends.
+// Returns false if there are no tags.
+func cycleTag(current uint8, forward bool) (uint8, bool) {
+	ids := sortedTagIDs()
+	if len(ids) < 1 {
+		return 0, false
+	}
+
+	if forward {
+		for _, id := range ids {
+			if id > current {
+				return id, true
+			}
+		}
+		return ids[0], true
+	}
 
-// Map containing the available tags and their unique index.
-// It should be kept consecutive, and starting from 1, if you wish to use the increment/decrement feature. The set_tag
-// event will send the number that was pressed (e.g. KC_1 => 1).
-var tags = map[uint8]Tag{
-	1: &GeneralInfo{},
-	2: &SysStats{},
-	3: &GPUStats{},
+	for i := len(ids) - 1; i >= 0; i-- {
+		if ids[i] < current {
+			return ids[i], true
+		}
+	}
+	return ids[len(ids)-1], true
 }
 
 // Draws some general information.
 // The first line is the time, the second is the current layer, the third a motivational message or number of
 // unread messages, and the fourth is the current temperature.
-func (*GeneralInfo) Draw(area Area, results chan []string, quit chan bool) {
+func (*GeneralInfo) Draw(ctx context.Context, area Area, results chan []string) {
 	defer close(results)
 
-	info := []string{"", "%l", "You look great today!", ""}
+	info := []string{"", "%l", "", ""}
+	message := "You look great today!"
+	scroll := ScrollText(message, area.Width)
 	unreadMails := make(chan int64, 5)
 	weatherReport := make(chan WeatherResult, 5)
-	stop := make(chan bool)
-	stopped := false
+	weatherForecast := make(chan []WeatherResult, 5)
+	var current WeatherResult
+	var forecast []WeatherResult
+	trend := ""        // The trend of the temperature since the previous report.
+	done := ctx.Done() // Set to nil once the tag has been stopped.
 	wait := 0
 
-	if *gArgs.gmailCredentials != "" {
-		go GmailStats(*gArgs.gmailCredentials, *gArgs.gmailLabel, unreadMails, stop)
+	if StartMailStats(ctx, unreadMails) {
 		wait++
 	}
 
 	var location string
-	if *gArgs.weatherKey != "" && *gArgs.weatherLocation != "" {
-		go WeatherStats(*gArgs.weatherKey, *gArgs.temperatureUnit, *gArgs.weatherLocation, weatherReport, stop)
+	if StartWeatherStats(ctx, weatherReport) {
 		wait++
 
-		// The firmware only supports Latin characters, without diacritics. These need to be either normalized, or
-		// removed completely before drawn to the display, otherwise it just won't look right.
-		isNonLatin := func(r rune) bool { return r >= 0x80 }
-		t := transform.Chain(norm.NFD, transform.RemoveFunc(isNonLatin), norm.NFC)
-		// Assume that the location is <city>,<country>
-		location, _, _ = transform.String(t, strings.Split(*gArgs.weatherLocation, ",")[0])
+		// The forecast is only available from OpenWeatherMap.
+		if *gArgs.weatherForecast > 0 && *gArgs.weatherProvider == OPEN_WEATHER_MAP {
+			go WeatherForecast(ctx, *gArgs.weatherKey, *gArgs.temperatureUnit, *gArgs.weatherLocation,
+				int(*gArgs.weatherForecast), weatherForecast)
+			wait++
+		}
+
+		// Assume that the location is <city>,<country>. It's optional for providers that use the position instead.
+		location = ToLatin(strings.Split(*gArgs.weatherLocation, ",")[0])
 	}
 
 	for {
 		info[0] = time.Now().Local().Format("Mon Jan _2 15:04:05")
+		info[2] = scroll()
 		results <- info
 
 		select {
 		case numUnread, more := <-unreadMails:
 			if !more {
-				info[2] = ""
+				unreadMails = nil
+				message = ""
+				if ctx.Err() == nil {
+					message = ERROR_ICON + " No mail status"
+				}
+				scroll = ScrollText(message, area.Width)
 				wait--
-				if stopped && wait < 1 {
+				if ctx.Err() != nil && wait < 1 {
 					return
 				}
 				continue
 			}
-			info[2] = fmt.Sprintf("%s%d unread emails", MAIL_ICON, numUnread)
+			if unread := fmt.Sprintf("%s%d unread emails", MAIL_ICON, numUnread); unread != message {
+				message = unread
+				scroll = ScrollText(message, area.Width)
+			}
 		case weather, more := <-weatherReport:
 			if !more {
+				weatherReport = nil
 				info[3] = ""
+				if ctx.Err() == nil {
+					info[3] = ERROR_ICON + " No weather"
+				}
+				wait--
+				if ctx.Err() != nil && wait < 1 {
+					return
+				}
+				continue
+			}
+			if current.Time != (time.Time{}) {
+				trend = trendIcon(current.Temperature, weather.Temperature)
+			}
+			current = weather
+			info[3] = weatherLine(area, current, trend, forecast, location)
+		case entries, more := <-weatherForecast:
+			if !more {
+				weatherForecast = nil // Keep showing the current weather.
 				wait--
-				if stopped && wait < 1 {
+				if ctx.Err() != nil && wait < 1 {
 					return
 				}
 				continue
 			}
-			info[3] = fmt.Sprintf("%s%d%s%s in %s",
-				WEATHER_ICONS[weather.Weather],
-				int(math.Round(weather.Temperature)),
-				DEGREES_ICON,
-				*gArgs.temperatureUnit,
-				location)
+			forecast = entries
+			if current.Time != (time.Time{}) {
+				info[3] = weatherLine(area, current, trend, forecast, location)
+			}
 		case <-time.After(1 * time.Second):
-		case <-quit:
+		case <-done:
 			if wait < 1 {
 				return
-			} else if !stopped {
-				close(stop)
 			}
-			stopped = true
+			done = nil
+		}
+	}
+}
+
+// Show an error message in place of the content of a tag whose data source has failed, and wait for the tag to be
+// stopped. Does nothing if the data source stopped because the tag was stopped.
+func showSourceError(ctx context.Context, area Area, message string, results chan []string) {
+	if ctx.Err() != nil {
+		return
+	}
+
+	results <- []string{"", CenterText(ERROR_ICON+" "+message, area.Width)}
+	<-ctx.Done()
+}
+
+// Clamp a fraction to 0-1, treating invalid values as zero.
+func clampFraction(value float64) float64 {
+	value = math.Min(math.Max(0.0, value), 1.0)
+	if math.IsInf(value, 0) || math.IsNaN(value) {
+		value = 0.0
+	}
+	return value
+}
+
+// Draw a vertical bar spanning the specified number of rows, filled from the bottom according to the value (0-1).
+// Returns the character to draw on each row, from the top row to the bottom row.
+func drawVerticalBar(value float64, height uint8) []string {
+	const steps = len(VERTICAL_BAR_CHARS) // The number of steps each row can be filled in.
+
+	filled := int(math.Round(clampFraction(value) * float64(int(height)*steps)))
+	rows := make([]string, height)
+	for i := range rows {
+		// Count the rows from the bottom.
+		fill := filled - (int(height)-1-i)*steps
+		if fill <= 0 {
+			rows[i] = " "
+		} else if fill >= steps {
+			rows[i] = VERTICAL_BAR_CHARS[steps-1:]
+		} else {
+			rows[i] = VERTICAL_BAR_CHARS[fill-1 : fill]
+		}
+	}
+	return rows
+}
+
+// Lay out the usage of each CPU core as short bars in a grid, filling the rows first.
+// Returns nil if the bars don't fit on the screen.
+func drawCores(area Area, cores []float64) []string {
+	if area.Height < 1 || len(cores) < 1 {
+		return nil
+	}
+
+	perRow := (len(cores) + int(area.Height) - 1) / int(area.Height)
+	cellWidth := (int(area.Width) - (perRow - 1)) / perRow // Cells are separated by a space.
+	labelLen := len(strconv.Itoa(len(cores) - 1))
+	barLen := cellWidth - labelLen - 2
+	if barLen < 2 {
+		return nil
+	}
+
+	output := make([]string, (len(cores)+perRow-1)/perRow)
+	for i, value := range cores {
+		row := i / perRow
+		if i%perRow != 0 {
+			output[row] += " "
+		}
+		output[row] += fmt.Sprintf("%*d%s",
+			labelLen,
+			i,
+			drawBar(value, barLen))
+	}
+	return output
+}
+
+// Get the line describing the current weather at a location, with the trend of the temperature, followed by as many
+// forecast entries as fit.
+func weatherLine(area Area, current WeatherResult, trend string, forecast []WeatherResult, location string) string {
+	line := fmt.Sprintf("%s%d%s%s%s",
+		WEATHER_ICONS[current.Weather],
+		int(math.Round(current.Temperature)),
+		DEGREES_ICON,
+		*gArgs.temperatureUnit,
+		trend)
+	if len(forecast) < 1 {
+		if location != "" {
+			line += " in " + location
+		}
+		return line
+	}
+
+	// Make room for the forecast by leaving out the location.
+	line = fmt.Sprintf("%s%d%s%s", WEATHER_ICONS[current.Weather], int(math.Round(current.Temperature)), DEGREES_ICON, trend)
+	for _, entry := range forecast {
+		next := fmt.Sprintf(" %s%d", WEATHER_ICONS[entry.Weather], int(math.Round(entry.Temperature)))
+		if len(line)+len(next) > int(area.Width) {
+			break
 		}
+		line += next
+	}
+	return line
+}
+
+// Get the arrow showing whether a temperature has risen or fallen since the previous reading, as it's shown (i.e.
+// rounded). Returns nothing unless enabled with -temp-trend, since it requires the arrow glyphs in the font.
+func trendIcon(previous, current float64) string {
+	if !*gArgs.tempTrend {
+		return ""
+	}
+	switch diff := math.Round(current) - math.Round(previous); {
+	case diff > 0:
+		return UP_ARROW_ICON
+	case diff < 0:
+		return DOWN_ARROW_ICON
+	default:
+		return FLAT_ICON
+	}
+}
+
+// Get the icon marking a value that has crossed its alert threshold, or nothing if it hasn't. A threshold of zero (or
+// less) disables the alert.
+func alertMark(value, threshold float64) string {
+	if threshold > 0 && value >= threshold {
+		return ALERT_ICON
 	}
+	return ""
 }
 
 // Draw system status as bar graphs.
-// The bars are CPU, memory, swap (page file), and disk usage as percentages.
-func (*SysStats) Draw(area Area, results chan []string, quit chan bool) {
+// The bars are CPU, memory, swap (page file), and disk usage as percentages. On Linux there is one disk bar for each
+// monitored disk, labeled with the name of the disk. The disk bars share the rows left after the others, several to a
+// row if there are more disks than rows.
+// If -cpu-per-core is set, the usage of each CPU core is drawn instead, as long as they fit on the screen.
+func (*SysStats) Draw(ctx context.Context, area Area, results chan []string) {
 	defer close(results)
 
-	sysStat := make(chan []float64, 5)
-	columns := []string{"CPU%", "Mem%", "Swap", "Disk"}
+	const disksFrom = 3 // The CPU, memory, and swap come before the disks, on a row each.
+	sysStat := make(chan SystemStatsResult, 5)
+	sensor := FindCPUTemperatureSensor(*gArgs.cpuTempSensor)
+	var smoothing barSmoothing
 
-	go SystemStats(1*time.Second, sysStat, quit)
+	go SystemStats(ctx, *gArgs.sysStatInterval, sysStat)
 	for {
 		select {
-		case values, more := <-sysStat:
+		case result, more := <-sysStat:
 			if !more {
+				showSourceError(ctx, area, "No system status", results)
 				return
 			}
+			values := smoothing.Smooth(append(append([]float64(nil), result.Values...), result.Cores...))
+			labels := result.Labels
 
-			output := make([]string, len(values))
-			for i, value := range values {
-				value := math.Min(math.Max(0.0, value), 1.0)
-				if math.IsInf(value, 0) || math.IsNaN(value) {
-					value = 0.0
+			if len(result.Cores) > 0 {
+				if output := drawCores(area, values[len(result.Values):]); output != nil {
+					results <- output
+					continue
+				}
+			}
+			values = values[:len(result.Values)]
+
+			// The CPU temperature is shown after the CPU bar, if there is a sensor.
+			suffix := ""
+			if sensor != "" {
+				if temp, err := ReadCPUTemperature(sensor); err == nil {
+					temp = ConvertTemperature(temp, *gArgs.temperatureUnit)
+					suffix = fmt.Sprintf("%s%d%s%s",
+						alertMark(temp, *gArgs.cpuTempAlert),
+						int(math.Round(temp)),
+						DEGREES_ICON,
+						*gArgs.temperatureUnit)
+				}
+			}
+
+			output := make([]string, 0, area.Height)
+			for i, value := range values[:disksFrom] {
+				value := clampFraction(value)
+				mark := ""
+				if i == 0 {
+					mark = alertMark(clampFraction(result.Values[0])*100, *gArgs.cpuAlert)
+				}
+				barLen := int(area.Width) - len(mark) - len(labels[i]) - 2
+				if i == 0 {
+					barLen -= len(suffix)
 				}
-				barLen := int(area.Width) - len(columns[i]) - 2
 				// Draw the label and a nice bar.
-				output[i] = fmt.Sprintf("%s[%-*s]",
-					columns[i],
-					barLen,
-					strings.Repeat(BAR_CHAR, int(math.Round(float64(barLen)*value))))
+				output = append(output, fmt.Sprintf("%s%s%s",
+					mark,
+					labels[i],
+					drawBar(value, int(math.Max(float64(barLen), 0)))))
+			}
+			output[0] += suffix
+
+			disks := values[disksFrom:]
+			if rows := int(area.Height) - len(output); rows > 0 && len(disks) > 0 {
+				perRow := (len(disks) + rows - 1) / rows
+				width := (int(area.Width) - (perRow - 1)) / perRow // The bars on a row are separated by a space.
+				for first := 0; first < len(disks); first += perRow {
+					var bars []string
+					for i := first; i < first+perRow && i < len(disks); i++ {
+						label := labels[disksFrom+i]
+						barLen := int(math.Max(float64(width-len(label)-2), 0))
+						bars = append(bars, label+drawBar(disks[i], barLen))
+					}
+					output = append(output, strings.Join(bars, " "))
+				}
+			}
+			if len(output) > int(area.Height) {
+				output = output[:area.Height]
 			}
 			results <- output
 		}
@@ -146,46 +528,54 @@ func (*SysStats) Draw(area Area, results chan []string, quit chan bool) {
 }
 
 // Draw status of the graphics card as bar graphs.
-// The bars are GPU, memory, and PCIe bus utilization in percentages. It will also show the current temperature.
-func (*GPUStats) Draw(area Area, results chan []string, quit chan bool) {
+// The bars are GPU, memory, and PCIe bus utilization in percentages. It will also show the current temperature, and the
+// amount of memory used if it's known and fits after the bar.
+func (*GPUStats) Draw(ctx context.Context, area Area, results chan []string) {
 	defer close(results)
 
 	gpuStats := make(chan GraphicCardResult, 5)
 	columns := []string{"GPU%", "Mem%", "PCIe", FAN_ICON_2}
+	previousTemp := math.NaN()
+	var smoothing barSmoothing
 
-	go GraphicCardStats(1*time.Second, *gArgs.temperatureUnit, gpuStats, quit)
+	go GraphicCardStats(ctx, *gArgs.gpuInterval, *gArgs.temperatureUnit, gpuStats)
 	for {
 		select {
 		case result, more := <-gpuStats:
 			if !more {
+				showSourceError(ctx, area, "No graphics card", results)
 				return
 			}
 
-			values := []float64{
+			values := smoothing.Smooth([]float64{
 				result.GPU,
 				result.Memory,
 				result.PCIBandwidth,
 				result.FanSpeed,
-			}
+			})
 
 			output := make([]string, len(values))
 			for i, value := range values {
 				label := columns[i]
 
-				// Clamp
-				value := math.Min(math.Max(0.0, value), 1.0)
-				if math.IsInf(value, 0) || math.IsNaN(value) {
-					value = 0.0
-				}
+				value := clampFraction(value)
 
 				prefix := ""
-				if i == len(values)-1 { // Temperature + Fan speed
-					temp := strconv.Itoa(int(math.Round(result.Temperature)))
-					prefix = fmt.Sprintf("Temp:%s%s%s%s",
+				if i == 0 {
+					prefix = alertMark(clampFraction(result.GPU)*100, *gArgs.gpuAlert)
+				} else if i == len(values)-1 { // Temperature + Fan speed
+					temp := alertMark(result.Temperature, *gArgs.gpuTempAlert) + strconv.Itoa(int(math.Round(result.Temperature)))
+					trend := ""
+					if !math.IsNaN(previousTemp) {
+						trend = trendIcon(previousTemp, result.Temperature)
+					}
+					previousTemp = result.Temperature
+					prefix = fmt.Sprintf("Temp:%s%s%s%s%s",
 						temp,
 						DEGREES_ICON,
 						*gArgs.temperatureUnit,
-						strings.Repeat(" ", 4-len(temp)))
+						trend,
+						strings.Repeat(" ", int(math.Max(float64(4-len(temp)), 0))))
 
 					// Swap icon each iteration
 					if columns[i] == FAN_ICON_1 {
@@ -196,14 +586,1402 @@ func (*GPUStats) Draw(area Area, results chan []string, quit chan bool) {
 				}
 
 				barLen := int(area.Width) - len(label) - len(prefix) - 2
+				suffix := ""
+				if i == 1 && result.MemoryTotal > 0 && barLen > MIN_BAR_LENGTH {
+					suffix = fitText(uint8(barLen-MIN_BAR_LENGTH),
+						fmt.Sprintf("%d/%dMB", int(result.MemoryUsed/(1024*1024)), int(result.MemoryTotal/(1024*1024))),
+						fmt.Sprintf("%.1f/%.1fGB", result.MemoryUsed/(1024*1024*1024), result.MemoryTotal/(1024*1024*1024)),
+						"")
+					barLen -= len(suffix)
+				}
 				// Draw the label and a nice bar.
-				output[i] = fmt.Sprintf("%s%s[%-*s]",
+				output[i] = fmt.Sprintf("%s%s%s%s",
 					prefix,
 					columns[i],
-					barLen,
-					strings.Repeat(BAR_CHAR, int(math.Round(float64(barLen)*value))))
+					drawBar(value, barLen),
+					suffix)
+			}
+			results <- output
+		}
+	}
+}
+
+// Draw the currently playing media.
+// The first line is the title, the second the artist, and the third a bar showing the progress through the track.
+// Nothing is drawn if nothing is playing.
+func (*NowPlaying) Draw(ctx context.Context, area Area, results chan []string) {
+	defer close(results)
+
+	media := make(chan MediaResult, 5)
+	var view mediaView
+
+	go NowPlayingStats(ctx, 1*time.Second, media)
+	for {
+		select {
+		case result, more := <-media:
+			if !more {
+				showSourceError(ctx, area, "No media player", results)
+				return
+			}
+
+			if !result.Playing {
+				results <- []string{"", "", ""}
+				continue
+			}
+			results <- view.lines(area, result)
+		}
+	}
+}
+
+// Keeps track of the scrolling title and artist of media being drawn.
+type mediaView struct {
+	title, artist             string        // The title and artist currently shown.
+	scrollTitle, scrollArtist func() string // The scrolling title and artist.
+}
+
+// Get the lines showing the media: the title, the artist, and a bar showing the progress through the track.
+func (view *mediaView) lines(area Area, result MediaResult) []string {
+	progress := 0.0
+	if result.Duration > 0 {
+		progress = clampFraction(float64(result.Position) / float64(result.Duration))
+	}
+
+	// Long titles and artists scroll, starting over whenever the track changes.
+	if view.scrollTitle == nil || result.Title != view.title {
+		view.title = result.Title
+		view.scrollTitle = ScrollText(ToLatin(view.title), area.Width-uint8(len(MUSIC_ICON)))
+	}
+	if view.scrollArtist == nil || result.Artist != view.artist {
+		view.artist = result.Artist
+		view.scrollArtist = ScrollText(ToLatin(view.artist), area.Width)
+	}
+
+	barLen := int(area.Width) - 2
+	return []string{
+		MUSIC_ICON + view.scrollTitle(),
+		view.scrollArtist(),
+		drawBar(progress, barLen),
+	}
+}
+
+// Draw the track currently playing on Spotify.
+// The first line is the title, the second the artist, and the third a bar showing the progress through the track.
+func (*Spotify) Draw(ctx context.Context, area Area, results chan []string) {
+	defer close(results)
+
+	if *gArgs.spotifyClientID == "" || *gArgs.spotifyClientSecret == "" {
+		results <- []string{"", CenterText("Spotify not set up", area.Width)}
+		<-ctx.Done()
+		return
+	}
+
+	media := make(chan MediaResult, 5)
+	var view mediaView
+
+	go SpotifyStats(ctx, *gArgs.spotifyClientID, *gArgs.spotifyClientSecret, 2*time.Second, media)
+	for {
+		select {
+		case result, more := <-media:
+			if !more {
+				showSourceError(ctx, area, "Spotify unavailable", results)
+				return
+			}
+
+			if !result.Playing {
+				results <- []string{"", CenterText("Nothing playing", area.Width), ""}
+				continue
+			}
+			results <- view.lines(area, result)
+		}
+	}
+}
+
+// Draw the current time and date, centered on the screen.
+// The first line is the time, the second the date, and the third the time in a second timezone, if one has been
+// specified.
+func (*Clock) Draw(ctx context.Context, area Area, results chan []string) {
+	defer close(results)
+
+	var location *time.Location
+	if *gArgs.clockTimezone != "" {
+		var err error
+		if location, err = time.LoadLocation(*gArgs.clockTimezone); err != nil {
+			logWarnf("Failed to load timezone '%s': %v\n", *gArgs.clockTimezone, err)
+		}
+	}
+
+	for {
+		now := time.Now().Local()
+		lines := []string{
+			CenterText(now.Format(*gArgs.clockFormat), area.Width),
+			CenterText(now.Format("Mon Jan _2 2006"), area.Width),
+		}
+		if location != nil {
+			other := now.In(location)
+			lines = append(lines, CenterText(other.Format(*gArgs.clockFormat)+" "+other.Format("MST"), area.Width))
+		}
+		results <- lines
+
+		select {
+		case <-time.After(1 * time.Second):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Format a rate in bytes per second as bits per second, with a suitable prefix (e.g. "12.3M").
+func formatBitRate(bytesPerSecond float64) string {
+	bits := bytesPerSecond * 8
+	for _, prefix := range []string{"", "k", "M"} {
+		if bits < 1000 {
+			return fmt.Sprintf("%.3g%s", bits, prefix)
+		}
+		bits /= 1000
+	}
+	return fmt.Sprintf("%.3gG", bits)
+}
+
+// Draw the network throughput as bar graphs.
+// The bars are the download and upload rates, relative to the maximum given by -net-max-mbps. The third line shows the
+// rates in bits per second.
+func (*NetStats) Draw(ctx context.Context, area Area, results chan []string) {
+	defer close(results)
+
+	netStats := make(chan NetworkResult, 5)
+	columns := []string{"Down", "Up  "}
+	maxRate := *gArgs.netMaxMbps * 1000 * 1000 / 8 // In bytes per second.
+
+	// The first result takes a while, so show empty bars in the meantime.
+	results <- []string{
+		columns[0] + drawBar(0, int(area.Width)-len(columns[0])-2),
+		columns[1] + drawBar(0, int(area.Width)-len(columns[1])-2),
+	}
+
+	go NetworkStats(ctx, *gArgs.netInterface, 1*time.Second, netStats)
+	for {
+		select {
+		case result, more := <-netStats:
+			if !more {
+				showSourceError(ctx, area, "No network status", results)
+				return
+			}
+
+			values := []float64{result.Received, result.Sent}
+			output := make([]string, len(values), len(values)+1)
+			for i, value := range values {
+				value := clampFraction(value / maxRate)
+				barLen := int(area.Width) - len(columns[i]) - 2
+				// Draw the label and a nice bar.
+				output[i] = fmt.Sprintf("%s%s",
+					columns[i],
+					drawBar(value, barLen))
+			}
+			output = append(output, fmt.Sprintf("D:%sb/s U:%sb/s", formatBitRate(result.Received), formatBitRate(result.Sent)))
+			results <- output
+		}
+	}
+}
+
+// Draw the prices of cryptocurrencies or stocks, one at a time.
+// The first line is the symbol, the second the price, and the third the change over the last 24 hours.
+func (*Ticker) Draw(ctx context.Context, area Area, results chan []string) {
+	defer close(results)
+
+	var symbols []string
+	for _, symbol := range strings.Split(*gArgs.tickerSymbols, ",") {
+		if symbol = strings.TrimSpace(symbol); symbol != "" {
+			symbols = append(symbols, symbol)
+		}
+	}
+
+	tickerStats := make(chan []TickerResult, 5)
+	var prices []TickerResult
+	current := 0
+
+	results <- []string{"", CenterText("Fetching prices...", area.Width)}
+
+	go TickerStats(ctx, *gArgs.tickerProvider, symbols, tickerStats)
+	for {
+		select {
+		case result, more := <-tickerStats:
+			if !more {
+				showSourceError(ctx, area, "No prices", results)
+				return
+			}
+			prices = result
+			if current >= len(prices) {
+				current = 0
+			}
+		case <-time.After(*gArgs.tickerRotation):
+			if len(prices) > 0 {
+				current = (current + 1) % len(prices)
+			}
+		case <-ctx.Done():
+			return
+		}
+
+		if len(prices) > 0 {
+			price := prices[current]
+			arrow := UP_ARROW_ICON
+			if price.Change < 0 {
+				arrow = DOWN_ARROW_ICON
+			}
+			results <- []string{
+				CenterText(strings.ToUpper(price.Symbol), area.Width),
+				CenterText(fmt.Sprintf("$%.2f", price.Price), area.Width),
+				CenterText(fmt.Sprintf("%s%.2f%%", arrow, math.Abs(price.Change)), area.Width),
+			}
+		}
+	}
+}
+
+// Draw how full the filesystems are as bar graphs.
+// There is one bar for each mount point given by -diskspace-mounts, labeled with the last part of the mount point, and
+// followed by the free space if it fits.
+func (*DiskSpace) Draw(ctx context.Context, area Area, results chan []string) {
+	defer close(results)
+
+	var mounts []string
+	for _, mount := range strings.Split(*gArgs.diskSpaceMounts, ",") {
+		if mount = strings.TrimSpace(mount); mount != "" {
+			mounts = append(mounts, mount)
+		}
+	}
+
+	diskSpace := make(chan []DiskSpaceResult, 5)
+
+	go DiskSpaceStats(ctx, mounts, 10*time.Second, diskSpace)
+	for {
+		select {
+		case spaces, more := <-diskSpace:
+			if !more {
+				showSourceError(ctx, area, "No disk space", results)
+				return
+			}
+
+			output := make([]string, len(spaces))
+			for i, space := range spaces {
+				// E.g. "/" => "/", "/home" => "home", and "C:\" => "C:"
+				label := filepath.Base(space.Mount)
+				if label == "." || label == string(filepath.Separator) {
+					label = strings.TrimSuffix(space.Mount, string(filepath.Separator))
+					if label == "" {
+						label = space.Mount
+					}
+				}
+
+				used := 0.0
+				if space.Total > 0 {
+					used = clampFraction(float64(space.Total-space.Free) / float64(space.Total))
+				}
+
+				free := fmt.Sprintf(" %dG", space.Free/1000/1000/1000)
+				barLen := int(area.Width) - len(label) - 2
+				if barLen-len(free) >= 4 {
+					barLen -= len(free)
+				} else {
+					free = ""
+				}
+
+				// Draw the label, a nice bar, and the free space.
+				output[i] = fmt.Sprintf("%s%s%s",
+					label,
+					drawBar(used, barLen),
+					free)
+			}
+			results <- output
+		}
+	}
+}
+
+// Draw the status of the Docker containers.
+// The first line is the number of running containers, followed by the name and state of as many containers as fit,
+// running ones first.
+func (*Docker) Draw(ctx context.Context, area Area, results chan []string) {
+	defer close(results)
+
+	dockerStats := make(chan DockerResult, 5)
+
+	go DockerStats(ctx, *gArgs.dockerSocket, 5*time.Second, dockerStats)
+	for {
+		select {
+		case result, more := <-dockerStats:
+			if !more {
+				showSourceError(ctx, area, "Docker unavailable", results)
+				return
+			}
+
+			output := []string{fmt.Sprintf("Containers: %d/%d up", result.Running, len(result.Containers))}
+			for _, container := range result.Containers {
+				if len(output) >= int(area.Height) {
+					break
+				}
+				output = append(output, containerLine(area, container))
 			}
 			results <- output
 		}
 	}
 }
+
+// Get the line showing the name of a Docker container, with its state right-aligned, cutting the name short if needed.
+func containerLine(area Area, container DockerContainer) string {
+	name, state := ToLatin(container.Name), ToLatin(container.State)
+	nameLen := int(area.Width) - len(state) - 1
+	if nameLen > 0 && len(name) > nameLen {
+		name = name[:nameLen]
+	}
+	return fmt.Sprintf("%-*s %s", nameLen, name, state)
+}
+
+// Draw the upcoming calendar events, one per line, prefixed by when they start.
+func (*Calendar) Draw(ctx context.Context, area Area, results chan []string) {
+	defer close(results)
+
+	if *gArgs.gmailCredentials == "" {
+		results <- []string{"", CenterText("Calendar not set up", area.Width)}
+		<-ctx.Done()
+		return
+	}
+
+	calendarStats := make(chan []CalendarEvent, 5)
+	var events []CalendarEvent
+	fetched := false
+
+	results <- []string{"", CenterText("Fetching events...", area.Width)}
+
+	go CalendarStats(ctx, *gArgs.gmailCredentials, *gArgs.calendarID, int(area.Height), calendarStats)
+	for {
+		select {
+		case result, more := <-calendarStats:
+			if !more {
+				showSourceError(ctx, area, "No calendar", results)
+				return
+			}
+			events = result
+			fetched = true
+		case <-time.After(30 * time.Second):
+			// Update the relative times.
+		}
+
+		if !fetched {
+			continue
+		}
+
+		now := time.Now()
+		output := make([]string, 0, area.Height)
+		for _, event := range events {
+			if len(output) >= int(area.Height) {
+				break
+			}
+			if !event.End.IsZero() && !now.Before(event.End) {
+				continue // Already over.
+			}
+			line := eventTime(now, event) + " " + ToLatin(event.Title)
+			if len(line) > int(area.Width) {
+				line = line[:area.Width]
+			}
+			output = append(output, line)
+		}
+		if len(output) < 1 {
+			output = []string{"", CenterText("No upcoming events", area.Width)}
+		}
+		results <- output
+	}
+}
+
+// Get a short description of when an event starts, relative to now when it's soon.
+func eventTime(now time.Time, event CalendarEvent) string {
+	year, month, day := now.Date()
+	today := time.Date(year, month, day, 0, 0, 0, 0, now.Location())
+	tomorrow := today.AddDate(0, 0, 1)
+
+	if event.AllDay {
+		switch {
+		case event.Start.Before(tomorrow):
+			return "Today"
+		case event.Start.Before(tomorrow.AddDate(0, 0, 1)):
+			return "Tmrw"
+		default:
+			return event.Start.Format("Mon")
+		}
+	}
+
+	until := event.Start.Sub(now)
+	switch {
+	case until <= 0:
+		return "Now"
+	case until < time.Hour:
+		return fmt.Sprintf("in %dm", int(math.Ceil(until.Minutes())))
+	case event.Start.Before(tomorrow):
+		return event.Start.Local().Format("15:04")
+	default:
+		return event.Start.Local().Format("Mon 15:04")
+	}
+}
+
+// Draw the load averages over the last 1, 5, and 15 minutes, and how long the system has been up.
+func (*LoadAvg) Draw(ctx context.Context, area Area, results chan []string) {
+	defer close(results)
+
+	loadAvgStats := make(chan LoadAvgResult, 5)
+
+	go LoadAvgStats(ctx, 5*time.Second, loadAvgStats)
+	for {
+		select {
+		case result, more := <-loadAvgStats:
+			if !more {
+				showSourceError(ctx, area, "No load average", results)
+				return
+			}
+
+			results <- []string{
+				CenterText("Load average", area.Width),
+				CenterText(fmt.Sprintf("%.2f %.2f %.2f", result.Load1, result.Load5, result.Load15), area.Width),
+				"",
+				CenterText("Up "+formatUptime(result.Uptime), area.Width),
+			}
+		}
+	}
+}
+
+// Draw the round-trip time to a host, and a graph of the recent round-trip times.
+// The graph has one column for each of the last samples, scaled so that the slowest one fills the column. Timeouts are
+// drawn as an "x".
+func (*Ping) Draw(ctx context.Context, area Area, results chan []string) {
+	defer close(results)
+
+	pingStats := make(chan PingResult, 5)
+	samples := make([]PingResult, area.Width) // Ring buffer of the last samples.
+	next := 0
+	count := 0
+	title := CenterText("Ping "+*gArgs.pingHost, area.Width)
+
+	results <- []string{title, CenterText("Pinging...", area.Width)}
+
+	go PingStats(ctx, *gArgs.pingHost, 1*time.Second, pingStats)
+	for {
+		select {
+		case result, more := <-pingStats:
+			if !more {
+				showSourceError(ctx, area, "Ping failed", results)
+				return
+			}
+
+			samples[next] = result
+			next = (next + 1) % len(samples)
+			if count < len(samples) {
+				count++
+			}
+
+			var slowest time.Duration
+			for _, sample := range samples {
+				if sample.RTT > slowest {
+					slowest = sample.RTT
+				}
+			}
+
+			// Draw the graph from the oldest sample to the newest.
+			graph := strings.Repeat(" ", len(samples)-count)
+			for i := len(samples) - count; i < len(samples); i++ {
+				sample := samples[(next+i)%len(samples)]
+				if sample.Timeout {
+					graph += "x"
+				} else {
+					graph += drawVerticalBar(float64(sample.RTT)/float64(slowest), 1)[0]
+				}
+			}
+
+			rtt := "timeout"
+			if !result.Timeout {
+				rtt = fmt.Sprintf("%.1f ms", float64(result.RTT)/float64(time.Millisecond))
+			}
+			results <- []string{title, CenterText(rtt, area.Width), "", graph}
+		}
+	}
+}
+
+// Draw the speed of the system fans as bar graphs, one fan per line.
+// Each line has an animated fan icon, the first few characters of the fan's name, a bar full at -fan-max-rpm, and the
+// speed in RPM.
+func (*Fans) Draw(ctx context.Context, area Area, results chan []string) {
+	defer close(results)
+
+	var names []string
+	for _, name := range strings.Split(*gArgs.fanSensors, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+
+	fanStats := make(chan []FanResult, 5)
+	icon := FAN_ICON_2
+
+	go FanStats(ctx, names, 1*time.Second, fanStats)
+	for {
+		select {
+		case fans, more := <-fanStats:
+			if !more {
+				showSourceError(ctx, area, "No fans", results)
+				return
+			}
+
+			// Swap icon each iteration
+			if icon == FAN_ICON_1 {
+				icon = FAN_ICON_2
+			} else {
+				icon = FAN_ICON_1
+			}
+
+			output := make([]string, 0, len(fans))
+			for _, fan := range fans {
+				if len(output) >= int(area.Height) {
+					break
+				}
+				name := ToLatin(fan.Name)
+				if len(name) > 4 {
+					name = name[:4]
+				}
+				rpm := strconv.Itoa(int(math.Round(fan.RPM)))
+				barLen := int(area.Width) - len(icon) - 4 - len(rpm) - 3
+				output = append(output, fmt.Sprintf("%s%-4s%s %s",
+					icon,
+					name,
+					drawBar(fan.RPM / *gArgs.fanMaxRPM, barLen),
+					rpm))
+			}
+			results <- output
+		}
+	}
+}
+
+// Draw the messages from the message file, one at a time, wrapped across the lines of the screen.
+func (*Message) Draw(ctx context.Context, area Area, results chan []string) {
+	defer close(results)
+
+	if *gArgs.messageFile == "" {
+		results <- []string{"", CenterText("No message file", area.Width)}
+		<-ctx.Done()
+		return
+	}
+
+	messageStats := make(chan []string, 5)
+	var messages []string
+	current := 0
+
+	go MessageStats(ctx, *gArgs.messageFile, 2*time.Second, messageStats)
+	for {
+		select {
+		case result, more := <-messageStats:
+			if !more {
+				showSourceError(ctx, area, "No messages", results)
+				return
+			}
+			messages = result
+			if current >= len(messages) {
+				current = 0
+			}
+		case <-time.After(*gArgs.messageRotation):
+			if len(messages) > 0 {
+				current = (current + 1) % len(messages)
+			}
+		}
+
+		if len(messages) < 1 {
+			results <- []string{"", "", ""}
+			continue
+		}
+
+		results <- WrapText(messages[current], area.Width, area.Height)
+	}
+}
+
+// Draw a breakdown of the memory usage as bar graphs.
+// The bars are the memory used by programs, the memory used by the cache and buffers, and the swap usage, followed by
+// the used and total memory in gigabytes.
+func (*Memory) Draw(ctx context.Context, area Area, results chan []string) {
+	defer close(results)
+
+	memoryStats := make(chan MemoryResult, 5)
+	columns := []string{"Used", "Cach", "Swap"}
+
+	go MemoryStats(ctx, 1*time.Second, memoryStats)
+	for {
+		select {
+		case result, more := <-memoryStats:
+			if !more {
+				showSourceError(ctx, area, "No memory status", results)
+				return
+			}
+
+			values := []float64{
+				result.Used / result.Total,
+				result.Cached / result.Total,
+				result.SwapUsed / result.SwapTotal,
+			}
+
+			output := make([]string, len(values), len(values)+1)
+			for i, value := range values {
+				value := clampFraction(value)
+				barLen := int(area.Width) - len(columns[i]) - 2
+				// Draw the label and a nice bar.
+				output[i] = fmt.Sprintf("%s%s",
+					columns[i],
+					drawBar(value, barLen))
+			}
+			output = append(output, CenterText(fmt.Sprintf("%.1f/%.1f GB used", result.Used/1e9, result.Total/1e9), area.Width))
+			results <- output
+		}
+	}
+}
+
+// Draw the number of logged in users, and who logged in most recently.
+func (*Sessions) Draw(ctx context.Context, area Area, results chan []string) {
+	defer close(results)
+
+	sessionsStats := make(chan SessionsResult, 5)
+
+	go SessionsStats(ctx, 10*time.Second, sessionsStats)
+	for {
+		select {
+		case result, more := <-sessionsStats:
+			if !more {
+				showSourceError(ctx, area, "No sessions", results)
+				return
+			}
+
+			if result.Count == 0 {
+				results <- []string{"", CenterText("Nobody logged in", area.Width)}
+				continue
+			}
+
+			users := "1 user logged in"
+			if result.Count != 1 {
+				users = fmt.Sprintf("%d users logged in", result.Count)
+			}
+			login := ""
+			if !result.LastLogin.IsZero() {
+				login = loginTime(time.Now(), result.LastLogin)
+			}
+			results <- []string{
+				CenterText(users, area.Width),
+				"",
+				CenterText("Last login", area.Width),
+				CenterText(strings.TrimSpace(ToLatin(result.LastUser)+" "+login), area.Width),
+			}
+		}
+	}
+}
+
+// Format the time of a login compactly, leaving out the date if it was today.
+func loginTime(now time.Time, login time.Time) string {
+	login = login.Local()
+	if year, month, day := now.Local().Date(); login.Year() == year && login.Month() == month && login.Day() == day {
+		return login.Format("15:04")
+	}
+	return login.Format("Jan 2 15:04")
+}
+
+// How long to show the title of the feed between the headlines.
+const NEWS_SOURCE_PAUSE = 2 * time.Second
+
+// Draw the headlines of an RSS or Atom feed, one at a time, with the title of the feed shown briefly between them.
+// Headlines that fit on the screen are wrapped, and longer ones scroll on a single line. If fetching the feed fails,
+// the previous headlines are kept.
+func (*News) Draw(ctx context.Context, area Area, results chan []string) {
+	defer close(results)
+
+	if *gArgs.rssURL == "" {
+		results <- []string{"", CenterText("No feed URL", area.Width)}
+		<-ctx.Done()
+		return
+	}
+
+	newsStats := make(chan NewsResult, 5)
+	var news NewsResult
+	current := 0
+	showSource := false
+	var headline []string    // The wrapped headline, if it fits.
+	var scroll func() string // The scrolling headline, if it doesn't fit.
+	var next <-chan time.Time
+
+	// Show the next headline, or the title of the feed before it.
+	show := func() {
+		if showSource && news.Source != "" {
+			results <- splashLines(area, news.Source)
+			next = time.After(NEWS_SOURCE_PAUSE)
+			return
+		}
+
+		showSource = false
+		headline, scroll = WrapText(news.Headlines[current], area.Width, 0), nil
+		if len(headline) > int(area.Height) {
+			headline, scroll = nil, ScrollText(news.Headlines[current], area.Width)
+			results <- []string{"", scroll()}
+		} else {
+			results <- headline
+		}
+		next = time.After(*gArgs.rssRotation)
+	}
+
+	// Steps the scrolling headline.
+	scrollTicker := time.NewTicker(1 * time.Second)
+	defer scrollTicker.Stop()
+
+	results <- []string{"", CenterText("Fetching news...", area.Width)}
+
+	go NewsStats(ctx, *gArgs.rssURL, newsStats)
+	for {
+		select {
+		case result, more := <-newsStats:
+			if !more {
+				showSourceError(ctx, area, "No news", results)
+				return
+			}
+			if len(result.Headlines) < 1 {
+				if len(news.Headlines) < 1 {
+					results <- []string{"", CenterText("No headlines", area.Width)}
+				}
+				continue
+			}
+
+			first := len(news.Headlines) < 1
+			news = result
+			if current >= len(news.Headlines) {
+				current = 0
+			}
+			if first {
+				showSource = true
+				show()
+			}
+		case <-next:
+			if !showSource {
+				current = (current + 1) % len(news.Headlines)
+			}
+			showSource = !showSource
+			show()
+		case <-scrollTicker.C:
+			if !showSource && scroll != nil {
+				results <- []string{"", scroll()}
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Get the first of the alternatives that fits within the width. If none of them does, the last one is cut to the width,
+// without splitting any icons.
+func fitText(width uint8, alternatives ...string) string {
+	for _, text := range alternatives {
+		if len(text) <= int(width) {
+			return text
+		}
+	}
+
+	fitted := ""
+	for _, unit := range splitIcons(alternatives[len(alternatives)-1]) {
+		if len(fitted)+len(unit) > int(width) {
+			break
+		}
+		fitted += unit
+	}
+	return fitted
+}
+
+// Draw an overview of several metrics: the time, the CPU and memory usage, the temperature and usage of the graphics
+// card, and the number of unread emails (if GMail or IMAP has been set up). The lines are shortened to fit narrow
+// screens. If a data source fails, its line shows an error instead.
+func (*Dashboard) Draw(ctx context.Context, area Area, results chan []string) {
+	defer close(results)
+
+	sysStat := make(chan SystemStatsResult, 5)
+	gpuStats := make(chan GraphicCardResult, 5)
+	unreadMails := make(chan int64, 5)
+	lines := []string{"", "", "", ""}
+
+	stopping := ctx.Done() // Set to nil once the tag has been stopped.
+	running := 2
+	go SystemStats(ctx, *gArgs.sysStatInterval, sysStat)
+	go GraphicCardStats(ctx, *gArgs.gpuInterval, *gArgs.temperatureUnit, gpuStats)
+	if StartMailStats(ctx, unreadMails) {
+		running++
+	} else {
+		unreadMails = nil
+	}
+
+	// Show an error in place of a line whose data source has failed.
+	sourceFailed := func(line int, message string) {
+		running--
+		lines[line] = ""
+		if ctx.Err() == nil {
+			lines[line] = fitText(area.Width, ERROR_ICON+" "+message, ERROR_ICON)
+		}
+	}
+
+	for {
+		now := time.Now().Local()
+		lines[0] = fitText(area.Width, now.Format("Mon Jan _2 15:04:05"), now.Format("Mon 15:04:05"),
+			now.Format("15:04:05"), now.Format("15:04"))
+		results <- append([]string(nil), lines...)
+
+		select {
+		case result, more := <-sysStat:
+			if !more {
+				sysStat = nil
+				sourceFailed(1, "No system status")
+				break
+			}
+
+			// Split the width between a CPU and a memory bar, with shorter labels on narrow screens.
+			labels := []string{"CPU", "Mem"}
+			if area.Width < 16 {
+				labels = []string{"C", "M"}
+			}
+			barLen := int(math.Max(float64(int(area.Width)/2-len(labels[0])-2), 0))
+			lines[1] = ""
+			for i, label := range labels {
+				lines[1] += fmt.Sprintf("%s%s",
+					label,
+					drawBar(result.Values[i], barLen))
+			}
+		case result, more := <-gpuStats:
+			if !more {
+				gpuStats = nil
+				sourceFailed(2, "No graphics card")
+				break
+			}
+
+			temp := fmt.Sprintf("%d%s%s", int(math.Round(result.Temperature)), DEGREES_ICON, *gArgs.temperatureUnit)
+			usage := int(math.Round(clampFraction(result.GPU) * 100))
+			lines[2] = fitText(area.Width, fmt.Sprintf("GPU %s %d%%", temp, usage), "GPU "+temp, temp)
+		case numUnread, more := <-unreadMails:
+			if !more {
+				unreadMails = nil
+				sourceFailed(3, "No mail status")
+				break
+			}
+
+			lines[3] = fitText(area.Width,
+				fmt.Sprintf("%s%d unread emails", MAIL_ICON, numUnread),
+				fmt.Sprintf("%s%d unread", MAIL_ICON, numUnread),
+				fmt.Sprintf("%s%d", MAIL_ICON, numUnread))
+		case <-time.After(1 * time.Second):
+		case <-stopping:
+			stopping = nil
+		}
+
+		if stopping == nil && running < 1 {
+			return
+		}
+	}
+}
+
+// Draw the metric selected with -history-metric over time, as a graph with one column for each of the last samples,
+// below the current value.
+func (*History) Draw(ctx context.Context, area Area, results chan []string) {
+	defer close(results)
+
+	metric := strings.ToLower(*gArgs.historyMetric)
+	label := HISTORY_METRICS[metric]
+	historyStats := make(chan float64, 5)
+	samples := make([]float64, area.Width) // Ring buffer of the last samples.
+	next := 0
+	count := 0
+	height := uint8(math.Max(float64(area.Height)-1, 1))
+
+	go HistoryStats(ctx, metric, historyStats)
+	for {
+		select {
+		case value, more := <-historyStats:
+			if !more {
+				showSourceError(ctx, area, "No history", results)
+				return
+			}
+
+			value = clampFraction(value)
+			samples[next] = value
+			next = (next + 1) % len(samples)
+			if count < len(samples) {
+				count++
+			}
+
+			// Draw the graph from the oldest sample to the newest, one column at a time.
+			graph := make([]string, height)
+			for i := range graph {
+				graph[i] = strings.Repeat(" ", len(samples)-count)
+			}
+			for i := len(samples) - count; i < len(samples); i++ {
+				for row, char := range drawVerticalBar(samples[(next+i)%len(samples)], height) {
+					graph[row] += char
+				}
+			}
+
+			title := CenterText(fmt.Sprintf("%s %d%%", label, int(math.Round(value*100))), area.Width)
+			results <- append([]string{title}, graph...)
+		}
+	}
+}
+
+// Get a line showing a power draw, with a bar that is full at the power limit if it's known.
+func powerLine(area Area, label string, power PowerResult, available bool) string {
+	if !available {
+		return label + " n/a"
+	}
+
+	watts := fmt.Sprintf("%3dW", int(math.Round(power.Power)))
+	barLen := int(area.Width) - len(label) - len(watts) - 2
+	if power.Limit <= 0 || barLen < 1 {
+		return label + " " + strings.TrimSpace(watts)
+	}
+	return fmt.Sprintf("%s%s%s",
+		label,
+		drawBar(power.Power/power.Limit, barLen),
+		watts)
+}
+
+// Draw the power draw of the CPU package (Linux only) and the graphics card, with bars that are full at their power
+// limits, and the total power draw. Sources that aren't available are marked as such.
+func (*Power) Draw(ctx context.Context, area Area, results chan []string) {
+	defer close(results)
+
+	cpuPower := make(chan PowerResult, 5)
+	gpuStats := make(chan GraphicCardResult, 5)
+	var cpu, gpu PowerResult
+	var cpuAvailable, gpuAvailable bool
+
+	go CPUPowerStats(ctx, 1*time.Second, cpuPower)
+	go GraphicCardStats(ctx, *gArgs.gpuInterval, *gArgs.temperatureUnit, gpuStats)
+	for cpuPower != nil || gpuStats != nil {
+		select {
+		case result, more := <-cpuPower:
+			if !more {
+				cpuPower, cpuAvailable = nil, false
+				break
+			}
+			cpu, cpuAvailable = result, true
+		case result, more := <-gpuStats:
+			if !more {
+				gpuStats, gpuAvailable = nil, false
+				break
+			}
+			// Graphics cards that don't report the power draw are treated as unavailable.
+			gpu, gpuAvailable = PowerResult{Power: result.Power, Limit: result.PowerLimit}, result.Power > 0
+		}
+
+		if ctx.Err() != nil {
+			continue
+		}
+
+		total := 0.0
+		if cpuAvailable {
+			total += cpu.Power
+		}
+		if gpuAvailable {
+			total += gpu.Power
+		}
+		results <- []string{
+			CenterText("Power draw", area.Width),
+			powerLine(area, "CPU", cpu, cpuAvailable),
+			powerLine(area, "GPU", gpu, gpuAvailable),
+			CenterText(fmt.Sprintf("Total %dW", int(math.Round(total))), area.Width),
+		}
+	}
+
+	showSourceError(ctx, area, "No power draw", results)
+}
+
+// Draw the IP address of the primary network interface, and the public IP address if enabled.
+func (*IPInfo) Draw(ctx context.Context, area Area, results chan []string) {
+	defer close(results)
+
+	ipInfo := make(chan IPInfoResult, 5)
+	go IPInfoStats(ctx, *gArgs.showPublicIP, 10*time.Second, ipInfo)
+	for {
+		select {
+		case result, more := <-ipInfo:
+			if !more {
+				showSourceError(ctx, area, "No IP address", results)
+				return
+			}
+
+			if result.LocalIP == "" {
+				results <- []string{CenterText("IP address", area.Width), "", CenterText("offline", area.Width)}
+				continue
+			}
+			output := []string{CenterText("IP address", area.Width), "", "LAN " + result.LocalIP}
+			if *gArgs.showPublicIP {
+				public := ToLatin(result.PublicIP)
+				if public == "" {
+					public = "unknown"
+				}
+				output = append(output, "WAN "+public)
+			}
+			results <- output
+		}
+	}
+}
+
+// Draw the title of the focused window, scrolling if it doesn't fit.
+func (*ActiveWindow) Draw(ctx context.Context, area Area, results chan []string) {
+	defer close(results)
+
+	windows := make(chan string, 5)
+	title := ""
+	scroll := ScrollText(title, area.Width)
+
+	go ActiveWindowStats(ctx, 1*time.Second, windows)
+	for {
+		select {
+		case result, more := <-windows:
+			if !more {
+				showSourceError(ctx, area, "No active window", results)
+				return
+			}
+
+			if result = ToLatin(result); result != title {
+				title = result
+				scroll = ScrollText(title, area.Width)
+			}
+			if title == "" {
+				results <- []string{CenterText("Active window", area.Width), "", CenterText("None", area.Width)}
+				continue
+			}
+			results <- []string{CenterText("Active window", area.Width), "", scroll()}
+		}
+	}
+}
+
+// Format the time left of a countdown as minutes and seconds, with hours if needed. Parts of a second are rounded up,
+// so that zero is only shown when the countdown has finished.
+func formatCountdown(left time.Duration) string {
+	seconds := int((left + time.Second - 1) / time.Second)
+	if seconds >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+	}
+	return fmt.Sprintf("%02d:%02d", seconds/60, seconds%60)
+}
+
+// Draw the countdown timer.
+// The first line is the state of the timer, the second the time left, and the third a bar showing the progress. The
+// screen flashes for a while when the countdown finishes.
+func (*Timer) Draw(ctx context.Context, area Area, results chan []string) {
+	defer close(results)
+
+	shown := ""
+	for {
+		state := gTimer.State()
+		var lines []string
+		if !state.Finished.IsZero() && time.Since(state.Finished) < TIMER_FLASH_DURATION &&
+			time.Since(state.Finished)/TIMER_FLASH_INTERVAL%2 == 0 {
+			// Fill the screen, which looks like inverting it.
+			lines = make([]string, area.Height)
+			for i := range lines {
+				lines[i] = strings.Repeat(BAR_CHAR, int(area.Width))
+			}
+		} else {
+			status := "Paused"
+			if state.Running {
+				status = "Running"
+			} else if !state.Finished.IsZero() {
+				status = "Done!"
+			} else if state.Remaining == state.Length {
+				status = "Timer"
+			}
+
+			barLen := int(area.Width) - 2
+			progress := 1 - clampFraction(float64(state.Remaining)/float64(state.Length))
+			lines = []string{
+				CenterText(status, area.Width),
+				CenterText(formatCountdown(state.Remaining), area.Width),
+				drawBar(progress, barLen),
+			}
+		}
+
+		// Only draw when something has changed, since the timer is checked often to react quickly to the keyboard.
+		if joined := strings.Join(lines, "\n"); joined != shown {
+			results <- lines
+			shown = joined
+		}
+
+		select {
+		case <-time.After(TIMER_REFRESH_INTERVAL):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Draw the SMART health and temperature of the drives, one per line.
+func (*DiskHealth) Draw(ctx context.Context, area Area, results chan []string) {
+	defer close(results)
+
+	var names []string
+	for _, name := range strings.Split(*gArgs.diskHealthDevices, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+
+	diskStats := make(chan []DiskHealthResult, 5)
+	go DiskHealthStats(ctx, names, 30*time.Second, diskStats)
+	for {
+		select {
+		case disks, more := <-diskStats:
+			if !more {
+				showSourceError(ctx, area, "No drives", results)
+				return
+			}
+
+			output := make([]string, 0, len(disks))
+			for _, disk := range disks {
+				if len(output) >= int(area.Height) {
+					break
+				}
+
+				status := "?"
+				switch disk.Health {
+				case HealthPassed:
+					status = "PASS"
+				case HealthFailed:
+					status = ALERT_ICON + "FAIL"
+				}
+				temp := "-"
+				if !math.IsNaN(disk.Temperature) {
+					temp = fmt.Sprintf("%d%s%s",
+						int(math.Round(ConvertTemperature(disk.Temperature, *gArgs.temperatureUnit))),
+						DEGREES_ICON,
+						*gArgs.temperatureUnit)
+				}
+
+				right := fmt.Sprintf("%s %5s", status, temp)
+				name := ToLatin(disk.Name)
+				if maxLen := int(area.Width) - len(right) - 1; len(name) > maxLen && maxLen >= 0 {
+					name = name[:maxLen]
+				}
+				output = append(output, name+RightAlignText(right, area.Width-uint8(len(name))))
+			}
+			results <- output
+		}
+	}
+}
+
+// Draw the state and capacity of the ZFS pools and RAID arrays.
+// Each pool takes two lines: the name and state, with pools needing attention marked, and a bar showing how full it is.
+func (*Pool) Draw(ctx context.Context, area Area, results chan []string) {
+	defer close(results)
+
+	poolStats := make(chan []PoolResult, 5)
+	go PoolStats(ctx, 30*time.Second, poolStats)
+	for {
+		select {
+		case pools, more := <-poolStats:
+			if !more {
+				showSourceError(ctx, area, "No pools", results)
+				return
+			}
+
+			output := make([]string, 0, 2*len(pools))
+			for _, pool := range pools {
+				if len(output)+2 > int(area.Height) {
+					break
+				}
+
+				health := ToLatin(pool.Health)
+				if pool.Degraded() {
+					health = ALERT_ICON + health
+				}
+				name := ToLatin(pool.Name)
+				if maxLen := int(area.Width) - len(health) - 1; len(name) > maxLen && maxLen >= 0 {
+					name = name[:maxLen]
+				}
+				output = append(output, name+RightAlignText(health, area.Width-uint8(len(name))))
+
+				if math.IsNaN(pool.Used) {
+					output = append(output, "")
+					continue
+				}
+				barLen := int(area.Width) - 2 - 5
+				output = append(output, fmt.Sprintf("%s %3d%%",
+					drawBar(pool.Used, barLen),
+					int(math.Round(pool.Used*100))))
+			}
+			results <- output
+		}
+	}
+}
+
+// Draw the typing statistics reported by the keyboard.
+// The first line is the current typing speed with a bar, the second the highest speed of the session, and the third
+// the number of keys pressed, if the firmware counts them.
+func (*TypingStats) Draw(ctx context.Context, area Area, results chan []string) {
+	defer close(results)
+
+	shown := ""
+	for {
+		state := gTyping.State()
+		var lines []string
+		if state.Updated.IsZero() {
+			lines = []string{CenterText("Typing", area.Width), "", CenterText("No WPM from keyboard", area.Width)}
+		} else {
+			wpm := strconv.Itoa(int(state.WPM))
+			barLen := int(area.Width) - 4 - 2 - 1 - len(wpm)
+			lines = []string{
+				fmt.Sprintf("WPM %s %s",
+					drawBar(float64(state.WPM)/TYPING_MAX_WPM, barLen),
+					wpm),
+				fmt.Sprintf("Peak %d WPM", state.PeakWPM),
+			}
+			if state.Keypresses > 0 {
+				lines = append(lines, fmt.Sprintf("Keys %d", state.Keypresses))
+			}
+		}
+
+		if joined := strings.Join(lines, "\n"); joined != shown {
+			results <- lines
+			shown = joined
+		}
+
+		select {
+		case <-time.After(TYPING_REFRESH_INTERVAL):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Draw a horizontal bar with room for the specified number of characters, filled according to the value (0-1). The
+// bar is drawn with the characters given by -bar-char, and enclosed by -bar-left and -bar-right.
+func drawBar(value float64, length int) string {
+	filled := int(math.Round(float64(length) * clampFraction(value)))
+	return fmt.Sprintf("%s%-*s%s",
+		glyphOr(*gArgs.barLeft, "["),
+		length,
+		strings.Repeat(glyphOr(*gArgs.barChar, BAR_CHAR), filled),
+		glyphOr(*gArgs.barRight, "]"))
+}
+
+// Exponential moving average of the values of a set of bars, making them move less abruptly. How much of the previous
+// value is kept is given by -smoothing, where 0 disables it.
+type barSmoothing struct {
+	previous []float64 // The previous smoothed values.
+}
+
+// Smooth the values against the previous ones. Starts over if the number of values changes, and values that aren't
+// numbers are passed through as is.
+func (smoothing *barSmoothing) Smooth(values []float64) []float64 {
+	factor := *gArgs.smoothing
+	if factor <= 0 || len(values) != len(smoothing.previous) {
+		smoothing.previous = append([]float64(nil), values...)
+		return values
+	}
+
+	for i, value := range values {
+		if !math.IsNaN(smoothing.previous[i]) && !math.IsNaN(value) {
+			smoothing.previous[i] = factor*smoothing.previous[i] + (1-factor)*value
+		} else {
+			smoothing.previous[i] = value
+		}
+	}
+	return append([]float64(nil), smoothing.previous...)
+}
+
+// Format a wind speed given in meters per second, in miles per hour if the temperature is shown in Fahrenheit.
+func formatWindSpeed(metersPerSecond float64) string {
+	if *gArgs.temperatureUnit == "F" {
+		return fmt.Sprintf("%.0f mph", metersPerSecond*2.23694)
+	}
+	return fmt.Sprintf("%.1f m/s", metersPerSecond)
+}
+
+// Draw the current weather in detail.
+// The first line is the condition and temperature, the second the humidity, the third the wind speed, and the fourth
+// the location.
+func (*Weather) Draw(ctx context.Context, area Area, results chan []string) {
+	defer close(results)
+
+	weatherReport := make(chan WeatherResult, 5)
+	if !StartWeatherStats(ctx, weatherReport) {
+		logError("The weather hasn't been configured.")
+		showSourceError(ctx, area, "No weather", results)
+		return
+	}
+
+	// The name of the location is optional for Open-Meteo, so show the position if it's missing.
+	location := ToLatin(strings.Split(*gArgs.weatherLocation, ",")[0])
+	if location == "" {
+		location = fmt.Sprintf("%.2f,%.2f", *gArgs.weatherLatitude, *gArgs.weatherLongitude)
+	}
+
+	for {
+		select {
+		case weather, more := <-weatherReport:
+			if !more {
+				showSourceError(ctx, area, "No weather", results)
+				return
+			}
+
+			results <- []string{
+				fmt.Sprintf("%s%d%s%s %s",
+					WEATHER_ICONS[weather.Weather],
+					int(math.Round(weather.Temperature)),
+					DEGREES_ICON,
+					*gArgs.temperatureUnit,
+					WEATHER_NAMES[weather.Weather]),
+				fmt.Sprintf("Humidity %d%%", weather.Humidity),
+				"Wind " + formatWindSpeed(weather.WindSpeed),
+				CenterText(location, area.Width),
+			}
+		}
+	}
+}
+
+// Draw the phase of the moon, and the times of sunrise and sunset.
+// The first line is the phase, the second how much of the moon is lit and its age in days, and the last two the times
+// of sunrise and sunset today. The times of the sun are calculated for the position given by -weather-lat and
+// -weather-lon, and left out if it's not set.
+func (*Astro) Draw(ctx context.Context, area Area, results chan []string) {
+	defer close(results)
+
+	// There is no way of telling an unset position from 0,0, like for the weather.
+	hasPosition := *gArgs.weatherLatitude != 0 || *gArgs.weatherLongitude != 0
+	if !hasPosition {
+		logInfo("No position set with -weather-lat and -weather-lon, so sunrise and sunset aren't shown.")
+	}
+
+	var shown string
+	for {
+		now := time.Now()
+		phase := MoonPhase(now)
+		lines := []string{
+			CenterText(WEATHER_ICONS[ClearNight]+" "+MoonPhaseName(phase), area.Width),
+			CenterText(fmt.Sprintf("%d%% lit, day %d",
+				int(math.Round(MoonIllumination(phase)*100)),
+				int(phase*SYNODIC_MONTH)), area.Width),
+		}
+
+		if hasPosition {
+			sunrise, sunset, found, up := SunTimes(now, *gArgs.weatherLatitude, *gArgs.weatherLongitude)
+			if found {
+				lines = append(lines,
+					CenterText("Sunrise "+sunrise.Format("15:04"), area.Width),
+					CenterText("Sunset  "+sunset.Format("15:04"), area.Width))
+			} else if up {
+				lines = append(lines, CenterText("Sun up all day", area.Width))
+			} else {
+				lines = append(lines, CenterText("Sun down all day", area.Width))
+			}
+		}
+
+		if joined := strings.Join(lines, "\n"); joined != shown {
+			shown = joined
+			results <- lines
+		}
+
+		select {
+		case <-time.After(ASTRO_REFRESH_INTERVAL):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,317 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+// Tests of the selection of tags, and of the lines they draw.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Get a set of tags with the specified indices.
+func tagSet(ids ...uint8) map[uint8]Tag {
+	set := make(map[uint8]Tag, len(ids))
+	for _, id := range ids {
+		set[id] = &fakeTag{}
+	}
+	return set
+}
+
+// Get the sorted indices of the available tags.
+func tagIDs() string {
+	return fmt.Sprint(sortedTagIDs())
+}
+
+func TestEnableTags(t *testing.T) {
+	useTags(t, allTags)
+	all := tagIDs()
+
+	tests := []struct {
+		list string
+		want string
+	}{
+		{"1,clock", "[1 5]"},
+		{" gpu , 3 ", "[3]"}, // Not among the tags enabled before.
+		{"", all},
+		{"1, nonexistent", "[1]"},
+		{"nonexistent", all},
+		{"GPU,Weather", "[3 28]"},
+		{",", all},
+	}
+
+	// The tags enabled by each list are independent of the ones before, like when the configuration is reloaded.
+	for _, test := range tests {
+		enableTags(test.list)
+		if got := tagIDs(); got != test.want {
+			t.Errorf("enableTags(%q) enabled the tags %s, want %s", test.list, got, test.want)
+		}
+	}
+}
+
+// Answers the HTTP requests instead of sending them.
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Answer all HTTP requests with the specified handler during the test, instead of sending them.
+func useHTTP(t *testing.T, handler http.HandlerFunc) {
+	real := http.DefaultTransport
+	http.DefaultTransport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		recorder := httptest.NewRecorder()
+		handler(recorder, req)
+		return recorder.Result(), nil
+	})
+	t.Cleanup(func() { http.DefaultTransport = real })
+}
+
+func TestCycleTag(t *testing.T) {
+	tests := []struct {
+		name    string
+		ids     []uint8
+		current uint8
+		forward bool
+		want    uint8
+		found   bool
+	}{
+		{"forward", []uint8{1, 2, 3}, 1, true, 2, true},
+		{"back", []uint8{1, 2, 3}, 2, false, 1, true},
+		{"forward wraps around", []uint8{1, 2, 3}, 3, true, 1, true},
+		{"back wraps around", []uint8{1, 2, 3}, 1, false, 3, true},
+		{"forward over a gap", []uint8{2, 5, 9}, 2, true, 5, true},
+		{"back over a gap", []uint8{2, 5, 9}, 9, false, 5, true},
+		{"forward wraps around with gaps", []uint8{2, 5, 9}, 9, true, 2, true},
+		{"back wraps around with gaps", []uint8{2, 5, 9}, 2, false, 9, true},
+		{"forward from a missing tag", []uint8{2, 5, 9}, 6, true, 9, true},
+		{"back from a missing tag", []uint8{2, 5, 9}, 6, false, 5, true},
+		{"forward from below the lowest tag", []uint8{2, 5, 9}, 0, true, 2, true},
+		{"back from below the lowest tag", []uint8{2, 5, 9}, 1, false, 9, true},
+		{"forward from above the highest tag", []uint8{2, 5, 9}, 200, true, 2, true},
+		{"back from above the highest tag", []uint8{2, 5, 9}, 200, false, 9, true},
+		{"single tag forward", []uint8{4}, 4, true, 4, true},
+		{"single tag back", []uint8{4}, 4, false, 4, true},
+		{"no tags forward", nil, 1, true, 0, false},
+		{"no tags back", nil, 1, false, 0, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			useTags(t, tagSet(test.ids...))
+			got, found := cycleTag(test.current, test.forward)
+			if got != test.want || found != test.found {
+				t.Errorf("cycleTag(%d, %v) with tags %v = %d, %v, want %d, %v",
+					test.current, test.forward, test.ids, got, found, test.want, test.found)
+			}
+		})
+	}
+}
+
+func TestContainerLine(t *testing.T) {
+	area := Area{Width: 16, Height: 4}
+	tests := []struct {
+		name      string
+		container DockerContainer
+		want      string
+	}{
+		{"short name", DockerContainer{Name: "web", State: "running"}, "web      running"},
+		{"long name", DockerContainer{Name: "a-very-long-name", State: "exited"}, "a-very-lo exited"},
+		{"newline in the name", DockerContainer{Name: "we\nb", State: "up"}, "we b          up"},
+		{"control characters", DockerContainer{Name: "\x01\x02db\x1B", State: "up\x07\x00"}, "db            up"},
+		{"non-Latin characters", DockerContainer{Name: "café-日本", State: "créé"}, "cafe-       cree"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := containerLine(area, test.container); got != test.want {
+				t.Errorf("containerLine(%v, %q) = %q, want %q", area, test.container, got, test.want)
+			}
+		})
+	}
+}
+
+// Stopping the general information with both the mail and weather sources running used to close a channel twice.
+func TestGeneralInfoStop(t *testing.T) {
+	// An IMAP server that hangs up right away.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Failed to listen:", err)
+	}
+	defer listener.Close()
+	mailConnected := make(chan bool, 1)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+			select {
+			case mailConnected <- true:
+			default:
+			}
+		}
+	}()
+
+	weatherRequested := make(chan bool, 1)
+	useHTTP(t, func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(`{"current_weather":{"temperature":12.5,"weathercode":3,"is_day":1}}`))
+		select {
+		case weatherRequested <- true:
+		default:
+		}
+	})
+
+	setFlag(t, "imap-server", listener.Addr().String())
+	setFlag(t, "weather-provider", OPEN_METEO)
+	setFlag(t, "weather-lat", "59.3")
+	setFlag(t, "weather-lon", "18.1")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	results := make(chan []string, 5)
+	go (&GeneralInfo{}).Draw(ctx, Area{Width: 21, Height: 4}, results)
+
+	for _, source := range []struct {
+		name    string
+		started chan bool
+	}{{"mail", mailConnected}, {"weather", weatherRequested}} {
+		select {
+		case <-source.started:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("The %s source wasn't started.", source.name)
+		}
+	}
+
+	// Stop it more than once, which must not panic.
+	cancel()
+	cancel()
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case _, more := <-results:
+			if !more {
+				return
+			}
+		case <-timeout:
+			t.Fatal("The tag didn't stop in time.")
+		}
+	}
+}
+
+// Each disk used to get a row of its own, so that the ones that didn't fit on the screen were never shown.
+func TestSysStatsDisks(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Stand in for the system status, which is shared with the tag.
+	result := SystemStatsResult{
+		Labels: []string{"CPU%", "Mem%", "Swap", "sda", "sdb"},
+		Values: []float64{0.1, 0.2, 0.3, 1, 0.5},
+	}
+	key := cacheKey("sysstats", *gArgs.sysStatInterval, *gArgs.sysStatDisk, *gArgs.cpuPerCore)
+	subscribe(ctx, key, func(ctx context.Context, values chan interface{}) {
+		for {
+			select {
+			case values <- result:
+				time.Sleep(10 * time.Millisecond)
+			case <-ctx.Done():
+				return
+			}
+		}
+	})
+
+	results := make(chan []string, 5)
+	go (&SysStats{}).Draw(ctx, Area{Width: 21, Height: 4}, results)
+	var got []string
+	select {
+	case got = <-results:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Nothing was drawn.")
+	}
+	cancel()
+	for range results {
+	}
+
+	if len(got) != 4 {
+		t.Fatalf("Drew %d rows on a screen of 4, want 4: %q", len(got), got)
+	}
+	if want := "sda" + drawBar(1, 5) + " sdb" + drawBar(0.5, 5); got[3] != want {
+		t.Errorf("Drew the disks as %q, want %q", got[3], want)
+	}
+}
+
+// A stalled request for the prices used to keep the tag from stopping.
+func TestTickerStop(t *testing.T) {
+	requested := make(chan bool, 1)
+	useHTTP(t, func(w http.ResponseWriter, req *http.Request) {
+		select {
+		case requested <- true:
+		default:
+		}
+		<-req.Context().Done()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	results := make(chan []string, 5)
+	go (&Ticker{}).Draw(ctx, Area{Width: 21, Height: 4}, results)
+
+	select {
+	case <-requested:
+	case <-time.After(5 * time.Second):
+		t.Fatal("The prices weren't requested.")
+	}
+	cancel()
+	waitTagStopped(t, results)
+}
+
+// A stalled request for the feed used to keep the tag from stopping.
+func TestNewsStop(t *testing.T) {
+	requested := make(chan bool, 1)
+	useHTTP(t, func(w http.ResponseWriter, req *http.Request) {
+		select {
+		case requested <- true:
+		default:
+		}
+		<-req.Context().Done()
+	})
+	setFlag(t, "rss-url", "http://news.invalid/feed")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	results := make(chan []string, 5)
+	go (&News{}).Draw(ctx, Area{Width: 21, Height: 4}, results)
+
+	select {
+	case <-requested:
+	case <-time.After(5 * time.Second):
+		t.Fatal("The feed wasn't requested.")
+	}
+	cancel()
+	waitTagStopped(t, results)
+}
+
+// Wait for a tag to stop drawing, failing the test if it doesn't.
+func waitTagStopped(t *testing.T, results chan []string) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case _, more := <-results:
+			if !more {
+				return
+			}
+		case <-timeout:
+			t.Fatal("The tag didn't stop in time.")
+		}
+	}
+}
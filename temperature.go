@@ -0,0 +1,39 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+// Handling of the different temperature units.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Parse a temperature unit, allowing any case and the full names of the units.
+// Returns the unit as a single upper case letter ("C", "F", or "K").
+func ParseTemperatureUnit(unit string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(unit)) {
+	case "c", "celsius", "centigrade":
+		return "C", nil
+	case "f", "fahrenheit":
+		return "F", nil
+	case "k", "kelvin":
+		return "K", nil
+	default:
+		return "", fmt.Errorf("invalid temperature unit '%s' (expected C, F, or K)", unit)
+	}
+}
+
+// Convert a temperature in degrees Celsius to the specified unit ("C", "F", or "K").
+// All temperature sources report in Celsius, and are converted with this function before being shown.
+func ConvertTemperature(celsius float64, unit string) float64 {
+	switch unit {
+	case "F":
+		return celsius*9/5 + 32
+	case "K":
+		return celsius + 273.15
+	default:
+		return celsius
+	}
+}
@@ -0,0 +1,214 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+// Helpers for laying out text on the screens. Note that the screens show one character per byte, and that icons can
+// span several characters.
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// How many ticks scrolling text pauses at each end.
+const SCROLL_PAUSE = 3
+
+// The text to end wrapped text with when it doesn't fit.
+const ELLIPSIS = "..."
+
+// The firmware only supports Latin characters, without diacritics. These need to be either normalized, or removed
+// completely before drawn to the display, otherwise it just won't look right.
+// Control characters are removed as well, since the font shows icons in their place, except for whitespace (such as
+// tabs and newlines), which is replaced by spaces.
+func ToLatin(text string) string {
+	isNonLatin := func(r rune) bool { return r >= 0x80 }
+	t := transform.Chain(norm.NFD, transform.RemoveFunc(isNonLatin), norm.NFC)
+	latin, _, _ := transform.String(t, text)
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\t' || r == '\n' || r == '\v' || r == '\f' || r == '\r':
+			return ' '
+		case r < 0x20 || r == 0x7F:
+			return -1
+		}
+		return r
+	}, latin)
+}
+
+// Make text safe to send to the firmware. The icons use most of the control characters, so those are kept, but a NUL
+// byte would end the text early, and is replaced by a space. Text from external sources should already have been
+// cleaned up by ToLatin.
+func sanitizeForDisplay(text string) string {
+	return strings.ReplaceAll(text, "\x00", " ")
+}
+
+// Split text into units that can't be split further, which are either a single character or a whole icon.
+func splitIcons(text string) []string {
+	icons := []string{MAIL_ICON, FAN_ICON_1, FAN_ICON_2}
+	for _, icon := range WEATHER_ICONS {
+		icons = append(icons, icon)
+	}
+
+	units := make([]string, 0, len(text))
+	for i := 0; i < len(text); {
+		unit := text[i : i+1]
+		for _, icon := range icons {
+			if len(icon) > 1 && strings.HasPrefix(text[i:], icon) {
+				unit = icon
+				break
+			}
+		}
+		units = append(units, unit)
+		i += len(unit)
+	}
+	return units
+}
+
+// Center text within the specified width, by padding it with spaces on both sides. Text that doesn't fit is returned
+// unchanged.
+func CenterText(text string, width uint8) string {
+	padding := int(width) - len(text)
+	if padding <= 0 {
+		return text
+	}
+	return strings.Repeat(" ", padding/2) + text + strings.Repeat(" ", padding-padding/2)
+}
+
+// Right-align text within the specified width, by padding it with spaces on the left. Text that doesn't fit is returned
+// unchanged.
+func RightAlignText(text string, width uint8) string {
+	padding := int(width) - len(text)
+	if padding <= 0 {
+		return text
+	}
+	return strings.Repeat(" ", padding) + text
+}
+
+// Create a horizontally scrolling window of the specified width over the text. Each call to the returned function
+// gives the next step, pausing a bit at the start and the end. Text that fits within the width is returned unchanged.
+// Icons are never split; if an icon doesn't fit at the edge of the window, a space is shown instead.
+func ScrollText(text string, width uint8) func() string {
+	if len(text) <= int(width) {
+		return func() string { return text }
+	}
+
+	units := splitIcons(text)
+
+	// Find the first unit from which the rest of the text fits within the width.
+	last, length := len(units), 0
+	for last > 0 && length+len(units[last-1]) <= int(width) {
+		last--
+		length += len(units[last])
+	}
+
+	start, pause := 0, 0
+	return func() string {
+		var window strings.Builder
+		for _, unit := range units[start:] {
+			if window.Len()+len(unit) > int(width) {
+				break
+			}
+			window.WriteString(unit)
+		}
+		line := window.String() + strings.Repeat(" ", int(width)-window.Len())
+
+		if (start == 0 || start == last) && pause < SCROLL_PAUSE {
+			pause++
+		} else {
+			pause = 0
+			start++
+			if start > last {
+				start = 0
+			}
+		}
+		return line
+	}
+}
+
+// Wrap text into at most maxLines lines (unlimited if zero) of at most width characters, breaking between words where
+// possible. Words longer than the width are broken up, but icons are never split. If the text doesn't fit, the last line
+// ends with an ellipsis.
+// Note that only spaces separate words, since some of the icons consist of whitespace characters.
+func WrapText(text string, width uint8, maxLines uint8) []string {
+	if width < 1 {
+		return nil
+	}
+
+	var lines [][]string // The units of each line.
+	var line []string
+	length := 0
+	for _, word := range strings.Split(text, " ") {
+		if word == "" {
+			continue
+		}
+		units := splitIcons(word)
+
+		// Start a new line unless the word fits after a space on the current one.
+		if length > 0 && length+1+len(word) > int(width) {
+			lines = append(lines, line)
+			line, length = nil, 0
+		} else if length > 0 {
+			line = append(line, " ")
+			length++
+		}
+
+		for _, unit := range units {
+			if length+len(unit) > int(width) && length > 0 {
+				lines = append(lines, line)
+				line, length = nil, 0
+			}
+			line = append(line, unit)
+			length += len(unit)
+		}
+	}
+	if length > 0 {
+		lines = append(lines, line)
+	}
+
+	if maxLines > 0 && len(lines) > int(maxLines) {
+		lines = lines[:maxLines]
+
+		// Make room for the ellipsis on the last line.
+		last := lines[maxLines-1]
+		length = 0
+		for _, unit := range last {
+			length += len(unit)
+		}
+		for len(last) > 0 && (length+len(ELLIPSIS) > int(width) || last[len(last)-1] == " ") {
+			length -= len(last[len(last)-1])
+			last = last[:len(last)-1]
+		}
+		lines[maxLines-1] = append(last, ELLIPSIS)
+	}
+
+	output := make([]string, len(lines))
+	for i, units := range lines {
+		output[i] = strings.Join(units, "")
+	}
+	return output
+}
+
+// Parse a single character given by the user, either as is or escape-coded like in Go (e.g. "\x7F" for the bar
+// character of the font). Returns an error unless it's exactly one character on the display.
+func ParseGlyph(glyph string) (string, error) {
+	unquoted, err := strconv.Unquote(`"` + strings.ReplaceAll(glyph, `"`, `\"`) + `"`)
+	if err != nil {
+		return "", fmt.Errorf("invalid escape sequence in '%s'", glyph)
+	} else if len(unquoted) != 1 {
+		return "", fmt.Errorf("'%s' is not a single character", glyph)
+	}
+	return unquoted, nil
+}
+
+// Parse a single character like ParseGlyph, but use the fallback if it's invalid.
+func glyphOr(glyph string, fallback string) string {
+	if parsed, err := ParseGlyph(glyph); err == nil {
+		return parsed
+	}
+	return fallback
+}
@@ -0,0 +1,151 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+// Tests of the text formatting.
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWrapText(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		width    uint8
+		maxLines uint8
+		want     []string
+	}{
+		{"fits on one line", "a b", 5, 0, []string{"a b"}},
+		{"word of exactly the width", "hello world", 5, 0, []string{"hello", "world"}},
+		{"word of exactly the width after another", "ab hello", 5, 0, []string{"ab", "hello"}},
+		{"over-long word", "abcdefghij", 4, 0, []string{"abcd", "efgh", "ij"}},
+		{"over-long word after another", "ab cdefgh", 4, 0, []string{"ab", "cdef", "gh"}},
+		{"icon at the edge of the line", "abc" + MAIL_ICON, 4, 0, []string{"abc", MAIL_ICON}},
+		{"icon filling the line", "ab" + MAIL_ICON, 4, 0, []string{"ab" + MAIL_ICON}},
+		{"repeated spaces", "a   b", 5, 0, []string{"a b"}},
+		{"within the maximum lines", "one two", 3, 2, []string{"one", "two"}},
+		{"ellipsis after the last line", "one two three four", 9, 2, []string{"one two", "three..."}},
+		{"ellipsis replacing the end of the line", "abcdefgh ij", 8, 1, []string{"abcde..."}},
+		{"ellipsis without a space before it", "abc de fgh", 7, 1, []string{"abc..."}},
+		{"ellipsis replacing a whole icon", "ab" + MAIL_ICON + " cd", 4, 1, []string{"a..."}},
+		{"empty text", "", 5, 0, []string{}},
+		{"no width", "abc", 0, 0, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := WrapText(test.text, test.width, test.maxLines)
+			if len(got) != len(test.want) || strings.Join(got, "|") != strings.Join(test.want, "|") {
+				t.Errorf("WrapText(%q, %d, %d) = %q, want %q", test.text, test.width, test.maxLines, got, test.want)
+			}
+			for _, line := range got {
+				if len(line) > int(test.width) {
+					t.Errorf("WrapText(%q, %d, %d) gave the line %q, which is too long",
+						test.text, test.width, test.maxLines, line)
+				}
+			}
+		})
+	}
+}
+
+func TestCenterText(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		width uint8
+		want  string
+	}{
+		{"even padding", "ab", 6, "  ab  "},
+		{"odd padding", "ab", 5, " ab  "},
+		{"padding of one", "abcd", 5, "abcd "},
+		{"exact fit", "abcde", 5, "abcde"},
+		{"too long", "abcdef", 5, "abcdef"},
+		{"empty text", "", 3, "   "},
+		{"icon", MAIL_ICON, 6, "  " + MAIL_ICON + "  "},
+		{"icon and text with odd padding", MAIL_ICON + " 3", 9, "  " + MAIL_ICON + " 3   "},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := CenterText(test.text, test.width); got != test.want {
+				t.Errorf("CenterText(%q, %d) = %q, want %q", test.text, test.width, got, test.want)
+			}
+		})
+	}
+}
+
+func TestRightAlignText(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		width uint8
+		want  string
+	}{
+		{"even padding", "ab", 6, "    ab"},
+		{"odd padding", "ab", 5, "   ab"},
+		{"exact fit", "abcde", 5, "abcde"},
+		{"too long", "abcdef", 5, "abcdef"},
+		{"empty text", "", 3, "   "},
+		{"icon", FAN_ICON_1, 5, "   " + FAN_ICON_1},
+		{"icon and text", FAN_ICON_1 + "50%", 8, "   " + FAN_ICON_1 + "50%"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := RightAlignText(test.text, test.width); got != test.want {
+				t.Errorf("RightAlignText(%q, %d) = %q, want %q", test.text, test.width, got, test.want)
+			}
+		})
+	}
+}
+
+func TestToLatin(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"plain text", "Hello, world!", "Hello, world!"},
+		{"diacritics", "Café Åre", "Cafe Are"},
+		{"non-Latin characters", "Tokyo 東京", "Tokyo "},
+		{"newlines", "one\ntwo\r\nthree", "one two  three"},
+		{"tabs and feeds", "a\tb\vc\fd", "a b c d"},
+		{"control characters", "a\x01\x02b\x07c\x1Bd", "abcd"},
+		{"NUL byte", "a\x00b", "ab"},
+		{"DEL character", "bar\x7F", "bar"},
+		{"empty text", "", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := ToLatin(test.text); got != test.want {
+				t.Errorf("ToLatin(%q) = %q, want %q", test.text, got, test.want)
+			}
+		})
+	}
+}
+
+func TestSanitizeForDisplay(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"plain text", "abc", "abc"},
+		{"NUL bytes", "a\x00b\x00", "a b "},
+		{"icons", MAIL_ICON + "3 " + FAN_ICON_1 + MUSIC_ICON, MAIL_ICON + "3 " + FAN_ICON_1 + MUSIC_ICON},
+		{"icons using whitespace bytes", WEATHER_ICONS[Rain] + "12" + DEGREES_ICON, WEATHER_ICONS[Rain] + "12" + DEGREES_ICON},
+		{"bar characters", BAR_CHAR + VERTICAL_BAR_CHARS, BAR_CHAR + VERTICAL_BAR_CHARS},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := sanitizeForDisplay(test.text); got != test.want {
+				t.Errorf("sanitizeForDisplay(%q) = %q, want %q", test.text, got, test.want)
+			}
+		})
+	}
+}
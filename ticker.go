@@ -0,0 +1,166 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+// Get prices of cryptocurrencies or stocks. Cryptocurrencies are fetched from CoinGecko, where the symbols are the IDs of
+// the coins (e.g. "bitcoin"), and stocks from Yahoo Finance, where the symbols are the ticker symbols (e.g. "AAPL").
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Ticker constants.
+const (
+	TICKER_INTERVAL    = 1 * time.Minute  // How often to fetch the prices normally.
+	TICKER_MAX_BACKOFF = 10 * time.Minute // The maximum time to wait when being rate limited.
+)
+
+// How long to wait for a response to a request for JSON, so that a stalled request doesn't hold up stopping.
+const JSON_TIMEOUT = 30 * time.Second
+
+// The type of a ticker result
+type TickerResult struct {
+	Symbol string  // The symbol of the coin or stock.
+	Price  float64 // The current price, in US dollars.
+	Change float64 // The change over the last 24 hours (or since the previous close), in percent.
+}
+
+// An error for when the provider is rate limiting the requests.
+var errRateLimited = fmt.Errorf("rate limited")
+
+// The client fetching JSON.
+var jsonClient = &http.Client{Timeout: JSON_TIMEOUT}
+
+// Fetch JSON from an URL, giving up when the context is canceled.
+func getJSON(ctx context.Context, address string, result interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, address, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := jsonClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return errRateLimited
+	} else if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s (%d)", http.StatusText(resp.StatusCode), resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// Get the prices of coins from CoinGecko.
+func coinGeckoPrices(ctx context.Context, symbols []string) ([]TickerResult, error) {
+	var prices map[string]struct {
+		USD       float64 `json:"usd"`
+		USDChange float64 `json:"usd_24h_change"`
+	}
+	err := getJSON(ctx, "https://api.coingecko.com/api/v3/simple/price?vs_currencies=usd&include_24hr_change=true&ids="+
+		url.QueryEscape(strings.Join(symbols, ",")), &prices)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []TickerResult
+	for _, symbol := range symbols {
+		if price, found := prices[symbol]; found {
+			results = append(results, TickerResult{Symbol: symbol, Price: price.USD, Change: price.USDChange})
+		} else {
+			logWarnf("Unknown coin '%s'.\n", symbol)
+		}
+	}
+	return results, nil
+}
+
+// Get the prices of stocks from Yahoo Finance.
+func yahooPrices(ctx context.Context, symbols []string) ([]TickerResult, error) {
+	var results []TickerResult
+	for _, symbol := range symbols {
+		var chart struct {
+			Chart struct {
+				Result []struct {
+					Meta struct {
+						Price         float64 `json:"regularMarketPrice"`
+						PreviousClose float64 `json:"chartPreviousClose"`
+					} `json:"meta"`
+				} `json:"result"`
+			} `json:"chart"`
+		}
+		err := getJSON(ctx, "https://query1.finance.yahoo.com/v8/finance/chart/"+url.PathEscape(symbol)+"?range=1d", &chart)
+		if err != nil {
+			return nil, err
+		} else if len(chart.Chart.Result) < 1 {
+			logWarnf("Unknown stock '%s'.\n", symbol)
+			continue
+		}
+
+		meta := chart.Chart.Result[0].Meta
+		result := TickerResult{Symbol: symbol, Price: meta.Price}
+		if meta.PreviousClose != 0 {
+			result.Change = (meta.Price - meta.PreviousClose) / meta.PreviousClose * 100
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// Start a loop that gets the prices of the symbols from the specified provider ("coingecko" or "yahoo").
+func TickerStats(ctx context.Context, provider string, symbols []string, result chan []TickerResult) {
+	defer close(result)
+
+	key := cacheKey("ticker", provider, symbols)
+	if cached, found := cachedValue(key); found {
+		result <- cached.([]TickerResult)
+	}
+
+	var fetch func(context.Context, []string) ([]TickerResult, error)
+	switch strings.ToLower(provider) {
+	case "coingecko":
+		fetch = coinGeckoPrices
+	case "yahoo":
+		fetch = yahooPrices
+	default:
+		logErrorf("Unknown ticker provider '%s'.\n", provider)
+		return
+	}
+
+	interval := TICKER_INTERVAL
+	for {
+		prices, err := fetch(ctx, symbols)
+		if ctx.Err() != nil {
+			return
+		} else if err == errRateLimited {
+			// Back off until the provider accepts requests again.
+			interval *= 2
+			if interval > TICKER_MAX_BACKOFF {
+				interval = TICKER_MAX_BACKOFF
+			}
+			logWarnf("Rate limited by %s. Waiting %v.\n", provider, interval)
+		} else if err != nil {
+			logWarn("Failed to get prices:", err)
+		} else {
+			interval = TICKER_INTERVAL
+			cacheValue(key, prices)
+			select {
+			case result <- prices:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
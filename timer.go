@@ -0,0 +1,177 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+// A countdown timer (e.g. for the Pomodoro technique), controlled by events from the keyboard. The timer keeps running
+// while other tags are shown, and is shared between the screens.
+
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// The shortest length of the timer that can be set from the keyboard.
+const MIN_TIMER_LENGTH = 1 * time.Minute
+
+// The longest length of the timer that can be set from the keyboard.
+const MAX_TIMER_LENGTH = 24 * time.Hour
+
+// How often the timer tag checks the timer.
+const TIMER_REFRESH_INTERVAL = 200 * time.Millisecond
+
+// How long the timer tag flashes when the countdown finishes, and how long each flash is.
+const (
+	TIMER_FLASH_DURATION = 10 * time.Second
+	TIMER_FLASH_INTERVAL = 500 * time.Millisecond
+)
+
+// The state of the countdown timer.
+type TimerState struct {
+	Length    time.Duration // The length of a full countdown.
+	Remaining time.Duration // The time left of the countdown.
+	Running   bool          // Whether the timer is counting down.
+	Finished  time.Time     // When the countdown reached zero, or zero if it hasn't.
+}
+
+// The countdown timer. The zero value is a stopped timer with the length given by the -timer-length flag.
+type Countdown struct {
+	mutex   sync.Mutex
+	length  time.Duration // The length of a full countdown, or zero until the timer is first used.
+	left    time.Duration // The time left when the timer was last started or paused.
+	started time.Time     // When the timer was last started, or zero if it's stopped.
+}
+
+// The countdown timer controlled from the keyboard.
+var gTimer Countdown
+
+// Set up the length of the timer the first time it's used. Must be called with the mutex held.
+func (timer *Countdown) init() {
+	if timer.length == 0 {
+		timer.length = *gArgs.timerLength
+		if timer.length <= 0 {
+			timer.length = MIN_TIMER_LENGTH
+		}
+		timer.left = timer.length
+	}
+}
+
+// Get the time left at the specified time. Must be called with the mutex held.
+func (timer *Countdown) remaining(now time.Time) time.Duration {
+	left := timer.left
+	if !timer.started.IsZero() {
+		left -= now.Sub(timer.started)
+	}
+	if left < 0 {
+		left = 0
+	}
+	return left
+}
+
+// Get the current state of the timer.
+func (timer *Countdown) State() TimerState {
+	timer.mutex.Lock()
+	defer timer.mutex.Unlock()
+	timer.init()
+
+	now := time.Now()
+	state := TimerState{
+		Length:    timer.length,
+		Remaining: timer.remaining(now),
+		Running:   !timer.started.IsZero(),
+	}
+	if state.Running && state.Remaining == 0 {
+		state.Running = false
+		state.Finished = timer.started.Add(timer.left)
+	}
+	return state
+}
+
+// Start the timer if it's stopped, or pause it if it's running. A finished timer is started over.
+func (timer *Countdown) Toggle() {
+	timer.mutex.Lock()
+	defer timer.mutex.Unlock()
+	timer.init()
+
+	now := time.Now()
+	left := timer.remaining(now)
+	switch {
+	case left == 0:
+		timer.left = timer.length
+		timer.started = now
+	case timer.started.IsZero():
+		timer.started = now
+	default:
+		timer.left = left
+		timer.started = time.Time{}
+	}
+}
+
+// Stop the timer, and set it back to its full length.
+func (timer *Countdown) Reset() {
+	timer.mutex.Lock()
+	defer timer.mutex.Unlock()
+	timer.init()
+
+	timer.left = timer.length
+	timer.started = time.Time{}
+}
+
+// Change the length of the timer, and the time left of the current countdown, by the specified number of minutes.
+func (timer *Countdown) Adjust(minutes int) {
+	timer.mutex.Lock()
+	defer timer.mutex.Unlock()
+	timer.init()
+
+	change := time.Duration(minutes) * time.Minute
+	timer.length += change
+	if timer.length < MIN_TIMER_LENGTH {
+		timer.length = MIN_TIMER_LENGTH
+	} else if timer.length > MAX_TIMER_LENGTH {
+		timer.length = MAX_TIMER_LENGTH
+	}
+
+	now := time.Now()
+	left := timer.remaining(now)
+	if left == 0 {
+		return // Finished, so the change applies to the next countdown.
+	}
+
+	// Never let the change finish the countdown, nor make it longer than the full length.
+	lowest := left
+	if lowest > MIN_TIMER_LENGTH {
+		lowest = MIN_TIMER_LENGTH
+	}
+	left += change
+	if left < lowest {
+		left = lowest
+	} else if left > timer.length {
+		left = timer.length
+	}
+
+	timer.left = left
+	if !timer.started.IsZero() {
+		timer.started = now
+	}
+}
+
+// Control the timer according to an event from the keyboard.
+func (timer *Countdown) HandleEvent(event Event) {
+	switch event.Event {
+	case TimerToggle:
+		timer.Toggle()
+	case TimerReset:
+		timer.Reset()
+	case TimerAdjust:
+		if len(event.Params) < 1 {
+			logWarn("Missing minutes in timer event.")
+			return
+		}
+		timer.Adjust(int(int8(event.Params[0])))
+	}
+}
+
+// Get whether an event controls the timer.
+func isTimerEvent(id EventID) bool {
+	return id == TimerToggle || id == TimerReset || id == TimerAdjust
+}
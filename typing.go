@@ -0,0 +1,63 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+// Typing statistics reported by the keyboard itself, through WPM events from the firmware. The statistics are kept
+// for the whole session, regardless of which tags are shown.
+
+package main
+
+import (
+	"encoding/binary"
+	"sync"
+	"time"
+)
+
+// The typing speed, in words per minute, that fills the bar of the typing tag.
+const TYPING_MAX_WPM = 150
+
+// How often the typing tag checks the statistics.
+const TYPING_REFRESH_INTERVAL = 250 * time.Millisecond
+
+// The typing statistics.
+type TypingState struct {
+	WPM        uint8     // The current typing speed, in words per minute.
+	PeakWPM    uint8     // The highest typing speed of the session.
+	Keypresses uint64    // The number of keys pressed during the session, if reported by the firmware.
+	Updated    time.Time // When the firmware last reported the statistics, or zero if it never has.
+}
+
+// The typing statistics of the keyboard, which are updated by the WPM events.
+type Typing struct {
+	mutex sync.Mutex
+	state TypingState
+}
+
+// The typing statistics reported by the keyboard.
+var gTyping Typing
+
+// Get the current typing statistics.
+func (typing *Typing) State() TypingState {
+	typing.mutex.Lock()
+	defer typing.mutex.Unlock()
+	return typing.state
+}
+
+// Update the statistics from a WPM event. The first parameter is the current typing speed, and the next two the number
+// of keys pressed since the previous event (16-bit little-endian), which is zero if the firmware doesn't count them.
+func (typing *Typing) HandleEvent(event Event) {
+	if len(event.Params) < 1 {
+		logWarn("Missing speed in WPM event.")
+		return
+	}
+
+	typing.mutex.Lock()
+	defer typing.mutex.Unlock()
+	typing.state.WPM = event.Params[0]
+	if typing.state.WPM > typing.state.PeakWPM {
+		typing.state.PeakWPM = typing.state.WPM
+	}
+	if len(event.Params) >= 3 {
+		typing.state.Keypresses += uint64(binary.LittleEndian.Uint16(event.Params[1:3]))
+	}
+	typing.state.Updated = time.Now()
+}
@@ -6,84 +6,269 @@
 package main
 
 import (
-	"log"
+	"context"
 	"net/http"
 	"time"
 
 	owm "github.com/briandowns/openweathermap"
 )
 
+// How soon to retry getting the current weather at first when it fails.
+const WEATHER_RETRY_MIN_DELAY = 30 * time.Second
+
+// The providers of the current weather.
+const (
+	OPEN_WEATHER_MAP = "openweathermap" // OpenWeatherMap.org, which requires an API key and a location.
+	OPEN_METEO       = "open-meteo"     // Open-Meteo.com, which requires a latitude and longitude.
+)
+
 type WeatherCondition byte // The type of a weather condition
 // The different weather conditions
 const (
-	ClearSky     = 0x00 // Clear sky
-	FewClouds    = 0x01 // Few clouds, partially sunny
-	Cloudy       = 0x02 // Cloudy
-	Rain         = 0x03 // Rainy
-	Thunderstorm = 0x04 // Thunderstorm-y
-	Snow         = 0x05 // Snowy
-	Mist         = 0x06 // Misty
+	ClearSky       = 0x00 // Clear sky
+	FewClouds      = 0x01 // Few clouds, partially sunny
+	Cloudy         = 0x02 // Cloudy
+	Rain           = 0x03 // Rainy
+	Thunderstorm   = 0x04 // Thunderstorm-y
+	Snow           = 0x05 // Snowy
+	Mist           = 0x06 // Misty
+	ClearNight     = 0x07 // Clear sky at night
+	FewCloudsNight = 0x08 // Few clouds at night
+	BrokenClouds   = 0x09 // Broken or overcast clouds
 )
 
 // The type of a weather result
 type WeatherResult struct {
-	Temperature float64          // The current temperature at the location
-	Weather     WeatherCondition // The current weather condition
+	Time        time.Time        // The time of the weather
+	Temperature float64          // The temperature at the location
+	Weather     WeatherCondition // The weather condition
+	Humidity    int              // The relative humidity, in percent
+	WindSpeed   float64          // The wind speed, in meters per second
 }
 
 // Map from weather condition to characters showing icons found in glcdfont.c
 var WEATHER_ICONS = map[WeatherCondition]string{
-	ClearSky:     "\x03\x04", // Sun icon
-	FewClouds:    "\x05\x06", // Cloud+sun icon
-	Cloudy:       "\x07\x08", // Cloud icon
-	Rain:         "\x09\x0A", // Rain icon
-	Thunderstorm: "\x0B\x0C", // Thunder icon
-	Snow:         "\x0D\x0E", // Snow icon
-	Mist:         "\x0F\x10", // Mist icon
+	ClearSky:       "\x03\x04", // Sun icon
+	FewClouds:      "\x05\x06", // Cloud+sun icon
+	Cloudy:         "\x07\x08", // Cloud icon
+	Rain:           "\x09\x0A", // Rain icon
+	Thunderstorm:   "\x0B\x0C", // Thunder icon
+	Snow:           "\x0D\x0E", // Snow icon
+	Mist:           "\x0F\x10", // Mist icon
+	ClearNight:     "\x1A\x1B", // Moon icon
+	FewCloudsNight: "\x1C\x1D", // Cloud+moon icon
+	BrokenClouds:   "\x1E\x1F", // Two clouds icon
+}
+
+// Map from weather condition to a short description
+var WEATHER_NAMES = map[WeatherCondition]string{
+	ClearSky:       "Clear",
+	FewClouds:      "Few clouds",
+	Cloudy:         "Cloudy",
+	Rain:           "Rain",
+	Thunderstorm:   "Thunderstorm",
+	Snow:           "Snow",
+	Mist:           "Mist",
+	ClearNight:     "Clear",
+	FewCloudsNight: "Few clouds",
+	BrokenClouds:   "Broken clouds",
+}
+
+// Translate an OpenWeatherMap icon code to a weather condition.
+// The codes are two digits followed by "d" for day, or "n" for night (e.g. "01n").
+func iconToCondition(icon string) WeatherCondition {
+	if len(icon) < 2 {
+		return ClearSky
+	}
+	night := len(icon) > 2 && icon[2] == 'n'
+
+	switch icon[:2] {
+	case "01":
+		if night {
+			return ClearNight
+		}
+		return ClearSky
+	case "02":
+		if night {
+			return FewCloudsNight
+		}
+		return FewClouds
+	case "03":
+		return Cloudy
+	case "04":
+		return BrokenClouds
+	case "09", "10":
+		return Rain
+	case "11":
+		return Thunderstorm
+	case "13":
+		return Snow
+	case "50":
+		return Mist
+	default:
+		return ClearSky
+	}
 }
 
 // Start a loop that gets the current temperature (in the specified unit as "C", "F", or "K") and weather status at the
 // specified location, with the specified API key.
-func WeatherStats(apiKey string, unit string, location string, result chan WeatherResult, quit chan bool) {
+func WeatherStats(ctx context.Context, apiKey string, unit string, location string, interval time.Duration, result chan WeatherResult) {
 	defer close(result)
 
-	weather, err := owm.NewCurrent(unit, "EN", apiKey)
+	key := cacheKey("weather", location, unit)
+	if cached, found := cachedValue(key); found {
+		result <- cached.(WeatherResult)
+	}
+
+	// Always get the temperature in Celsius, and convert it like the other temperatures.
+	weather, err := owm.NewCurrent("C", "EN", apiKey)
 	if err != nil {
-		log.Println("Failed to create weather service:", err)
+		logError("Failed to create weather service:", err)
 		return
 	}
 
+	delay := interval
 	for {
-		weather.CurrentByName(location)
-		if weather.Cod != 200 {
-			log.Printf("Failed to get weather report: %s (%d)\n", http.StatusText(weather.Cod), weather.Cod)
+		if err := weather.CurrentByName(location); err != nil {
+			delay = weatherRetryDelay(delay, interval)
+			logWarnf("Failed to get weather report: %v (retrying in %v)\n", err, delay)
+		} else if weather.Cod == http.StatusUnauthorized || weather.Cod == http.StatusForbidden {
+			// Retrying won't help until the key has been fixed.
+			logErrorf("Failed to get weather report: %s (%d). Check the API key.\n", http.StatusText(weather.Cod), weather.Cod)
+			return
+		} else if weather.Cod != 200 {
+			delay = weatherRetryDelay(delay, interval)
+			logWarnf("Failed to get weather report: %s (%d) (retrying in %v)\n", http.StatusText(weather.Cod), weather.Cod, delay)
 		} else if len(weather.Weather) < 1 {
-			log.Println("Failed to get weather report. Unknown location?")
+			delay = interval
+			logError("Failed to get weather report. Unknown location?")
+		} else {
+			delay = interval
+			current := WeatherResult{
+				Time:        time.Now(),
+				Temperature: ConvertTemperature(weather.Main.Temp, unit),
+				Weather:     iconToCondition(weather.Weather[0].Icon),
+				Humidity:    weather.Main.Humidity,
+				WindSpeed:   weather.Wind.Speed,
+			}
+			cacheValue(key, current)
+			result <- current
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Start getting the current weather from the provider selected with -weather-provider, if it has been configured. The
+// weather is shared with everything else getting it. Returns false, without starting anything, if it hasn't.
+func StartWeatherStats(ctx context.Context, result chan WeatherResult) bool {
+	unit, interval := *gArgs.temperatureUnit, *gArgs.weatherInterval
+
+	var key string
+	var start func(ctx context.Context, result chan WeatherResult)
+	switch *gArgs.weatherProvider {
+	case OPEN_METEO:
+		// There is no way of telling an unset position from 0,0, which is in the middle of the ocean anyway.
+		latitude, longitude := *gArgs.weatherLatitude, *gArgs.weatherLongitude
+		if latitude == 0 && longitude == 0 {
+			return false
+		}
+		key = cacheKey("open-meteo", latitude, longitude, unit, interval)
+		start = func(ctx context.Context, result chan WeatherResult) {
+			OpenMeteoStats(ctx, latitude, longitude, unit, interval, result)
+		}
+	default:
+		apiKey, location := *gArgs.weatherKey, *gArgs.weatherLocation
+		if apiKey == "" || location == "" {
+			return false
+		}
+		key = cacheKey("weather", apiKey, location, unit, interval)
+		start = func(ctx context.Context, result chan WeatherResult) {
+			WeatherStats(ctx, apiKey, unit, location, interval, result)
+		}
+	}
+
+	go func() {
+		defer close(result)
+
+		values := subscribe(ctx, key, func(ctx context.Context, values chan interface{}) {
+			reports := make(chan WeatherResult, 5)
+			go start(ctx, reports)
+			for report := range reports {
+				values <- report
+			}
+		})
+		for value := range values {
+			result <- value.(WeatherResult)
+		}
+	}()
+	return true
+}
+
+// Get the delay before retrying after a failed weather report. The first retry is done quickly, and the delay then
+// doubles each time, up to the normal interval.
+func weatherRetryDelay(previous time.Duration, interval time.Duration) time.Duration {
+	if previous >= interval {
+		if interval < WEATHER_RETRY_MIN_DELAY {
+			return interval
+		}
+		return WEATHER_RETRY_MIN_DELAY
+	}
+	if delay := previous * 2; delay < interval {
+		return delay
+	}
+	return interval
+}
+
+// Start a loop that gets a forecast of the temperature (in the specified unit as "C", "F", or "K") and weather status
+// at the specified location, with the specified API key. The forecast has the specified number of entries, three hours
+// apart.
+func WeatherForecast(ctx context.Context, apiKey string, unit string, location string, entries int, result chan []WeatherResult) {
+	defer close(result)
+
+	key := cacheKey("forecast", location, unit, entries)
+	if cached, found := cachedValue(key); found {
+		result <- cached.([]WeatherResult)
+	}
+
+	// Always get the temperature in Celsius, and convert it like the other temperatures.
+	forecast, err := owm.NewForecast("5", "C", "EN", apiKey)
+	if err != nil {
+		logError("Failed to create weather forecast service:", err)
+		return
+	}
+
+	for {
+		// The days are actually the number of three hour entries for the five day forecast.
+		if err := forecast.DailyByName(location, entries); err != nil {
+			logWarn("Failed to get weather forecast:", err)
+		} else if data, ok := forecast.ForecastWeatherJson.(*owm.Forecast5WeatherData); !ok || data.Cnt < 1 {
+			logError("Failed to get weather forecast. Unknown location?")
 		} else {
-			// Translate the icon code to a weather condition.
-			var icon WeatherCondition
-			switch weather.Weather[0].Icon[:2] {
-			case "01":
-				icon = ClearSky
-			case "02":
-				icon = FewClouds
-			case "03", "04":
-				icon = Cloudy
-			case "09", "10":
-				icon = Rain
-			case "11":
-				icon = Thunderstorm
-			case "13":
-				icon = Snow
-			case "50":
-				icon = Mist
+			var results []WeatherResult
+			for _, entry := range data.List {
+				if len(entry.Weather) < 1 {
+					continue
+				}
+				results = append(results, WeatherResult{
+					Time:        time.Unix(int64(entry.Dt), 0),
+					Temperature: ConvertTemperature(entry.Main.Temp, unit),
+					Weather:     iconToCondition(entry.Weather[0].Icon),
+					Humidity:    entry.Main.Humidity,
+					WindSpeed:   entry.Wind.Speed,
+				})
 			}
-			result <- WeatherResult{Temperature: weather.Main.Temp, Weather: icon}
+			cacheValue(key, results)
+			result <- results
 		}
 
 		select {
-		case <-time.After(5 * time.Minute):
-		case <-quit:
+		case <-time.After(30 * time.Minute):
+		case <-ctx.Done():
 			return
 		}
 	}
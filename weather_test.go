@@ -0,0 +1,77 @@
+// Copyright 2020 Albert "Drauthius" Diserholt. All rights reserved.
+// Licensed under the MIT License.
+
+// Tests of the translation of weather codes from the providers to weather conditions.
+
+package main
+
+import (
+	"testing"
+)
+
+func TestIconToCondition(t *testing.T) {
+	tests := []struct {
+		icon string
+		want WeatherCondition
+	}{
+		{"01d", ClearSky},
+		{"01n", ClearNight},
+		{"02d", FewClouds},
+		{"02n", FewCloudsNight},
+		{"03d", Cloudy},
+		{"03n", Cloudy},
+		{"04d", BrokenClouds},
+		{"04n", BrokenClouds},
+		{"09d", Rain},
+		{"09n", Rain},
+		{"10d", Rain},
+		{"10n", Rain},
+		{"11d", Thunderstorm},
+		{"11n", Thunderstorm},
+		{"13d", Snow},
+		{"13n", Snow},
+		{"50d", Mist},
+		{"50n", Mist},
+		{"01", ClearSky}, // Without day or night.
+		{"02", FewClouds},
+		{"99d", ClearSky}, // Unknown code.
+		{"99n", ClearSky},
+		{"1", ClearSky}, // Too short.
+		{"", ClearSky},
+	}
+
+	for _, test := range tests {
+		if got := iconToCondition(test.icon); got != test.want {
+			t.Errorf("iconToCondition(%q) = %d, want %d", test.icon, got, test.want)
+		}
+	}
+}
+
+func TestWMOWeatherCondition(t *testing.T) {
+	tests := []struct {
+		codes []int
+		day   WeatherCondition // The condition during the day.
+		night WeatherCondition // The condition at night.
+	}{
+		{[]int{0}, ClearSky, ClearNight},
+		{[]int{1, 2}, FewClouds, FewCloudsNight},
+		{[]int{3}, BrokenClouds, BrokenClouds},
+		{[]int{45, 48}, Mist, Mist},
+		{[]int{51, 53, 55, 56, 57, 61, 63, 65, 66, 67}, Rain, Rain},
+		{[]int{80, 81, 82}, Rain, Rain},
+		{[]int{71, 73, 75, 77, 85, 86}, Snow, Snow},
+		{[]int{95, 96, 99}, Thunderstorm, Thunderstorm},
+		{[]int{-1, 4, 44, 50, 68, 70, 78, 83, 84, 87, 94, 100}, ClearSky, ClearSky}, // Unknown codes.
+	}
+
+	for _, test := range tests {
+		for _, code := range test.codes {
+			if got := wmoWeatherCondition(code, true); got != test.day {
+				t.Errorf("wmoWeatherCondition(%d, true) = %d, want %d", code, got, test.day)
+			}
+			if got := wmoWeatherCondition(code, false); got != test.night {
+				t.Errorf("wmoWeatherCondition(%d, false) = %d, want %d", code, got, test.night)
+			}
+		}
+	}
+}